@@ -0,0 +1,188 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "time"
+)
+
+/**
+    @file alert.go
+    @brief Alarms on population thresholds and extinctions, posted to a webhook
+    Each -alert-on condition raises an alarm when it starts to hold, and again
+    only once it has not held for alertRearm chronons in a row, so a
+    population hovering at a bound or cycling across it does not raise one
+    every few chronons. The run ending in an
+    extinction raises one as well. Every alarm is printed, and with
+    -alert-url also POSTed in the background as a JSON object, e.g.
+
+        wa-tor -headless -alert-url https://hooks.example.com/wator -alert-on "sharks<100" -alert-on "fish>150000" 300 2000 3 8 5 1000 4
+
+        {"event":"threshold","condition":"sharks<100","chronon":5120,"seed":42,
+         "populations":{"fish":9160,"sharks":97},"time":"2026-10-14T09:12:03Z"}
+
+    The event is "threshold" or "extinction". A webhook that cannot be
+    reached is reported once and does not hold up the run; an alarm raised
+    while too many are still being sent is printed only.
+*/
+
+//  @brief Alarms waiting to be sent before new ones are printed only
+const alertQueue = 64
+
+//  @brief Chronons in a row a condition must not hold before it raises another alarm
+const alertRearm = 10
+
+//  @brief Alert is the JSON object posted for one alarm
+type Alert struct {
+    Event       string         `json:"event"`               //  "threshold" or "extinction"
+    Condition   string         `json:"condition,omitempty"` //  The condition that started to hold, for a threshold
+    Chronon     int            `json:"chronon"`
+    Seed        int64          `json:"seed"`
+    Populations map[string]int `json:"populations"` //  Count of every species
+    Time        time.Time      `json:"time"`
+}
+
+//  @brief Alerter raises the alarms of a run and posts them to a webhook
+type Alerter struct {
+    url    string     //  Webhook the alarms are posted to, empty to only print them
+    conds  Conditions //  Thresholds the alarms are raised on
+    armed  []bool     //  Whether each condition raises an alarm as it next holds
+    quiet  []int      //  Chronons in a row each condition has not held for
+    seed   int64
+    client *http.Client
+
+    queue   chan Alert    //  Alarms waiting to be sent
+    stopped chan struct{} //  Closed once the sender has sent everything
+    failed  bool          //  A post has already been reported as failing, owned by the sender
+}
+
+//  @brief Reports whether u is an http or https URL alarms can be posted to
+func validAlertURL(u string) bool {
+    parsed, err := url.Parse(u)
+    return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+//  @brief Creates an alerter raising alarms on conds and extinctions, posting them to endpoint when it is not empty, or returns nil when there is nothing to raise
+func NewAlerter(endpoint string, conds Conditions, seed int64) *Alerter {
+    if endpoint == "" && len(conds) == 0 {
+        return nil
+    }
+    a := &Alerter{
+        url:     endpoint,
+        conds:   conds,
+        armed:   make([]bool, len(conds)),
+        quiet:   make([]int, len(conds)),
+        seed:    seed,
+        client:  &http.Client{Timeout: 10 * time.Second},
+        queue:   make(chan Alert, alertQueue),
+        stopped: make(chan struct{}),
+    }
+    for i := range a.armed {
+        a.armed[i] = true
+    }
+    go a.send()
+    return a
+}
+
+//  @brief Raises an alarm for every condition that starts to hold for the counts of w at chronon
+func (a *Alerter) Check(cfg Config, w *World, chronon, fish, sharks int) {
+    if a == nil {
+        return
+    }
+    for i, c := range a.conds {
+        if !c.Met(fish, sharks) {
+            a.quiet[i]++
+            if a.quiet[i] >= alertRearm {
+                a.armed[i] = true
+            }
+            continue
+        }
+        if a.armed[i] {
+            a.raise(Alert{Event: "threshold", Condition: c.String(), Chronon: chronon, Populations: populations(cfg, w)},
+                fmt.Sprintf("%s at chronon %d", c, chronon))
+        }
+        a.armed[i], a.quiet[i] = false, 0
+    }
+}
+
+//  @brief Raises the alarm of a run ending in an extinction at chronon
+func (a *Alerter) Extinction(cfg Config, w *World, chronon int) {
+    if a == nil {
+        return
+    }
+    a.raise(Alert{Event: "extinction", Chronon: chronon, Populations: populations(cfg, w)}, fmt.Sprintf("extinction at chronon %d", chronon))
+}
+
+//  @brief Prints the alarm and queues it for the webhook
+func (a *Alerter) raise(alert Alert, text string) {
+    fmt.Printf("Alert: %s\n", text)
+    if a.url == "" {
+        return
+    }
+    alert.Seed, alert.Time = a.seed, time.Now().UTC()
+    select {
+    case a.queue <- alert:
+    default:
+        fmt.Printf("Could not post alert: %d alerts are still being sent\n", alertQueue)
+    }
+}
+
+//  @brief Sends the alarms still waiting and stops the sender
+func (a *Alerter) Close() {
+    if a == nil {
+        return
+    }
+    close(a.queue)
+    <-a.stopped
+}
+
+//  @brief Posts every queued alarm, reporting only the first failure so a missing webhook does not flood the output
+func (a *Alerter) send() {
+    defer close(a.stopped)
+    for alert := range a.queue {
+        if err := a.post(alert); err != nil && !a.failed {
+            a.failed = true
+            fmt.Printf("Could not post alert to %s: %v\n", a.url, err)
+        }
+    }
+}
+
+//  @brief Posts one alarm as JSON
+func (a *Alerter) post(alert Alert) error {
+    // conditions such as "fish>100" are sent as written, not with the HTML escape of >
+    var body bytes.Buffer
+    enc := json.NewEncoder(&body)
+    enc.SetEscapeHTML(false)
+    if err := enc.Encode(alert); err != nil {
+        return err
+    }
+    resp, err := a.client.Post(a.url, "application/json", &body)
+    if err != nil {
+        return err
+    }
+    resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        return fmt.Errorf("%s", resp.Status)
+    }
+    return nil
+}
+
+//  @brief Returns the count of every species of w by name, those of the food web when cfg has one
+func populations(cfg Config, w *World) map[string]int {
+    counts := make(map[string]int)
+    if cfg.FoodWeb != nil {
+        for i, n := range cfg.FoodWeb.Counts(w) {
+            counts[cfg.FoodWeb.Species[i].Name] = n
+        }
+        return counts
+    }
+    counts["fish"] = countEntities(w, Fish)
+    counts["sharks"] = countEntities(w, Shark)
+    if cfg.NumOrca > 0 {
+        counts["orcas"] = countEntities(w, Orca)
+    }
+    return counts
+}
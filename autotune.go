@@ -0,0 +1,114 @@
+package main
+
+import (
+    "fmt"
+    "runtime"
+    "slices"
+    "strings"
+    "time"
+)
+
+/**
+    @file autotune.go
+    @brief Picking the fastest worker count during the run, enabled with -auto-tune N
+    During the first N chronons every candidate worker count, the powers of
+    two up to twice the number of CPUs and -threads itself, steps blocks of
+    autoTuneBlock chronons in turn, so each sees about the same stretch of the
+    population's history. After chronon N the count with the shortest mean
+    step time is kept for the rest of the run, e.g.
+
+        wa-tor -headless -auto-tune 300 -chronons 2000 2000 30000 3 2 4 300 1
+
+        Auto-tune at chronon 300: 4 threads  1: 3.81ms  2: 2.07ms  4: 1.42ms  8: 1.55ms
+
+    The benchmark line and file then report the count kept. Worker streams
+    are never reseeded, fewer workers use the first streams of the most
+    workers tried, but which count steps which rows and wins depends on the
+    timings, so a tuned run does not repeat with its seed the way one on a
+    single thread does. A checkpoint written after tuning resumes with
+    -threads set to the count kept.
+*/
+
+//  @brief How many chronons a candidate steps in a row before the next one takes over
+const autoTuneBlock = 10
+
+//  @brief AutoTuner times the candidate worker counts and keeps the fastest
+type AutoTuner struct {
+    until   int             //  Last chronon of the trials
+    start   int             //  Chronon the trials started after
+    pool    []RNG           //  Streams of the most workers tried, fewer workers use a prefix
+    counts  []int           //  Candidate worker counts, ascending
+    total   []time.Duration //  Step time of each candidate
+    steps   []int           //  Chronons stepped by each candidate
+    settled bool
+}
+
+//  @brief Prepares trials of chronons chronons for s, keeping the streams it already has
+func NewAutoTuner(s *Simulator, chronons int) *AutoTuner {
+    limit := workerCount(max(s.Config.Threads, 2*runtime.NumCPU()), s.World.Size)
+    var counts []int
+    for n := 1; n < limit; n *= 2 {
+        counts = append(counts, n)
+    }
+    counts = append(counts, limit)
+    if threads := workerCount(s.Config.Threads, s.World.Size); !slices.Contains(counts, threads) {
+        counts = append(counts, threads)
+        slices.Sort(counts)
+    }
+
+    pool := append([]RNG(nil), s.rngs...)
+    for _, seed := range deriveSeeds(s.Seed, limit)[len(pool):] {
+        pool = append(pool, mustRNG(s.Config.RNG, seed))
+    }
+    return &AutoTuner{
+        until:  s.Chronon + chronons,
+        start:  s.Chronon,
+        pool:   pool,
+        counts: counts,
+        total:  make([]time.Duration, len(counts)),
+        steps:  make([]int, len(counts)),
+    }
+}
+
+//  @brief Index of the candidate stepping the chronon after chronon
+func (a *AutoTuner) candidate(chronon int) int {
+    return (chronon - a.start) / autoTuneBlock % len(a.counts)
+}
+
+//  @brief Sets the worker count for the next chronon of s
+func (a *AutoTuner) Before(s *Simulator) {
+    if a.settled {
+        return
+    }
+    s.rngs = a.pool[:a.counts[a.candidate(s.Chronon)]]
+}
+
+//  @brief Records how long the chronon just stepped took, and settles once the trials are over
+func (a *AutoTuner) After(s *Simulator, took time.Duration) {
+    if a.settled {
+        return
+    }
+    i := a.candidate(s.Chronon - 1)
+    a.total[i] += took
+    a.steps[i]++
+    if s.Chronon < a.until {
+        return
+    }
+
+    best := -1
+    var line strings.Builder
+    for i, n := range a.counts {
+        if a.steps[i] == 0 {
+            continue
+        }
+        mean := a.total[i] / time.Duration(a.steps[i])
+        fmt.Fprintf(&line, "  %d: %v", n, roundDiag(mean))
+        if best < 0 || mean < a.total[best]/time.Duration(a.steps[best]) {
+            best = i
+        }
+    }
+    a.settled = true
+    s.rngs = a.pool[:a.counts[best]]
+    s.Config.Threads = a.counts[best]
+    fmt.Printf("Auto-tune at chronon %d: %d threads%s\n", s.Chronon, a.counts[best], line.String())
+}
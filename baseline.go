@@ -0,0 +1,225 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "math"
+    "os"
+    "slices"
+    "strconv"
+)
+
+/**
+    @file baseline.go
+    @brief The baseline subcommand: a recorded reference run a new build is checked against
+    baseline record runs a seeded configuration headless for -chronons and
+    stores the configuration, the population of every species after each
+    chronon and the digest of the final world. baseline check runs the
+    stored configuration again and compares, e.g.
+
+        wa-tor baseline record -out testdata/baseline.json -seed 7 -chronons 500 300 2000 3 8 5 100 4
+        wa-tor baseline check testdata/baseline.json
+        wa-tor baseline check -exact testdata/baseline.json
+
+    Without -exact every population may differ from the recorded one by
+    -tolerance, a fraction of it; with -exact, meant for a build that must
+    reproduce the run bit for bit, every count and the final world must be
+    the same. Record steps the bands of the run serially (see strategy.go),
+    so a baseline of several threads repeats too, and -exact refuses one
+    recorded with threaded bands. Unlike the golden files, which pin small worlds with every
+    optional rule, a baseline keeps a whole run of any configuration. Like
+    diff(1) check exits with status 1 when the run departs from it.
+*/
+
+//  @brief Baseline is a recorded reference run
+type Baseline struct {
+    Config   json.RawMessage `json:"config"`   //  Configuration of the run, as in a configuration file
+    Chronons int             `json:"chronons"` //  Chronons the run lasts
+    Species  []string        `json:"species"`  //  Names the counts are given for, in order
+    Series   []BaselinePoint `json:"series"`   //  Populations after every step
+    Hash     string          `json:"hash"`     //  World.Hash of the final world
+}
+
+//  @brief BaselinePoint holds the populations of a baseline run after one chronon
+type BaselinePoint struct {
+    Chronon int   `json:"chronon"`
+    Counts  []int `json:"counts"` //  Count of every species, in the order of Baseline.Species
+}
+
+//  @brief Runs cfg headless for chronons and records its series and final world
+func RecordBaseline(cfg Config, chronons int) (Baseline, error) {
+    data, err := MarshalConfig(cfg)
+    if err != nil {
+        return Baseline{}, err
+    }
+    b := Baseline{Config: data, Chronons: chronons}
+
+    w, err := NewPopulatedWorld(cfg)
+    if err != nil {
+        return Baseline{}, err
+    }
+    sim := NewSimulator(cfg, w)
+    for name := range populations(cfg, sim.World) {
+        b.Species = append(b.Species, name)
+    }
+    slices.Sort(b.Species)
+
+    for sim.Chronon < chronons {
+        sim.Step()
+        counts := populations(cfg, sim.World)
+        point := BaselinePoint{Chronon: sim.Chronon, Counts: make([]int, len(b.Species))}
+        for i, name := range b.Species {
+            point.Counts[i] = counts[name]
+        }
+        b.Series = append(b.Series, point)
+    }
+    b.Hash = strconv.FormatUint(sim.World.Hash(), 16)
+    return b, nil
+}
+
+//  @brief Returns the configuration of the baseline, resolved like a configuration file
+func (b Baseline) config() (Config, error) {
+    cfg, err := UnmarshalConfig(b.Config)
+    if err != nil {
+        return Config{}, err
+    }
+    if cfg.FoodWeb != nil {
+        cfg.FoodWeb.Activate()
+    }
+    return cfg, nil
+}
+
+//  @brief Describes every way got departs from the baseline b, each population being allowed to differ by the fraction tolerance
+//  With exact the counts and final world must match exactly
+func (b Baseline) Compare(got Baseline, tolerance float64, exact bool) []string {
+    if exact {
+        tolerance = 0
+    }
+    if !slices.Equal(b.Species, got.Species) || len(b.Series) != len(got.Series) {
+        return []string{fmt.Sprintf("the baseline has %d chronons of %v, the run %d chronons of %v", len(b.Series), b.Species, len(got.Series), got.Species)}
+    }
+
+    var diffs []string
+    worst, worstAt := 0.0, ""
+    for i, want := range b.Series {
+        have := got.Series[i]
+        if want.Chronon != have.Chronon {
+            return append(diffs, fmt.Sprintf("step %d reached chronon %d, the baseline %d", i+1, have.Chronon, want.Chronon))
+        }
+        for j, name := range b.Species {
+            off := math.Abs(float64(have.Counts[j]-want.Counts[j])) / float64(max(want.Counts[j], 1))
+            if off > worst {
+                worst, worstAt = off, fmt.Sprintf("%s at chronon %d: %d, the baseline %d", name, want.Chronon, have.Counts[j], want.Counts[j])
+            }
+        }
+    }
+    if worst > tolerance {
+        diffs = append(diffs, fmt.Sprintf("%s, off by %.2f%% where %.2f%% is allowed", worstAt, 100*worst, 100*tolerance))
+    }
+    if exact && b.Hash != got.Hash {
+        diffs = append(diffs, fmt.Sprintf("the final world has hash %s, the baseline %s", got.Hash, b.Hash))
+    }
+    return diffs
+}
+
+//  @brief Reads a baseline written by baseline record
+func ReadBaseline(path string) (Baseline, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return Baseline{}, err
+    }
+    var b Baseline
+    if err := json.Unmarshal(data, &b); err != nil {
+        return Baseline{}, fmt.Errorf("baseline %s: %v", path, err)
+    }
+    return b, nil
+}
+
+//  @brief Writes the baseline as indented JSON
+func WriteBaseline(path string, b Baseline) error {
+    data, err := json.MarshalIndent(b, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+//  @brief Entry point of the baseline subcommand
+func runBaseline(args []string) {
+    usage := "Usage: wa-tor baseline record [-out file] [flags] NumShark NumFish FishBreed SharkBreed Starve GridSize Threads\n" +
+        "       wa-tor baseline check [-tolerance F] [-exact] file"
+    if len(args) == 0 {
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+
+    switch args[0] {
+    case "record":
+        fs := flag.NewFlagSet("baseline record", flag.ExitOnError)
+        outFlag := fs.String("out", "baseline.json", "File the baseline is written to")
+        cfg := parseConfig(fs, args[1:], true)
+        if cfg.Chronons <= 0 {
+            fmt.Println("Error: baseline record needs a target -chronons greater than 0.")
+            os.Exit(1)
+        }
+        cfg.Headless = true
+        // threaded bands race for the cells at their edges, so only serial ones repeat bit for bit
+        cfg.Strategy = strategySerial
+        b, err := RecordBaseline(cfg, cfg.Chronons)
+        if err == nil {
+            err = WriteBaseline(*outFlag, b)
+        }
+        if err != nil {
+            fmt.Printf("Error: %v\n", err)
+            os.Exit(1)
+        }
+        fmt.Printf("Recorded %d chronons with seed %d to %s\n", len(b.Series), cfg.Seed, *outFlag)
+
+    case "check":
+        fs := flag.NewFlagSet("baseline check", flag.ExitOnError)
+        toleranceFlag := fs.Float64("tolerance", 0.05, "Fraction every population may differ from the baseline by")
+        exactFlag := fs.Bool("exact", false, "Require every count and the final world to match exactly")
+        fs.Parse(args[1:])
+        if fs.NArg() != 1 {
+            fmt.Println(usage)
+            os.Exit(1)
+        }
+        if *toleranceFlag < 0 {
+            fmt.Println("Error: -tolerance must be 0 or greater.")
+            os.Exit(1)
+        }
+
+        want, err := ReadBaseline(fs.Arg(0))
+        if err != nil {
+            fmt.Printf("Error: %v\n", err)
+            os.Exit(1)
+        }
+        cfg, err := want.config()
+        if err != nil {
+            fmt.Printf("Error: baseline %s: %v\n", fs.Arg(0), err)
+            os.Exit(1)
+        }
+        if *exactFlag && !cfg.repeatable() {
+            fmt.Printf("Error: baseline %s was recorded with %d threaded bands, which do not repeat exactly; record it again or check it without -exact.\n", fs.Arg(0), cfg.Threads)
+            os.Exit(1)
+        }
+        got, err := RecordBaseline(cfg, want.Chronons)
+        if err != nil {
+            fmt.Printf("Error: %v\n", err)
+            os.Exit(1)
+        }
+        diffs := want.Compare(got, *toleranceFlag, *exactFlag)
+        for _, d := range diffs {
+            fmt.Printf("FAIL    %s\n", d)
+        }
+        if len(diffs) > 0 {
+            os.Exit(1)
+        }
+        fmt.Printf("ok      %d chronons with seed %d match %s\n", len(got.Series), cfg.Seed, fs.Arg(0))
+
+    default:
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+}
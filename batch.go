@@ -0,0 +1,120 @@
+package main
+
+import "sync"
+
+/**
+    @file batch.go
+    @brief Several chronons per synchronisation point, enabled with -batch K
+    StepWorld waits for every worker at the end of each chronon, and all of
+    them write into one shared world under the step mutex. With -batch K a
+    worker instead advances its band of rows K chronons on its own, from a
+    private copy of the band and a halo of rows above and below it, and
+    the bands are joined only once the K chronons are done.
+
+    Creatures move one cell per chronon and decide by their neighbours, so
+    what a cell holds after a chronon depends only on the cells within
+    batchRadius rows of it before. The halo is K * batchRadius rows deep:
+    each chronon the rows next to the edge of the copy, which miss what lies
+    beyond it, go wrong, one batchRadius further inward per chronon, and
+    after K chronons they have just reached the band. A worker therefore
+    steps all of its halo in the first chronon of a batch and batchRadius
+    rows fewer on either side in every one after.
+
+    Bands are stepped without sharing anything, so a batched run repeats
+    with its seed and thread count. Only the births, meals and deaths of
+    creatures in a worker's band are counted; the halo's are counted by the
+    neighbouring worker. The rest of the run sees every Kth chronon only,
+    so -stats-every and the other periods should be multiples of K, and
+    the chronon limit ends the last batch early. Following creatures from
+    chronon to chronon (-lifetimes, -follow and -explain), behaviors, which
+    can look further than batchRadius (-behavior and -plugin), and oceans
+    where a step can leave its rows (-depth above 1, the Möbius topology)
+    cannot be batched, e.g.
+
+        wa-tor -headless -batch 8 -chronons 800 20000 200000 3 2 4 2000 8
+*/
+
+//  @brief How many rows away a cell can still change what another holds after one chronon: a move and the neighbours it was chosen by
+const batchRadius = 2
+
+//  @brief Advances w by k chronons, each of the workers of rngs stepping its band on its own, the last chronon being chronon + k
+func stepBatch(w *World, cfg Config, rngs []RNG, k int, seed int64, chronon int) *World {
+    next := worldLike(w, make([][]Cell, w.Rows()))
+    var mu sync.Mutex // protects next.Events
+
+    bandRunner(cfg.Strategy)(w.Rows(), len(rngs), func(t, start, end int) {
+        band, events := stepBand(w, cfg, rngs[t], start, end, k, seed, chronon)
+        // every worker owns the rows of its band, so they need no lock
+        for row := start; row < end; row++ {
+            next.Cells[row] = band.Cells[row]
+        }
+        mu.Lock()
+        next.Events.Add(events)
+        mu.Unlock()
+    })
+    return next
+}
+
+//  @brief Steps rows start to end of w k chronons with rnd, returning the world they end up in and the events of the band
+//  Only the rows the band depends on are allocated, the others are nil
+func stepBand(w *World, cfg Config, rnd RNG, start, end, k int, seed int64, chronon int) (*World, Events) {
+    var events Events
+    current := w
+    var mu sync.Mutex // uncontended, the step functions expect one
+    for i := 0; i < k; i++ {
+        cfg.chronon = chronon + i + 1
+        // rows within reach of the band after the remaining chronons, and the rows their creatures can move to
+        reach := batchRadius*(k-1-i) + 1
+        lo, n := bandRange(start-reach, end+reach, w.Rows())
+        if n == w.Rows() {
+            // the whole grid, stepped in the order StepWorld steps it
+            lo = 0
+        }
+        next := newSlabLike(w, lo-1, min(n+2, w.Rows()))
+        // writes by creatures of the halo are counted in a copy sharing the cells
+        halo := *next
+
+        for j := 0; j < n; j++ {
+            row := (lo + j) % w.Rows()
+            target := &halo
+            if row >= start && row < end {
+                target = next
+            }
+            for col := 0; col < w.Size; col++ {
+                stepCell(current, target, row, col, cfg, rnd, &mu)
+            }
+        }
+        if cfg.EntityRNG {
+            next.advanceStreams(seed, chronon+i+1)
+        }
+        events.Add(next.Events)
+        current = next
+    }
+    return current, events
+}
+
+//  @brief Returns the first row, wrapped onto the grid, and the number of the rows from lo up to hi, at most rows
+func bandRange(lo, hi, rows int) (first, n int) {
+    n = min(hi-lo, rows)
+    return ((lo % rows) + rows) % rows, n
+}
+
+//  @brief Returns an empty world like w with only n rows from lo allocated, wrapping around the grid
+func newSlabLike(w *World, lo, n int) *World {
+    slab := worldLike(w, make([][]Cell, w.Rows()))
+    lo, n = bandRange(lo, lo+n, w.Rows())
+    cells := make([]Cell, n*w.Size)
+    for j := 0; j < n; j++ {
+        slab.Cells[(lo+j)%w.Rows()] = cells[j*w.Size : (j+1)*w.Size : (j+1)*w.Size]
+    }
+    return slab
+}
+
+//  @brief Returns how many chronons the next Step advances: -batch, but no further than the chronon limit
+func (s *Simulator) batchLength() int {
+    k := max(s.Config.Batch, 1)
+    if s.Config.Chronons > 0 {
+        k = min(k, s.Config.Chronons-s.Chronon)
+    }
+    return max(k, 1)
+}
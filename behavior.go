@@ -0,0 +1,206 @@
+package main
+
+import (
+    "fmt"
+    "plugin"
+    "slices"
+    "strings"
+    "sync"
+)
+
+/**
+    @file behavior.go
+    @brief Movement of a species chosen by name, built in or loaded from Go plugins
+    The rules work out which cells a creature may move to: empty cells for a
+    fish, fish for a hungry shark, then corpses, other sharks and empty
+    cells, and so on. Normally one is picked at random. A behavior picks
+    instead, given every candidate and a look at the world; eating,
+    breeding and starving stay as the rules have them. -behavior NAME
+    selects one for the species it was registered for, e.g.
+
+        wa-tor -headless -behavior wator.Schooling -behavior wator.Stalking 300 2000 3 8 5 100 4
+
+    Behaviors are registered with RegisterBehavior, or shipped in a Go
+    plugin loaded with -plugin file.so. A plugin is a main package built
+    with -buildmode=plugin by the same Go release as this program, which
+    exports, using only builtin types so that it needs nothing from here,
+
+        var Behaviors = []struct {
+            Name    string
+            Species string
+            Pick    func(row, col int, candidates [][2]int, look func(row, col int) (entity, energy int), intn func(n int) int) int
+        }{{"mypkg.SmartShark", "shark", smartShark}}
+
+    Species is fish, shark, orca or the name of a species of the species
+    file. Pick is given the creature's cell, the candidate cells, a look at
+    any cell of the world before the chronon (wrapped onto the grid; the
+    entities are numbered 0 empty, 1 fish, 2 shark, 3 orca, 4 corpse and
+    from firstSpecies on the species in file order) and the creature's random
+    stream, and returns the index of the candidate to move to.
+*/
+
+//  @brief PickFunc picks one of the candidate cells a creature at (row, col) may move to, see behavior.go
+type PickFunc = func(row, col int, candidates [][2]int, look func(row, col int) (entity, energy int), intn func(n int) int) int
+
+//  @brief Behavior is a named way of picking moves for one species
+type Behavior = struct {
+    Name    string
+    Species string
+    Pick    PickFunc
+}
+
+//  @brief Behaviors known to the process, by name
+var (
+    behaviorMu       sync.RWMutex
+    behaviorRegistry = map[string]Behavior{}
+)
+
+//  @brief Makes a behavior selectable by its name, replacing one registered under the same name
+func RegisterBehavior(b Behavior) {
+    behaviorMu.Lock()
+    defer behaviorMu.Unlock()
+    behaviorRegistry[b.Name] = b
+}
+
+//  @brief Registers the built-in behaviors: fish schooling and sharks stalking, both moving toward the most fish
+func init() {
+    RegisterBehavior(Behavior{"wator.Schooling", "fish", towardMost(Fish)})
+    RegisterBehavior(Behavior{"wator.Stalking", "shark", towardMost(Fish)})
+}
+
+//  @brief Names is a repeatable command-line flag collecting every value given
+type Names []string
+
+//  @brief Formats the names as a comma separated list (flag.Value)
+func (n *Names) String() string {
+    return strings.Join(*n, ",")
+}
+
+//  @brief Adds one name each time the flag is given (flag.Value)
+func (n *Names) Set(s string) error {
+    *n = append(*n, strings.TrimSpace(s))
+    return nil
+}
+
+//  @brief Opens the plugins at paths and registers the behaviors they export
+func loadPlugins(paths []string) error {
+    for _, path := range paths {
+        p, err := plugin.Open(path)
+        if err != nil {
+            return fmt.Errorf("plugin %s: %v", path, err)
+        }
+        sym, err := p.Lookup("Behaviors")
+        if err != nil {
+            return fmt.Errorf("plugin %s: %v", path, err)
+        }
+        behaviors, ok := sym.(*[]Behavior)
+        if !ok {
+            return fmt.Errorf("plugin %s: Behaviors is a %T, not a list of Name, Species and Pick", path, sym)
+        }
+        for _, b := range *behaviors {
+            RegisterBehavior(b)
+        }
+    }
+    return nil
+}
+
+//  @brief Returns the entity the species of a behavior is stored as, cfg deciding which species exist
+func behaviorEntity(species string, cfg Config) (Entity, error) {
+    if cfg.FoodWeb != nil {
+        for _, sp := range cfg.FoodWeb.Species {
+            if sp.Name == species {
+                return sp.entity, nil
+            }
+        }
+        return Empty, fmt.Errorf("the species file has no species %s", species)
+    }
+    switch species {
+    case "fish":
+        return Fish, nil
+    case "shark":
+        return Shark, nil
+    case "orca":
+        return Orca, nil
+    }
+    return Empty, fmt.Errorf("there is no species %s without a species file", species)
+}
+
+//  @brief Loads the plugins of cfg and returns the pick function of every species the behaviors of cfg name
+func resolveBehaviors(cfg Config) (map[Entity]PickFunc, error) {
+    if err := loadPlugins(cfg.Plugins); err != nil {
+        return nil, err
+    }
+    if len(cfg.Behaviors) == 0 && len(cfg.Temperature) == 0 {
+        return nil, nil
+    }
+    behaviorMu.RLock()
+    defer behaviorMu.RUnlock()
+    picks := make(map[Entity]PickFunc)
+    for _, name := range cfg.Behaviors {
+        b, ok := behaviorRegistry[name]
+        if !ok {
+            return nil, fmt.Errorf("unknown behavior %s, known are %s", name, strings.Join(behaviorNames(), ", "))
+        }
+        e, err := behaviorEntity(b.Species, cfg)
+        if err != nil {
+            return nil, fmt.Errorf("behavior %s: %v", name, err)
+        }
+        if _, dup := picks[e]; dup {
+            return nil, fmt.Errorf("behavior %s: another behavior was already selected for %s", name, b.Species)
+        }
+        picks[e] = b.Pick
+    }
+    return picks, applyTemperatures(picks, cfg)
+}
+
+//  @brief Returns the names of the registered behaviors, sorted, must be called with behaviorMu held
+func behaviorNames() []string {
+    names := make([]string, 0, len(behaviorRegistry))
+    for name := range behaviorRegistry {
+        names = append(names, name)
+    }
+    slices.Sort(names)
+    return names
+}
+
+//  @brief Returns the index of the candidate the creature e at (row, col) moves to: its behavior's pick, or a random one
+func pickMove(cfg Config, current *World, e Entity, row, col int, candidates [][2]int, rnd RNG) int {
+    pick, ok := cfg.behaviors[e]
+    if !ok {
+        return rnd.Intn(len(candidates))
+    }
+    look := func(r, c int) (int, int) {
+        r, c = (r%current.Rows()+current.Rows())%current.Rows(), (c%current.Size+current.Size)%current.Size
+        cell := current.Cells[r][c]
+        return int(cell.Entity), cell.Energy
+    }
+    // a copy, so that the candidates of the rules stay in their stepScratch whatever the behavior keeps
+    i := pick(row, col, slices.Clone(candidates), look, rnd.Intn)
+    if i < 0 || i >= len(candidates) {
+        panic(fmt.Sprintf("behavior for %s at (%d, %d) picked candidate %d of %d", entityName(e), row, col, i, len(candidates)))
+    }
+    return i
+}
+
+//  @brief Returns a pick function moving to the candidate with the most neighbours holding e, ties broken at random
+//  The neighbours are the four cells around a candidate, wrapped onto the grid
+func towardMost(e Entity) PickFunc {
+    return func(row, col int, candidates [][2]int, look func(row, col int) (int, int), intn func(n int) int) int {
+        best, most := []int{}, -1
+        for i, c := range candidates {
+            n := 0
+            for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+                if entity, _ := look(c[0]+d[0], c[1]+d[1]); Entity(entity) == e {
+                    n++
+                }
+            }
+            switch {
+            case n > most:
+                best, most = []int{i}, n
+            case n == most:
+                best = append(best, i)
+            }
+        }
+        return best[intn(len(best))]
+    }
+}
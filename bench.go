@@ -0,0 +1,136 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+    "time"
+)
+
+/**
+    @file bench.go
+    @brief Repeated-run benchmarking
+    A single timing is too noisy to support speedup claims, so a benchmark
+    can run the same configuration several times and report the mean,
+    standard deviation and minimum time along with every raw sample.
+*/
+
+//  @brief BenchmarkSummary holds the timings of every repetition of one configuration
+type BenchmarkSummary struct {
+    Samples []time.Duration
+}
+
+//  @brief Returns the mean of the samples
+func (b BenchmarkSummary) Mean() time.Duration {
+    if len(b.Samples) == 0 {
+        return 0
+    }
+    var total time.Duration
+    for _, s := range b.Samples {
+        total += s
+    }
+    return total / time.Duration(len(b.Samples))
+}
+
+//  @brief Returns the sample standard deviation, zero for fewer than two samples
+func (b BenchmarkSummary) StdDev() time.Duration {
+    if len(b.Samples) < 2 {
+        return 0
+    }
+    mean := float64(b.Mean())
+    sum := 0.0
+    for _, s := range b.Samples {
+        d := float64(s) - mean
+        sum += d * d
+    }
+    return time.Duration(math.Sqrt(sum / float64(len(b.Samples)-1)))
+}
+
+//  @brief Returns the fastest sample
+func (b BenchmarkSummary) Min() time.Duration {
+    if len(b.Samples) == 0 {
+        return 0
+    }
+    fastest := b.Samples[0]
+    for _, s := range b.Samples[1:] {
+        fastest = min(fastest, s)
+    }
+    return fastest
+}
+
+//  @brief Runs the configuration cfg.BenchReps times (at least once) on freshly populated worlds
+//  Every repetition appends its own row to the benchmark file as usual
+func RunBenchmark(cfg Config) BenchmarkSummary {
+    reps := max(cfg.BenchReps, 1)
+    summary := BenchmarkSummary{Samples: make([]time.Duration, 0, reps)}
+
+    for rep := 1; rep <= reps; rep++ {
+        if reps > 1 {
+            fmt.Printf("Repetition %d/%d\n", rep, reps)
+        }
+        world, err := NewPopulatedWorld(cfg)
+        if err != nil {
+            fmt.Printf("Error: %v\n", err)
+            break
+        }
+        result := RunSimulation(cfg, world)
+        summary.Samples = append(summary.Samples, result.Elapsed)
+    }
+
+    return summary
+}
+
+//  @brief Prints the summary of a repeated benchmark and records it next to the benchmark file
+func ReportBenchmark(cfg Config, summary BenchmarkSummary) {
+    fmt.Printf("Reps: %d  Mean: %v  StdDev: %v  Min: %v\n",
+        len(summary.Samples), summary.Mean(), summary.StdDev(), summary.Min())
+    writeBenchmarkSummary(cfg, summary)
+}
+
+//  @brief Returns the file repeated-run summaries are written to, e.g. results_summary.csv for results.csv
+func benchSummaryFile(benchFile string) string {
+    return strings.TrimSuffix(benchFile, ".csv") + "_summary.csv"
+}
+
+//  @brief Appends one summary row (mean, stddev, min and raw samples) if BenchFile is set
+func writeBenchmarkSummary(cfg Config, summary BenchmarkSummary) {
+    if cfg.BenchFile == "" {
+        return
+    }
+    path := benchSummaryFile(cfg.BenchFile)
+
+    // Raw samples are kept in one column, separated by semicolons
+    samples := make([]string, len(summary.Samples))
+    for i, s := range summary.Samples {
+        samples[i] = strconv.FormatFloat(millis(s), 'f', 3, 64)
+    }
+
+    row := fmt.Sprintf(
+        "%d,%d,%d,%d,%d,%d,%d,%d,%d,%.3f,%.3f,%.3f,%s,%d",
+        cfg.Threads,
+        cfg.GridSize,
+        cfg.NumFish,
+        cfg.NumShark,
+        cfg.FishBreed,
+        cfg.SharkBreed,
+        cfg.Starve,
+        cfg.Chronons,
+        len(summary.Samples),
+        millis(summary.Mean()),
+        millis(summary.StdDev()),
+        millis(summary.Min()),
+        strings.Join(samples, ";"),
+        cfg.Seed,
+    )
+
+    header := "Threads,GridSize,NumFish,NumShark,FishBreed,SharkBreed,Starve,Chronons,Reps,MeanMillis,StdDevMillis,MinMillis,SamplesMillis,Seed"
+    if err := appendCSVRow(path, header, row); err != nil {
+        fmt.Printf("Could not write benchmark summary file %s: %v\n", path, err)
+    }
+}
+
+//  @brief Converts a duration to fractional milliseconds
+func millis(d time.Duration) float64 {
+    return float64(d) / float64(time.Millisecond)
+}
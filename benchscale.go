@@ -0,0 +1,211 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+/**
+    @file benchscale.go
+    @brief The bench-scale subcommand: a one-command thread-scaling benchmark
+    Runs the same seeded configuration at each requested thread count and
+    prints a table of time, speedup and parallel efficiency relative to
+    the first thread count, optionally rendering a speedup chart, e.g.
+
+        wa-tor bench-scale -threads 1,2,4,8 -chart speedup.png -chronons 500 50 200 3 6 5 200
+
+    With -mode procs (or both) each count is also run as that many worker
+    processes (see multiproc.go), to compare process-level parallelism with goroutines.
+*/
+
+//  @brief One row of the scaling table
+type ScalePoint struct {
+    Mode       string //  "threads" or "procs"
+    Threads    int    //  Goroutines, or worker processes in procs mode
+    Time       time.Duration //  Mean time over the repetitions
+    Speedup    float64       //  Baseline time divided by this time
+    Efficiency float64       //  Speedup divided by the relative thread count
+}
+
+//  @brief Parses a comma separated list of positive thread counts, e.g. "1,2,4,8"
+func parseThreadList(s string) ([]int, error) {
+    var threads []int
+    for _, part := range strings.Split(s, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        t, err := strconv.Atoi(part)
+        if err != nil || t < 1 {
+            return nil, fmt.Errorf("thread count %q must be an integer of 1 or greater", part)
+        }
+        threads = append(threads, t)
+    }
+    if len(threads) == 0 {
+        return nil, fmt.Errorf("no thread counts given")
+    }
+    return threads, nil
+}
+
+//  Parallel modes bench-scale can measure
+const (
+    scaleThreads = "threads" //  One process, N goroutines stepping the world
+    scaleProcs   = "procs"   //  N worker processes connected by pipes
+)
+
+//  @brief Runs cfg at each thread count in every mode and computes speedup and efficiency against the first run
+func RunScaling(cfg Config, threads []int, modes []string) []ScalePoint {
+    points := make([]ScalePoint, 0, len(threads)*len(modes))
+
+    for _, mode := range modes {
+        for _, t := range threads {
+            cfg.Threads = t
+            var summary BenchmarkSummary
+            if mode == scaleProcs {
+                fmt.Printf("Benchmarking %d process(es)\n", t)
+                summary = benchmarkProcesses(cfg, t)
+            } else {
+                fmt.Printf("Benchmarking %d thread(s)\n", t)
+                summary = RunBenchmark(cfg)
+            }
+            points = append(points, ScalePoint{Mode: mode, Threads: t, Time: summary.Mean()})
+        }
+    }
+
+    base := points[0]
+    for i := range points {
+        p := &points[i]
+        p.Speedup = float64(base.Time) / float64(p.Time)
+        p.Efficiency = p.Speedup / (float64(p.Threads) / float64(base.Threads))
+    }
+
+    return points
+}
+
+//  @brief Runs cfg.BenchReps repetitions (at least one) split across n worker processes
+func benchmarkProcesses(cfg Config, n int) BenchmarkSummary {
+    reps := max(cfg.BenchReps, 1)
+    summary := BenchmarkSummary{Samples: make([]time.Duration, 0, reps)}
+
+    for rep := 1; rep <= reps; rep++ {
+        result, err := RunProcesses(cfg, n)
+        if err != nil {
+            fmt.Printf("Error: %v\n", err)
+            break
+        }
+        summary.Samples = append(summary.Samples, result.Elapsed)
+    }
+    return summary
+}
+
+//  @brief Prints the scaling results as an aligned table
+func printScaleTable(points []ScalePoint) {
+    fmt.Printf("%8s  %8s  %14s  %8s  %10s\n", "Mode", "Threads", "Time", "Speedup", "Efficiency")
+    for _, p := range points {
+        fmt.Printf("%8s  %8d  %14v  %8.2f  %9.1f%%\n", p.Mode, p.Threads, p.Time.Round(time.Microsecond), p.Speedup, p.Efficiency*100)
+    }
+}
+
+//  @brief Writes the scaling results as CSV
+func writeScaleCSV(path string, points []ScalePoint) error {
+    f, err := CreateOutput(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    fmt.Fprintln(f, "Threads,TimeMillis,Speedup,Efficiency,Mode")
+    for _, p := range points {
+        fmt.Fprintf(f, "%d,%.3f,%.4f,%.4f,%s\n", p.Threads, millis(p.Time), p.Speedup, p.Efficiency, p.Mode)
+    }
+    return f.Close()
+}
+
+//  @brief Builds a chart of measured speedup against thread count, with the ideal linear speedup dashed
+//  Threads are drawn in blue and processes in red
+func speedupChart(points []ScalePoint) *Chart {
+    threads := Series{Color: chartBlue}
+    procs := Series{Color: chartRed}
+    ideal := Series{Color: chartGrey, Dashed: true}
+    base := points[0].Threads
+    top := 1.0
+
+    for _, p := range points {
+        measured := &threads
+        if p.Mode == scaleProcs {
+            measured = &procs
+        } else {
+            ideal.X = append(ideal.X, float64(p.Threads))
+            ideal.Y = append(ideal.Y, float64(p.Threads)/float64(base))
+        }
+        measured.X = append(measured.X, float64(p.Threads))
+        measured.Y = append(measured.Y, p.Speedup)
+        top = max(top, p.Speedup, float64(p.Threads)/float64(base))
+    }
+    if len(ideal.X) == 0 {
+        ideal.X, ideal.Y = procs.X, make([]float64, len(procs.X))
+        for i, x := range procs.X {
+            ideal.Y[i] = x / float64(base)
+        }
+    }
+
+    // Speedup is shown from zero so small gains are not exaggerated
+    return &Chart{Width: 640, Height: 480, YMin: 0, YMax: top, Series: []Series{ideal, threads, procs}}
+}
+
+//  @brief Entry point of the bench-scale subcommand
+func runBenchScale(args []string) {
+    fs := flag.NewFlagSet("bench-scale", flag.ExitOnError)
+    threadsFlag := fs.String("threads", "1,2,4,8", "Comma separated thread counts to benchmark")
+    outFlag := fs.String("out", "", "Also write the scaling table as CSV to this file")
+    chartFlag := fs.String("chart", "", "Render a PNG chart of speedup against threads to this file")
+    modeFlag := fs.String("mode", scaleThreads, "Parallelism to measure: "+scaleThreads+", "+scaleProcs+" or both")
+    cfg := parseConfig(fs, args, false)
+
+    threads, err := parseThreadList(*threadsFlag)
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+
+    var modes []string
+    switch *modeFlag {
+    case scaleThreads, scaleProcs:
+        modes = []string{*modeFlag}
+    case "both":
+        modes = []string{scaleThreads, scaleProcs}
+    default:
+        fmt.Printf("Error: -mode must be %s, %s or both.\n", scaleThreads, scaleProcs)
+        os.Exit(1)
+    }
+    if *modeFlag != scaleThreads {
+        if err := checkClusterConfig(cfg, threads[len(threads)-1]); err != nil {
+            fmt.Printf("Error: %v.\n", err)
+            os.Exit(1)
+        }
+    }
+
+    // Drawing would dominate the timings; every thread count runs with the same seed from cfg
+    cfg.Headless = true
+    fmt.Printf("Loaded configuration: %+v\n", cfg)
+    fmt.Printf("Seed: %d\n", cfg.Seed)
+
+    points := RunScaling(cfg, threads, modes)
+    printScaleTable(points)
+
+    if *outFlag != "" {
+        if err := writeScaleCSV(*outFlag, points); err != nil {
+            fmt.Printf("Could not write scaling table %s: %v\n", *outFlag, err)
+        }
+    }
+
+    if *chartFlag != "" {
+        if err := WritePNG(*chartFlag, speedupChart(points).Render()); err != nil {
+            fmt.Printf("Could not write speedup chart %s: %v\n", *chartFlag, err)
+        }
+    }
+}
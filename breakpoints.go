@@ -0,0 +1,70 @@
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+/**
+    @file breakpoints.go
+    @brief Chronon breakpoints, set with -break-at
+    A run with a console (-console or -mouse) pauses once each listed chronon
+    has been computed, so the world can be examined and stepped from there.
+    Without a console each of those worlds is written as a snapshot instead,
+    next to the -snapshot file with the chronon added to its name, or to
+    break-CHRONON.json, e.g.
+
+        wa-tor -headless -seed 7 -break-at 1500,3000 -snapshot end.json 300 2000 3 8 5 100 4
+
+    writes end-1500.json and end-3000.json. With the same seed the moments
+    come out identical on every run, see golden.go.
+*/
+
+//  @brief Breakpoints is a comma separated, repeatable list of chronons
+type Breakpoints []int
+
+//  @brief Formats the chronons as a comma separated list (flag.Value)
+func (b *Breakpoints) String() string {
+    parts := make([]string, len(*b))
+    for i, c := range *b {
+        parts[i] = strconv.Itoa(c)
+    }
+    return strings.Join(parts, ",")
+}
+
+//  @brief Adds the chronons of a comma separated list such as "1500,3000" (flag.Value)
+func (b *Breakpoints) Set(s string) error {
+    for _, part := range strings.Split(s, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        c, err := strconv.Atoi(part)
+        if err != nil || c < 1 {
+            return fmt.Errorf("breakpoint %q must be a chronon of 1 or greater", part)
+        }
+        *b = append(*b, c)
+    }
+    return nil
+}
+
+//  @brief Reports whether the run breaks after chronon
+func (b Breakpoints) Has(chronon int) bool {
+    return slices.Contains(b, chronon)
+}
+
+//  @brief Returns the snapshot file of the breakpoint at chronon: snapshot with the chronon inserted before its first extension, or break-CHRONON.json
+func breakpointPath(snapshot string, chronon int) string {
+    if snapshot == "" {
+        return fmt.Sprintf("break-%d.json", chronon)
+    }
+    dir, name := filepath.Split(snapshot)
+    stem, ext, _ := strings.Cut(name, ".")
+    if ext != "" {
+        ext = "." + ext
+    }
+    return fmt.Sprintf("%s%s-%d%s", dir, stem, chronon, ext)
+}
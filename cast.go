@@ -0,0 +1,118 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+    "time"
+)
+
+/**
+    @file cast.go
+    @brief Recording of the terminal drawing in the asciinema v2 format, written with -cast
+    A cast file is a JSON header line giving the size of the terminal,
+    followed by one line per write to it holding the seconds since the
+    recording began and what was written:
+
+        {"version":2,"width":103,"height":102,"timestamp":1760400000,"title":"Wa-Tor seed 9"}
+        [0.000412,"o","\u001b[2J\u001b[HChronon: 0\u001b[K\n~~F~S..."]
+
+    The frames are always recorded as they are redrawn on a terminal, stats
+    pane included, even when the output goes to a file or pipe, so that
+    asciinema play, or the asciinema player on a web page, replays the run
+    at the speed it was drawn without a video encoder, e.g.
+
+        wa-tor -cast run.cast -chronons 500 300 2000 3 8 5 60 4
+*/
+
+//  @brief Columns kept for the population line and a stats pane, and lines for the pane, which the terminal size of a cast must hold
+const (
+    castFooterColumns = 48
+    castPaneColumns   = 32
+    castPaneLines     = 16
+)
+
+//  @brief CastWriter records everything written to it as output events of an asciinema v2 cast
+type CastWriter struct {
+    file  io.WriteCloser //  The file, behind a compressor when its name asks for one (see compress.go)
+    buf   *bufio.Writer
+    start time.Time //  When the header was written, the time of every event counts from here
+    line  []byte    //  Event line buffer, kept between writes to avoid reallocation
+}
+
+//  @brief Creates (or truncates) the cast at path and writes its header for a terminal of width columns and height lines
+func CreateCastWriter(path string, width, height int, title string) (*CastWriter, error) {
+    file, err := CreateOutput(path)
+    if err != nil {
+        return nil, err
+    }
+    c := &CastWriter{file: file, buf: bufio.NewWriter(file), start: time.Now()}
+
+    header, err := json.Marshal(struct {
+        Version   int    `json:"version"`
+        Width     int    `json:"width"`
+        Height    int    `json:"height"`
+        Timestamp int64  `json:"timestamp"`
+        Title     string `json:"title,omitempty"`
+    }{2, width, height, c.start.Unix(), title})
+    if err != nil {
+        file.Close()
+        return nil, err
+    }
+    c.buf.Write(header)
+    if err := c.buf.WriteByte('\n'); err != nil {
+        file.Close()
+        return nil, err
+    }
+    return c, nil
+}
+
+//  @brief Records p as one output event at the time since the header was written (io.Writer)
+func (c *CastWriter) Write(p []byte) (int, error) {
+    line := append(c.line[:0], '[')
+    line = strconv.AppendFloat(line, time.Since(c.start).Seconds(), 'f', 6, 64)
+    line = append(line, `,"o",`...)
+    data, err := json.Marshal(string(p))
+    if err != nil {
+        return 0, err
+    }
+    line = append(line, data...)
+    line = append(line, "]\n"...)
+    c.line = line
+
+    if _, err := c.buf.Write(line); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
+
+//  @brief Flushes the buffered events and closes the file
+func (c *CastWriter) Close() error {
+    err := c.buf.Flush()
+    if cerr := c.file.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
+//  @brief Returns the columns and lines of a terminal the frames of w fit on, with the stats pane laid out as layout
+func castSize(w *World, layout string) (width, height int) {
+    // header, grid and the footer or bottom pane below it
+    width, height = max(w.Size, castFooterColumns), gridLine(w, w.Rows()-1)+2
+    switch layout {
+    case paneSide:
+        width += paneGap + castPaneColumns
+        height = max(height, castPaneLines+2)
+    case paneBottom:
+        width = max(width, castPaneColumns)
+        height += castPaneLines
+    }
+    return width, height
+}
+
+//  @brief Returns the title of the cast of a run with the given seed
+func castTitle(seed int64) string {
+    return fmt.Sprintf("Wa-Tor seed %d", seed)
+}
@@ -7,14 +7,41 @@ package main
 		(1) fish
 		(2) shark
 		(3) empty
-	Cells also track breeding timers and (for sharks) energy levels
+	Cells also track breeding timers, (for sharks) energy levels and (for fish) the life stage
 */
 
+//	@brief Stage is the life stage of a fish; only fish are ever Juvenile
+type Stage uint8
+
+const (
+    Adult    Stage = iota //	 Fully grown, may breed (the default, so stages are off unless FishMature is set)
+    Juvenile              //	 Newly born, cannot breed and is a smaller meal
+)
+
 //	@brief Cell stores information about a single grid tile in the simulation
 type Cell struct {
     Entity     Entity //	What occupies the cell (Empty, Fish, Shark)
-    BreedTimer int    //	Counts how many chronons since last reproduction
+    BreedTimer int    //	Counts how many chronons since last reproduction, or since birth for juveniles
+    Stage      Stage  //	Juvenile or Adult, only used by fish
 
     //	Only used by sharks
-    Energy int //	Remaining energy before starvation
+    Energy    int //	Remaining energy before starvation
+    Gestation int //	Chronons of pregnancy left, 0 when not pregnant
+
+    Life //	Follows the creature as it moves, for lifetime statistics
+}
+
+//	@brief Life identifies a creature from birth to death, see lifetimes.go
+//	Creatures are only numbered while lifetimes are tracked, otherwise ID stays 0
+type Life struct {
+    ID        int    //	Number given by the lifetime tracker, 0 for a newborn it has not seen yet
+    Offspring int    //	Young this creature has had so far
+    Stream    uint64 //	State of its own random stream with -entity-rng, 0 until it is given one, see entityrng.go
+    Home      int    //	Surface cell number plus 1 of the anchor of a shark's range with -territory, 0 until it has one, see territory.go
+}
+
+//	@brief Returns the life of a parent that has just given birth
+func (l Life) bred() Life {
+    l.Offspring++
+    return l
 }
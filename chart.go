@@ -0,0 +1,308 @@
+package main
+
+import (
+    "image"
+    "image/color"
+    "image/png"
+    "math"
+    "os"
+    "strconv"
+)
+
+/**
+    @file chart.go
+    @brief Minimal line-chart rendering to PNG using only the standard library
+    Charts have labelled axes, any number of line series and shaded bands
+    drawn beneath them. Tick labels are drawn with a tiny built-in bitmap
+    font, so no font files are needed.
+*/
+
+//  @brief Series is one line on a chart
+type Series struct {
+    X, Y   []float64
+    Color  color.RGBA
+    Dashed bool //  Draw the line dashed, e.g. for reference lines
+}
+
+//  @brief Band is a shaded area between two lines on a chart, e.g. the range of several runs
+type Band struct {
+    X, Low, High []float64
+    Color        color.RGBA //  Colour of its lines, the band is a light shade of it
+}
+
+//  @brief Chart describes a line chart; zero axis ranges are computed from the data
+type Chart struct {
+    Width, Height int
+    XMin, XMax    float64
+    YMin, YMax    float64
+    Series        []Series
+    Bands         []Band //  Drawn beneath the series
+}
+
+//  Colours used by charts
+var (
+    chartBackground = color.RGBA{255, 255, 255, 255}
+    chartAxis       = color.RGBA{0, 0, 0, 255}
+    chartGrid       = color.RGBA{225, 225, 225, 255}
+    chartBlue       = color.RGBA{31, 119, 180, 255}
+    chartRed        = color.RGBA{214, 39, 40, 255}
+    chartGrey       = color.RGBA{127, 127, 127, 255}
+)
+
+//  @brief How much of its colour a band keeps, the rest being the background
+const chartBandShade = 0.25
+
+//  Space in pixels around the plot area
+const (
+    chartMarginLeft   = 50
+    chartMarginRight  = 20
+    chartMarginTop    = 20
+    chartMarginBottom = 35
+)
+
+//  @brief 3x5 bitmap glyphs for tick labels, one bit per pixel, rows top to bottom
+var chartFont = map[byte][5]byte{
+    '0': {7, 5, 5, 5, 7},
+    '1': {2, 6, 2, 2, 7},
+    '2': {7, 1, 7, 4, 7},
+    '3': {7, 1, 7, 1, 7},
+    '4': {5, 5, 7, 1, 1},
+    '5': {7, 4, 7, 1, 7},
+    '6': {7, 4, 7, 5, 7},
+    '7': {7, 1, 1, 1, 1},
+    '8': {7, 5, 7, 5, 7},
+    '9': {7, 5, 7, 1, 7},
+    '.': {0, 0, 0, 0, 2},
+    '-': {0, 0, 7, 0, 0},
+}
+
+//  @brief Scale factor applied to the bitmap font
+const chartFontScale = 2
+
+//  @brief Fills in any unset axis range from the series data
+func (c *Chart) autoRange() {
+    if c.XMin == c.XMax {
+        c.XMin, c.XMax = math.Inf(1), math.Inf(-1)
+        for _, s := range c.Series {
+            for _, x := range s.X {
+                c.XMin, c.XMax = math.Min(c.XMin, x), math.Max(c.XMax, x)
+            }
+        }
+        for _, b := range c.Bands {
+            for _, x := range b.X {
+                c.XMin, c.XMax = math.Min(c.XMin, x), math.Max(c.XMax, x)
+            }
+        }
+    }
+    if c.YMin == c.YMax {
+        c.YMin, c.YMax = math.Inf(1), math.Inf(-1)
+        for _, s := range c.Series {
+            for _, y := range s.Y {
+                c.YMin, c.YMax = math.Min(c.YMin, y), math.Max(c.YMax, y)
+            }
+        }
+        for _, b := range c.Bands {
+            for i := range b.Low {
+                c.YMin, c.YMax = math.Min(c.YMin, b.Low[i]), math.Max(c.YMax, b.High[i])
+            }
+        }
+    }
+
+    // No data or a single value still needs a non-empty range
+    if math.IsInf(c.XMin, 0) || math.IsInf(c.XMax, 0) {
+        c.XMin, c.XMax = 0, 1
+    }
+    if math.IsInf(c.YMin, 0) || math.IsInf(c.YMax, 0) {
+        c.YMin, c.YMax = 0, 1
+    }
+    if c.XMin == c.XMax {
+        c.XMax = c.XMin + 1
+    }
+    if c.YMin == c.YMax {
+        c.YMax = c.YMin + 1
+    }
+}
+
+//  @brief Returns a "nice" tick spacing (1, 2 or 5 times a power of ten) giving about n ticks over span
+func niceStep(span float64, n int) float64 {
+    raw := span / float64(n)
+    magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+    switch {
+    case raw/magnitude < 1.5:
+        return magnitude
+    case raw/magnitude < 3.5:
+        return 2 * magnitude
+    case raw/magnitude < 7.5:
+        return 5 * magnitude
+    }
+    return 10 * magnitude
+}
+
+//  @brief Formats a tick value with just enough decimals for the tick spacing
+func tickLabel(v, step float64) string {
+    decimals := 0
+    if step < 1 {
+        decimals = int(math.Ceil(-math.Log10(step)))
+    }
+    return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+//  @brief Renders the chart into a new image
+func (c *Chart) Render() *image.RGBA {
+    c.autoRange()
+    img := image.NewRGBA(image.Rect(0, 0, c.Width, c.Height))
+    fillRect(img, 0, 0, c.Width, c.Height, chartBackground)
+
+    left, right := chartMarginLeft, c.Width-chartMarginRight
+    top, bottom := chartMarginTop, c.Height-chartMarginBottom
+
+    toPixel := func(x, y float64) (int, int) {
+        px := left + int(math.Round((x-c.XMin)/(c.XMax-c.XMin)*float64(right-left)))
+        py := bottom - int(math.Round((y-c.YMin)/(c.YMax-c.YMin)*float64(bottom-top)))
+        return px, py
+    }
+
+    // Grid lines and tick labels
+    xStep := niceStep(c.XMax-c.XMin, 6)
+    for x := math.Ceil(c.XMin/xStep) * xStep; x <= c.XMax+xStep/1e6; x += xStep {
+        px, _ := toPixel(x, c.YMin)
+        drawLine(img, px, top, px, bottom, 1, false, chartGrid)
+        label := tickLabel(x, xStep)
+        drawText(img, px-textWidth(label)/2, bottom+6, label, chartAxis)
+    }
+    yStep := niceStep(c.YMax-c.YMin, 5)
+    for y := math.Ceil(c.YMin/yStep) * yStep; y <= c.YMax+yStep/1e6; y += yStep {
+        _, py := toPixel(c.XMin, y)
+        drawLine(img, left, py, right, py, 1, false, chartGrid)
+        label := tickLabel(y, yStep)
+        drawText(img, left-6-textWidth(label), py-5*chartFontScale/2, label, chartAxis)
+    }
+
+    // Axes
+    drawLine(img, left, top, left, bottom, 1, false, chartAxis)
+    drawLine(img, left, bottom, right, bottom, 1, false, chartAxis)
+
+    // Bands, filled column by column between their lines
+    for _, b := range c.Bands {
+        shade := shadeOf(b.Color)
+        for i := 1; i < len(b.X) && i < len(b.Low) && i < len(b.High); i++ {
+            x0, _ := toPixel(b.X[i-1], 0)
+            x1, _ := toPixel(b.X[i], 0)
+            for px := x0; px <= x1; px++ {
+                f := 0.0
+                if x1 > x0 {
+                    f = float64(px-x0) / float64(x1-x0)
+                }
+                _, high := toPixel(0, b.High[i-1]+f*(b.High[i]-b.High[i-1]))
+                _, low := toPixel(0, b.Low[i-1]+f*(b.Low[i]-b.Low[i-1]))
+                fillRect(img, px, high, px+1, low+1, shade)
+            }
+        }
+    }
+
+    // Data
+    for _, s := range c.Series {
+        for i := 1; i < len(s.X) && i < len(s.Y); i++ {
+            x0, y0 := toPixel(s.X[i-1], s.Y[i-1])
+            x1, y1 := toPixel(s.X[i], s.Y[i])
+            drawLine(img, x0, y0, x1, y1, 2, s.Dashed, s.Color)
+        }
+    }
+
+    return img
+}
+
+//  @brief Returns the light shade of col a band is filled with
+func shadeOf(col color.RGBA) color.RGBA {
+    mix := func(v, background uint8) uint8 {
+        return uint8(math.Round(chartBandShade*float64(v) + (1-chartBandShade)*float64(background)))
+    }
+    return color.RGBA{mix(col.R, chartBackground.R), mix(col.G, chartBackground.G), mix(col.B, chartBackground.B), 255}
+}
+
+//  @brief Fills the rectangle [x0, x1) x [y0, y1) with a colour
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, col color.RGBA) {
+    for y := y0; y < y1; y++ {
+        for x := x0; x < x1; x++ {
+            img.SetRGBA(x, y, col)
+        }
+    }
+}
+
+//  @brief Draws a line of the given thickness using Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1, thickness int, dashed bool, col color.RGBA) {
+    dx, dy := abs(x1-x0), -abs(y1-y0)
+    sx, sy := 1, 1
+    if x0 > x1 {
+        sx = -1
+    }
+    if y0 > y1 {
+        sy = -1
+    }
+    err := dx + dy
+
+    for step := 0; ; step++ {
+        // Dashes are 6 pixels on, 4 pixels off
+        if !dashed || step%10 < 6 {
+            fillRect(img, x0, y0, x0+thickness, y0+thickness, col)
+        }
+        if x0 == x1 && y0 == y1 {
+            return
+        }
+        e2 := 2 * err
+        if e2 >= dy {
+            err += dy
+            x0 += sx
+        }
+        if e2 <= dx {
+            err += dx
+            y0 += sy
+        }
+    }
+}
+
+//  @brief Returns the width in pixels of a label drawn with the bitmap font
+func textWidth(s string) int {
+    return len(s) * 4 * chartFontScale
+}
+
+//  @brief Draws a label with its top-left corner at (x, y) using the bitmap font
+func drawText(img *image.RGBA, x, y int, s string, col color.RGBA) {
+    for i := 0; i < len(s); i++ {
+        glyph, ok := chartFont[s[i]]
+        if !ok {
+            continue
+        }
+        for row := 0; row < 5; row++ {
+            for bit := 0; bit < 3; bit++ {
+                if glyph[row]&(4>>bit) != 0 {
+                    px := x + (i*4+bit)*chartFontScale
+                    py := y + row*chartFontScale
+                    fillRect(img, px, py, px+chartFontScale, py+chartFontScale, col)
+                }
+            }
+        }
+    }
+}
+
+//  @brief Returns the absolute value of an integer
+func abs(v int) int {
+    if v < 0 {
+        return -v
+    }
+    return v
+}
+
+//  @brief Encodes an image as a PNG file
+func WritePNG(path string, img image.Image) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    if err := png.Encode(f, img); err != nil {
+        return err
+    }
+    return f.Close()
+}
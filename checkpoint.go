@@ -0,0 +1,186 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+)
+
+/**
+    @file checkpoint.go
+    @brief Checkpointing and resuming of simulations
+    A checkpoint is a world snapshot plus the state of every worker's random
+    stream, so a resumed run continues along exactly the same stochastic
+    trajectory as an uninterrupted one. Creatures near the boundary between
+    two workers can race for the same cell, so runs with more than one
+    thread are only repeatable with -strategy serial or where no such race
+    occurs.
+    Checkpoints written every -checkpoint-every chronons are encoded in the
+    background from a View of the world while the run goes on, the one at
+    the end of the run waits for them.
+*/
+
+//  @brief Checkpoint is the on-disk JSON form of a paused simulation
+type Checkpoint struct {
+    Snapshot
+    RNG     string   `json:"rng"`        //  Generator algorithm the streams belong to
+    Seed    int64    `json:"seed"`       //  Effective seed of the original run
+    Streams [][]byte `json:"rngStreams"` //  Saved state of each worker's stream, in worker order
+
+    NamedStreams map[string][]byte `json:"namedStreams,omitempty"` //  Saved state of each RNGStream by name
+}
+
+//  @brief Captures the current state of the simulator
+func (s *Simulator) Checkpoint() (Checkpoint, error) {
+    cp, err := s.streamStates()
+    if err != nil {
+        return Checkpoint{}, err
+    }
+    cp.Snapshot = NewSnapshot(s.World, s.Chronon)
+    return cp, nil
+}
+
+//  @brief Captures the state of the random streams, everything of a checkpoint but the world
+func (s *Simulator) streamStates() (Checkpoint, error) {
+    cp := Checkpoint{
+        RNG:      s.Config.RNG,
+        Seed:     s.Seed,
+        Streams:  make([][]byte, len(s.rngs)),
+    }
+    for i, r := range s.rngs {
+        state, err := r.MarshalBinary()
+        if err != nil {
+            return Checkpoint{}, err
+        }
+        cp.Streams[i] = state
+    }
+
+    if len(s.streams) > 0 {
+        cp.NamedStreams = make(map[string][]byte, len(s.streams))
+        for name, r := range s.streams {
+            state, err := r.MarshalBinary()
+            if err != nil {
+                return Checkpoint{}, err
+            }
+            cp.NamedStreams[name] = state
+        }
+    }
+    return cp, nil
+}
+
+//  @brief Writes a checkpoint of s to path
+//  The file is written under a temporary name and renamed, so an interrupted write never destroys the previous checkpoint
+func WriteCheckpoint(path string, s *Simulator) error {
+    cp, err := s.Checkpoint()
+    if err != nil {
+        return err
+    }
+    return writeCheckpointFile(path, cp)
+}
+
+//  @brief Writes cp to path under a temporary name and renames it
+func writeCheckpointFile(path string, cp Checkpoint) error {
+    tmp := path + ".tmp"
+    f, err := os.Create(tmp)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    if err := json.NewEncoder(f).Encode(cp); err != nil {
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp, path)
+}
+
+//  @brief checkpointWriter writes the periodic checkpoints of a run in the background, one at a time
+type checkpointWriter struct {
+    path string
+    done chan struct{} //  Closed when the write in progress has finished, nil before the first
+}
+
+//  @brief Starts writing a checkpoint of s as it is now, once the previous one has been written
+//  The streams are captured at once and the world through a View, so the run can step on meanwhile
+func (cw *checkpointWriter) Write(s *Simulator) {
+    cw.Wait()
+    cp, err := s.streamStates()
+    if err != nil {
+        fmt.Printf("Could not write checkpoint %s: %v\n", cw.path, err)
+        return
+    }
+    view, chronon := s.World.View(), s.Chronon
+
+    done := make(chan struct{})
+    cw.done = done
+    go func() {
+        defer close(done)
+        cp.Snapshot = NewSnapshot(view, chronon)
+        if err := writeCheckpointFile(cw.path, cp); err != nil {
+            fmt.Printf("Could not write checkpoint %s: %v\n", cw.path, err)
+        }
+    }()
+}
+
+//  @brief Waits until the checkpoint being written, if any, is on disk
+func (cw *checkpointWriter) Wait() {
+    if cw.done != nil {
+        <-cw.done
+    }
+}
+
+//  @brief Reads a checkpoint from path
+func ReadCheckpoint(path string) (Checkpoint, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return Checkpoint{}, err
+    }
+    defer f.Close()
+
+    var cp Checkpoint
+    if err := json.NewDecoder(f).Decode(&cp); err != nil {
+        return Checkpoint{}, fmt.Errorf("could not decode checkpoint %s: %v", path, err)
+    }
+    return cp, nil
+}
+
+//  @brief Recreates the simulator saved in cp, to be continued with cfg
+//  The grid size, thread count and generator must match the checkpointed run
+func ResumeSimulator(cfg Config, cp Checkpoint) (*Simulator, error) {
+    w, err := cp.World()
+    if err != nil {
+        return nil, err
+    }
+    if w.Size != cfg.GridSize {
+        return nil, fmt.Errorf("checkpoint grid size is %d, configuration has %d", w.Size, cfg.GridSize)
+    }
+    if max(w.Depth, 1) != max(cfg.Depth, 1) {
+        return nil, fmt.Errorf("checkpoint has %d depth layers, configuration has %d", max(w.Depth, 1), max(cfg.Depth, 1))
+    }
+    if cp.RNG != cfg.RNG {
+        return nil, fmt.Errorf("checkpoint uses the %s generator, configuration has %s", cp.RNG, cfg.RNG)
+    }
+
+    s := NewSimulator(cfg, w)
+    if len(cp.Streams) != len(s.rngs) {
+        return nil, fmt.Errorf("checkpoint has %d random streams, configuration uses %d threads", len(cp.Streams), len(s.rngs))
+    }
+    for i, state := range cp.Streams {
+        if err := s.rngs[i].UnmarshalBinary(state); err != nil {
+            return nil, err
+        }
+    }
+    s.Chronon = cp.Chronon
+    s.Seed = cp.Seed
+
+    // Named streams are recreated from the restored seed, then moved to their saved position
+    for name, state := range cp.NamedStreams {
+        if err := s.RNGStream(name).UnmarshalBinary(state); err != nil {
+            return nil, err
+        }
+    }
+
+    return s, nil
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+    "encoding/gob"
+    "flag"
+    "fmt"
+    "io"
+    "net"
+    "os"
+    "sync"
+    "time"
+)
+
+/**
+    @file cluster.go
+    @brief Cluster mode: one grid split across several processes or machines
+    The coordinator splits the grid into bands of rows, one per worker, and
+    each worker only ever holds its own band plus one halo row above and
+    below it, so grids too large for one machine can be simulated. Every
+    chronon the coordinator relays halo exchanges between neighbouring bands
+    over TCP:
+        1. each worker sends its first and last rows and its population counts
+        2. the coordinator decides whether to stop, and otherwise hands each worker
+           the rows bordering its band
+        3. each worker steps its band and sends the creatures that moved out of it
+        4. the coordinator hands those creatures to the bands they moved into
+    A creature moving into a cell that the neighbouring band has already filled
+    this chronon is lost, just as when two threads write the same cell. Start
+    the coordinator first, then the workers, e.g.
+
+        wa-tor cluster -listen :7070 -workers 4 -chronons 1000 -stats-every 100 50000 200000 3 8 5 20000
+        wa-tor cluster-worker -join coordinator:7070      (on each worker host)
+
+    With -local the coordinator starts its workers itself as goroutines, which is useful
+    for testing, and with -spawn as child processes on the same machine.
+*/
+
+//  @brief Sent to a worker once it joins: which band it owns and how to populate it
+type clusterAssign struct {
+    Config     Config
+    Row0, Row1 int   //  Rows [Row0, Row1) owned by the worker
+    Seed       int64 //  Seed of the worker's placement and step streams
+    NumFish    int   //  Creatures the worker places in its band
+    NumShark   int
+    NumOrca    int
+}
+
+//  @brief Sent by a worker at the start of every chronon
+type clusterEdges struct {
+    Top, Bottom []Cell //  First and last rows of the band
+    Fish        int    //  Population of the band
+    Sharks      int
+    Orcas       int
+}
+
+//  @brief Sent to a worker with the rows bordering its band, or telling it to stop
+type clusterHalo struct {
+    Stop         bool
+    Above, Below []Cell //  Last row of the band above and first row of the band below
+}
+
+//  @brief Sent by a worker after stepping: the creatures that moved into the halo rows
+type clusterMigrants struct {
+    Up, Down []Cell
+}
+
+//  @brief Sent to a worker with the creatures that moved into its first and last rows
+type clusterArrivals struct {
+    FromAbove, FromBelow []Cell
+}
+
+//  @brief Returns the rows [row0, row1) of band i when size rows are split into n bands
+//  StepWorld splits its rows the same way (see inBands), with the remainder spread over the first bands
+func bandRows(size, n, i int) (int, int) {
+    per, remainder := size/n, size%n
+    row0 := i*per + min(i, remainder)
+    row1 := row0 + per
+    if i < remainder {
+        row1++
+    }
+    return row0, row1
+}
+
+//  @brief Returns band i's share of total creatures, in proportion to its rows, so all shares add up to total
+func bandShare(total, size, row0, row1 int) int {
+    return total*row1/size - total*row0/size
+}
+
+//  @brief Creates a world of the full size where only the rows of the band and its halo rows are allocated
+//  The step functions only ever touch a cell's neighbours, so stepping the band never reaches other rows
+func newBandWorld(cfg Config, row0, row1 int) *World {
+    w := &World{
+        Size:        cfg.GridSize,
+        Cells:       make([][]Cell, cfg.GridSize),
+        FishBreed:   cfg.FishBreed,
+        SharkBreed:  cfg.SharkBreed,
+        Starve:      cfg.Starve,
+        OrcaBreed:   cfg.OrcaBreed,
+        OrcaStarve:  cfg.OrcaStarve,
+        CorpseDecay: cfg.CorpseDecay,
+        FishMature:  cfg.FishMature,
+        FishEggs:    cfg.FishEggs,
+        Gestation:   cfg.Gestation,
+        Topology:    cfg.Topology,
+    }
+    for row := row0 - 1; row <= row1; row++ {
+        w.Cells[w.wrap(row)] = make([]Cell, cfg.GridSize)
+    }
+    return w
+}
+
+//  @brief Randomly places the assigned creatures in the band, sharks first, then fish, then orcas
+func populateBand(w *World, a clusterAssign, rnd RNG) error {
+    spots := w.emptyCellsIn(Region{Row0: a.Row0, Col0: 0, Row1: a.Row1, Col1: w.Size})
+    if a.NumShark+a.NumFish+a.NumOrca > len(spots) {
+        return fmt.Errorf("cannot place %d creatures in the %d cells of rows %d to %d",
+            a.NumShark+a.NumFish+a.NumOrca, len(spots), a.Row0, a.Row1-1)
+    }
+
+    pickRandom(spots, a.NumShark+a.NumFish+a.NumOrca, rnd)
+    for i, pos := range spots[:a.NumShark+a.NumFish+a.NumOrca] {
+        switch {
+        case i < a.NumShark:
+            w.Cells[pos[0]][pos[1]] = Cell{Entity: Shark, Energy: w.Starve}
+        case i < a.NumShark+a.NumFish:
+            w.Cells[pos[0]][pos[1]] = Cell{Entity: Fish}
+        default:
+            w.Cells[pos[0]][pos[1]] = Cell{Entity: Orca, Energy: w.OrcaStarve}
+        }
+    }
+    return nil
+}
+
+//  @brief Counts the creatures of entity e in rows [row0, row1)
+func countRows(w *World, e Entity, row0, row1 int) int {
+    count := 0
+    for row := row0; row < row1; row++ {
+        for _, cell := range w.Cells[row] {
+            if cell.Entity == e {
+                count++
+            }
+        }
+    }
+    return count
+}
+
+//  @brief Places arriving creatures into empty cells of a row, the band's own creatures keep their cells
+func mergeArrivals(row, arrivals []Cell) {
+    for col, cell := range arrivals {
+        if cell.Entity != Empty && row[col].Entity == Empty {
+            row[col] = cell
+        }
+    }
+}
+
+//  @brief Runs one worker over an established connection to the coordinator until told to stop
+func runClusterWorker(conn io.ReadWriter) error {
+    enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+
+    var a clusterAssign
+    if err := dec.Decode(&a); err != nil {
+        return fmt.Errorf("could not read assignment: %v", err)
+    }
+    cfg := a.Config
+    rnd := mustRNG(cfg.RNG, a.Seed)
+
+    w := newBandWorld(cfg, a.Row0, a.Row1)
+    if err := populateBand(w, a, rnd); err != nil {
+        return err
+    }
+    above, below := w.wrap(a.Row0-1), w.wrap(a.Row1)
+
+    // A single worker owns the whole torus, so its halo rows are its own rows and nothing leaves the band
+    whole := a.Row1-a.Row0 == cfg.GridSize
+
+    for chronon := 1; ; chronon++ {
+        cfg.chronon = chronon
+        edges := clusterEdges{
+            Top:    w.Cells[a.Row0],
+            Bottom: w.Cells[a.Row1-1],
+            Fish:   countRows(w, Fish, a.Row0, a.Row1),
+            Sharks: countRows(w, Shark, a.Row0, a.Row1),
+            Orcas:  countRows(w, Orca, a.Row0, a.Row1),
+        }
+        if err := enc.Encode(edges); err != nil {
+            return err
+        }
+
+        var halo clusterHalo
+        if err := dec.Decode(&halo); err != nil {
+            return err
+        }
+        if halo.Stop {
+            return nil
+        }
+        if !whole {
+            copy(w.Cells[above], halo.Above)
+            copy(w.Cells[below], halo.Below)
+        }
+
+        // Step only the band; the halo rows belong to the neighbours
+        var mu sync.Mutex
+        next := newBandWorld(cfg, a.Row0, a.Row1)
+        for row := a.Row0; row < a.Row1; row++ {
+            for col := 0; col < w.Size; col++ {
+                stepCell(w, next, row, col, cfg, rnd, &mu)
+            }
+        }
+
+        migrants := clusterMigrants{Up: next.Cells[above], Down: next.Cells[below]}
+        if whole {
+            migrants = clusterMigrants{}
+        }
+        if err := enc.Encode(migrants); err != nil {
+            return err
+        }
+        var arrivals clusterArrivals
+        if err := dec.Decode(&arrivals); err != nil {
+            return err
+        }
+        mergeArrivals(next.Cells[a.Row0], arrivals.FromAbove)
+        mergeArrivals(next.Cells[a.Row1-1], arrivals.FromBelow)
+        w = next
+    }
+}
+
+//  @brief Coordinates a cluster run over the worker connections and prints the aggregated results
+func coordinateCluster(cfg Config, conns []io.ReadWriter) (RunResult, error) {
+    n := len(conns)
+    encs, decs := make([]*gob.Encoder, n), make([]*gob.Decoder, n)
+    for i, conn := range conns {
+        encs[i], decs[i] = gob.NewEncoder(conn), gob.NewDecoder(conn)
+
+        row0, row1 := bandRows(cfg.GridSize, n, i)
+        a := clusterAssign{
+            Config:   cfg,
+            Row0:     row0,
+            Row1:     row1,
+            Seed:     streamSeed(cfg.Seed, fmt.Sprintf("cluster-worker-%d", i)),
+            NumFish:  bandShare(cfg.NumFish, cfg.GridSize, row0, row1),
+            NumShark: bandShare(cfg.NumShark, cfg.GridSize, row0, row1),
+            NumOrca:  bandShare(cfg.NumOrca, cfg.GridSize, row0, row1),
+        }
+        if err := encs[i].Encode(a); err != nil {
+            return RunResult{}, fmt.Errorf("worker %d: %v", i, err)
+        }
+    }
+
+    edges := make([]clusterEdges, n)
+    migrants := make([]clusterMigrants, n)
+    start := time.Now()
+    lastStats := start
+    var fish, sharks, orcas int
+
+    for chronon := 0; ; chronon++ {
+        fish, sharks, orcas = 0, 0, 0
+        for i := range decs {
+            // gob leaves zero fields untouched, so every message is decoded into a fresh value
+            edges[i] = clusterEdges{}
+            if err := decs[i].Decode(&edges[i]); err != nil {
+                return RunResult{}, fmt.Errorf("worker %d: %v", i, err)
+            }
+            fish += edges[i].Fish
+            sharks += edges[i].Sharks
+            orcas += edges[i].Orcas
+        }
+
+        stop := false
+        if chronon == 0 {
+            fmt.Printf("Placed Fish: %d  Sharks: %d  Orcas: %d  Workers: %d\n", fish, sharks, orcas, n)
+        } else {
+            if cfg.StatsEvery > 0 && chronon%cfg.StatsEvery == 0 {
+                now := time.Now()
+                rate := float64(cfg.StatsEvery) / now.Sub(lastStats).Seconds()
+                fmt.Printf("Chronon: %d  Fish: %d  Sharks: %d  Orcas: %d  Elapsed: %v  Chronons/sec: %.1f\n",
+                    chronon, fish, sharks, orcas, now.Sub(start).Round(time.Millisecond), rate)
+                lastStats = now
+            }
+
+            // the same stop rules as a single-process run
+            if c, ok := cfg.StopIf.FirstMet(fish, sharks); ok {
+                fmt.Printf("Stopping at chronon %d: %s\n", chronon, c)
+                stop = true
+            }
+            if cfg.MaxTime > 0 && time.Since(start) >= cfg.MaxTime {
+                fmt.Printf("Time limit of %v reached at chronon %d\n", cfg.MaxTime, chronon)
+                stop = true
+            }
+            stop = stop || fish == 0 || sharks+orcas == 0 || (cfg.Chronons > 0 && chronon >= cfg.Chronons)
+        }
+
+        if stop {
+            for i := range encs {
+                if err := encs[i].Encode(clusterHalo{Stop: true}); err != nil {
+                    return RunResult{}, fmt.Errorf("worker %d: %v", i, err)
+                }
+            }
+            result := RunResult{Chronons: chronon, Fish: fish, Sharks: sharks, Elapsed: time.Since(start)}
+            fmt.Printf("Workers: %d  Time: %v\n", n, result.Elapsed)
+            fmt.Printf("Chronons: %d  Fish: %d  Sharks: %d  Orcas: %d  Seed: %d\n", chronon, fish, sharks, orcas, cfg.Seed)
+            return result, nil
+        }
+
+        // Bands wrap around, so the first band borders the last
+        for i := range encs {
+            halo := clusterHalo{Above: edges[(i+n-1)%n].Bottom, Below: edges[(i+1)%n].Top}
+            if err := encs[i].Encode(halo); err != nil {
+                return RunResult{}, fmt.Errorf("worker %d: %v", i, err)
+            }
+        }
+        for i := range decs {
+            migrants[i] = clusterMigrants{}
+            if err := decs[i].Decode(&migrants[i]); err != nil {
+                return RunResult{}, fmt.Errorf("worker %d: %v", i, err)
+            }
+        }
+        for i := range encs {
+            arrivals := clusterArrivals{FromAbove: migrants[(i+n-1)%n].Down, FromBelow: migrants[(i+1)%n].Up}
+            if err := encs[i].Encode(arrivals); err != nil {
+                return RunResult{}, fmt.Errorf("worker %d: %v", i, err)
+            }
+        }
+    }
+}
+
+//  @brief Checks that cfg can be split across the given number of workers
+//  Bands of more than one worker need 2 rows so that a band's two halo rows are distinct
+func checkClusterConfig(cfg Config, workers int) error {
+    switch {
+    case workers < 1:
+        return fmt.Errorf("the number of workers must be 1 or greater")
+    case workers > 1 && cfg.GridSize < 2*workers:
+        return fmt.Errorf("GridSize must be at least twice the number of workers, so every band has 2 rows")
+    case cfg.Topology != TopologyTorus:
+        return fmt.Errorf("worker processes only support the torus topology")
+    case cfg.Depth > 1:
+        return fmt.Errorf("worker processes only support a flat ocean of one depth layer")
+    case cfg.FoodWeb != nil || !cfg.FishRegion.IsZero() || !cfg.SharkRegion.IsZero() || cfg.Resume != "":
+        return fmt.Errorf("-species, -fish-region, -shark-region and -resume are not supported with worker processes")
+    case len(cfg.Behaviors) > 0 || len(cfg.Plugins) > 0:
+        return fmt.Errorf("-behavior and -plugin are not supported with worker processes")
+    case cfg.EntityRNG:
+        return fmt.Errorf("-entity-rng is not supported with worker processes")
+    }
+    return nil
+}
+
+//  @brief Entry point of the cluster subcommand: accepts the workers, then coordinates the run
+func runCluster(args []string) {
+    fs := flag.NewFlagSet("cluster", flag.ExitOnError)
+    listenFlag := fs.String("listen", ":7070", "Address the coordinator accepts workers on")
+    workersFlag := fs.Int("workers", 2, "Number of workers to wait for, each simulating one band of rows")
+    localFlag := fs.Bool("local", false, "Start the workers in this process instead of waiting for remote ones")
+    spawnFlag := fs.Bool("spawn", false, "Start the workers as child processes connected by pipes (see multiproc.go)")
+    cfg := parseConfig(fs, args, false)
+
+    if err := checkClusterConfig(cfg, *workersFlag); err != nil {
+        fmt.Printf("Error: %v.\n", err)
+        os.Exit(1)
+    }
+
+    fmt.Printf("Loaded configuration: %+v\n", cfg)
+    fmt.Printf("Seed: %d\n", cfg.Seed)
+
+    if *spawnFlag {
+        if _, err := RunProcesses(cfg, *workersFlag); err != nil {
+            fmt.Printf("Error: %v\n", err)
+            os.Exit(1)
+        }
+        return
+    }
+
+    ln, err := net.Listen("tcp", *listenFlag)
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+    defer ln.Close()
+
+    if *localFlag {
+        for i := 0; i < *workersFlag; i++ {
+            go func() {
+                conn, err := net.Dial("tcp", ln.Addr().String())
+                if err == nil {
+                    defer conn.Close()
+                    err = runClusterWorker(conn)
+                }
+                if err != nil {
+                    fmt.Printf("Local worker failed: %v\n", err)
+                }
+            }()
+        }
+    } else {
+        fmt.Printf("Waiting for %d workers on %s\n", *workersFlag, ln.Addr())
+    }
+
+    conns := make([]io.ReadWriter, 0, *workersFlag)
+    for len(conns) < *workersFlag {
+        conn, err := ln.Accept()
+        if err != nil {
+            fmt.Printf("Error: %v\n", err)
+            os.Exit(1)
+        }
+        defer conn.Close()
+        conns = append(conns, conn)
+    }
+
+    if _, err := coordinateCluster(cfg, conns); err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+}
+
+//  @brief Entry point of the cluster-worker subcommand: joins a coordinator and simulates the band it is given
+func runClusterWorkerCommand(args []string) {
+    fs := flag.NewFlagSet("cluster-worker", flag.ExitOnError)
+    joinFlag := fs.String("join", "localhost:7070", "Address of the coordinator")
+    stdioFlag := fs.Bool("stdio", false, "Talk to the coordinator over stdin and stdout, as a worker spawned by -spawn")
+    fs.Parse(args)
+
+    // stdout carries the protocol, so errors go to stderr
+    if *stdioFlag {
+        if err := runClusterWorker(stdio{}); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            os.Exit(1)
+        }
+        return
+    }
+
+    conn, err := net.Dial("tcp", *joinFlag)
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+    defer conn.Close()
+    if err := runClusterWorker(conn); err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+}
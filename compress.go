@@ -0,0 +1,145 @@
+package main
+
+import (
+    "compress/gzip"
+    "io"
+    "os"
+    "strings"
+
+    "github.com/klauspost/compress/zstd"
+)
+
+/**
+    @file compress.go
+    @brief Transparent gzip and zstd compression of output files
+    A snapshot, frame dump, stats, blocks, benchmark, sweep or lifetimes file
+    whose name ends in .gz or .zst is compressed while it is written, and
+    snapshots and sweep files with those names are decompressed when read.
+    Files that grow row by row (see csvfile.go) get one compressed member or
+    frame per append; both formats define a file of several as the
+    concatenation of their contents, so zcat and zstdcat read them as one, e.g.
+
+        wa-tor -headless -dump-frames frames.jsonl.zst -chronons 500 300 2000 3 8 5 100 4
+        wa-tor -headless -stats-every 10 -stats-csv stats.csv.gz -chronons 500 300 2000 3 8 5 100 4
+*/
+
+//  @brief Compression formats chosen by file name
+const (
+    compressNone = ""
+    compressGzip = ".gz"
+    compressZstd = ".zst"
+)
+
+//  @brief Returns the compression format a file name asks for
+func compressionOf(path string) string {
+    switch {
+    case strings.HasSuffix(path, compressGzip):
+        return compressGzip
+    case strings.HasSuffix(path, compressZstd):
+        return compressZstd
+    }
+    return compressNone
+}
+
+//  @brief Wraps w in a compressor for the format path asks for; closing the result finishes the stream but not w
+func compressWriter(path string, w io.Writer) (io.WriteCloser, error) {
+    switch compressionOf(path) {
+    case compressGzip:
+        return gzip.NewWriter(w), nil
+    case compressZstd:
+        return zstd.NewWriter(w)
+    }
+    return nopWriteCloser{w}, nil
+}
+
+//  @brief nopWriteCloser leaves an uncompressed stream open on Close
+type nopWriteCloser struct {
+    io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+    return nil
+}
+
+//  @brief compressedFile is an output file behind its compressor
+type compressedFile struct {
+    io.WriteCloser
+    file *os.File
+}
+
+//  @brief Finishes the compressed stream, then closes the file
+func (c compressedFile) Close() error {
+    if err := c.WriteCloser.Close(); err != nil {
+        c.file.Close()
+        return err
+    }
+    return c.file.Close()
+}
+
+//  @brief Creates (or truncates) the file at path, compressed when its name ends in .gz or .zst
+func CreateOutput(path string) (io.WriteCloser, error) {
+    f, err := os.Create(path)
+    if err != nil {
+        return nil, err
+    }
+    w, err := compressWriter(path, f)
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    return compressedFile{w, f}, nil
+}
+
+//  @brief Writes data to the file at path, compressed when its name ends in .gz or .zst
+func writeOutput(path string, data []byte) error {
+    w, err := CreateOutput(path)
+    if err != nil {
+        return err
+    }
+    if _, err := w.Write(data); err != nil {
+        w.Close()
+        return err
+    }
+    return w.Close()
+}
+
+//  @brief decompressedFile is an input file behind its decompressor
+type decompressedFile struct {
+    io.Reader
+    close func()
+    file  *os.File
+}
+
+//  @brief Releases the decompressor and closes the file
+func (d decompressedFile) Close() error {
+    if d.close != nil {
+        d.close()
+    }
+    return d.file.Close()
+}
+
+//  @brief Opens the file at path, decompressing it when its name ends in .gz or .zst
+func OpenInput(path string) (io.ReadCloser, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+
+    switch compressionOf(path) {
+    case compressGzip:
+        r, err := gzip.NewReader(f)
+        if err != nil {
+            f.Close()
+            return nil, err
+        }
+        return decompressedFile{Reader: r, close: func() { r.Close() }, file: f}, nil
+    case compressZstd:
+        r, err := zstd.NewReader(f)
+        if err != nil {
+            f.Close()
+            return nil, err
+        }
+        return decompressedFile{Reader: r, close: r.Close, file: f}, nil
+    }
+    return f, nil
+}
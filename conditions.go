@@ -0,0 +1,133 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+/**
+    @file conditions.go
+    @brief Population threshold conditions such as "fish<100" or "sharks>5000"
+    Conditions are given on the command line and evaluated every chronon
+    against the current population counts.
+*/
+
+//  @brief Condition compares the population of one species against a threshold
+type Condition struct {
+    Species Entity //  Fish or Shark
+    Op      string //  One of <, <=, >, >=, ==
+    Value   int    //  Threshold the population is compared against
+}
+
+//  Operators in the order they are matched, two-character operators first
+var conditionOps = []string{"<=", ">=", "==", "<", ">"}
+
+//  @brief Parses a condition of the form <species><op><value>, e.g. "fish<100"
+func ParseCondition(s string) (Condition, error) {
+    text := strings.ReplaceAll(s, " ", "")
+
+    for _, op := range conditionOps {
+        i := strings.Index(text, op)
+        if i < 0 {
+            continue
+        }
+
+        var species Entity
+        switch strings.ToLower(text[:i]) {
+        case "fish":
+            species = Fish
+        case "shark", "sharks":
+            species = Shark
+        default:
+            return Condition{}, fmt.Errorf("unknown species %q in condition %q", text[:i], s)
+        }
+
+        value, err := strconv.Atoi(text[i+len(op):])
+        if err != nil {
+            return Condition{}, fmt.Errorf("threshold in condition %q must be an integer", s)
+        }
+
+        return Condition{Species: species, Op: op, Value: value}, nil
+    }
+
+    return Condition{}, fmt.Errorf("condition %q needs one of the operators < <= > >= ==", s)
+}
+
+//  @brief Reports whether the condition holds for the given population counts
+func (c Condition) Met(fish, sharks int) bool {
+    count := fish
+    if c.Species == Shark {
+        count = sharks
+    }
+
+    switch c.Op {
+    case "<":
+        return count < c.Value
+    case "<=":
+        return count <= c.Value
+    case ">":
+        return count > c.Value
+    case ">=":
+        return count >= c.Value
+    case "==":
+        return count == c.Value
+    }
+    return false
+}
+
+//  @brief Formats the condition the way it is written on the command line
+func (c Condition) String() string {
+    name := "fish"
+    if c.Species == Shark {
+        name = "sharks"
+    }
+    return name + c.Op + strconv.Itoa(c.Value)
+}
+
+//  @brief Writes the condition as on the command line, the form used in configuration files (encoding.TextMarshaler)
+func (c Condition) MarshalText() ([]byte, error) {
+    return []byte(c.String()), nil
+}
+
+//  @brief Parses a condition written as on the command line (encoding.TextUnmarshaler)
+func (c *Condition) UnmarshalText(text []byte) error {
+    parsed, err := ParseCondition(string(text))
+    if err != nil {
+        return err
+    }
+    *c = parsed
+    return nil
+}
+
+//  @brief Conditions is a repeatable command-line flag holding several conditions
+type Conditions []Condition
+
+//  @brief Formats all conditions as a comma separated list (flag.Value)
+func (cs *Conditions) String() string {
+    parts := make([]string, len(*cs))
+    for i, c := range *cs {
+        parts[i] = c.String()
+    }
+    return strings.Join(parts, ",")
+}
+
+//  @brief Parses and adds one condition each time the flag is given (flag.Value)
+func (cs *Conditions) Set(s string) error {
+    c, err := ParseCondition(s)
+    if err != nil {
+        return err
+    }
+    *cs = append(*cs, c)
+    return nil
+}
+
+//  @brief Returns the first condition that holds for the given counts
+func (cs Conditions) FirstMet(fish, sharks int) (Condition, bool) {
+    for _, c := range cs {
+        if c.Met(fish, sharks) {
+            return c, true
+        }
+    }
+    return Condition{}, false
+}
@@ -1,21 +1,552 @@
 package main
 
+import (
+    "cmp"
+    _ "embed"
+    "encoding/json"
+    "fmt"
+    "os"
+    "slices"
+    "strings"
+    "time"
+)
+
 /**
 	@file config.go
 	@brief Configuration structure for the Wa-Tor simulation
+	A Config can also be written as JSON, so other tools (sweep generators,
+	web UIs) can build configurations without going through the command
+	line. Every field is optional and falls back to the default of the
+	matching flag; config.schema.json documents the format, maxTime and
+	chrononDuration are durations such as "10m", regions are "row0,col0,row1,col1" and stop
+	conditions are written as on the command line, e.g.
+
+	    {"numShark": 300, "numFish": 2000, "gridSize": 200, "threads": 4,
+	     "chronons": 500, "stopIf": ["sharks<10"], "maxTime": "1m"}
+
+	The config subcommand prints the schema and the defaults, checks
+	configuration files and runs them; watch also applies later changes to
+	their tunable parameters, see reload.go.
  */
 
 //	@brief Holds all user-configurable parameters for the simulation
 type Config struct {
-    NumShark   int
-    NumFish    int
-    FishBreed  int
-    SharkBreed int
-    Starve     int
-    GridSize   int
-    Threads    int
-
-    Chronons   int
-    DrawEvery  int
-    BenchFile  string
+    NumShark   int `json:"numShark" yaml:"numShark"`
+    NumFish    int `json:"numFish" yaml:"numFish"`
+    FishBreed  int `json:"fishBreed" yaml:"fishBreed"`
+    SharkBreed int `json:"sharkBreed" yaml:"sharkBreed"`
+    Starve     int `json:"starve" yaml:"starve"`
+    GridSize   int `json:"gridSize" yaml:"gridSize"`
+    Threads    int `json:"threads" yaml:"threads"`
+
+    Chronons        int           `json:"chronons" yaml:"chronons"`
+    DrawEvery       int           `json:"drawEvery" yaml:"drawEvery"`
+    BenchFile       string        `json:"benchFile,omitempty" yaml:"benchFile,omitempty"`
+    Headless        bool          `json:"headless" yaml:"headless"`                       //  No per-chronon terminal output, only the final summary
+    DryRun          bool          `json:"dryRun" yaml:"dryRun"`                           //  Print the settings that follow from the configuration instead of running it, see dryrun.go
+    Pane            string        `json:"pane" yaml:"pane"`                               //  Stats pane when redrawing on a terminal: side, bottom or none, see pane.go
+    Overlay         string        `json:"overlay,omitempty" yaml:"overlay,omitempty"`     //  Timers drawn in place of the glyphs: starve, breed or both, see overlay.go (optional)
+    Mouse           bool          `json:"mouse" yaml:"mouse"`                             //  Read keys and mouse clicks from the terminal to edit a paused run, see mouse.go
+    Reload          string        `json:"reload,omitempty" yaml:"reload,omitempty"`       //  JSON configuration the tunable parameters are reloaded from when it changes, see reload.go (optional)
+    StatsEvery      int           `json:"statsEvery" yaml:"statsEvery"`                   //  Print a one-line population and events summary every N chronons (0 = never)
+    StatsCSV        string        `json:"statsCsv,omitempty" yaml:"statsCsv,omitempty"`   //  CSV file a row of populations, events and spatial entropies is appended to with each summary (optional)
+    StopIf          Conditions    `json:"stopIf,omitempty" yaml:"stopIf,omitempty"`       //  End the run as soon as any of these holds
+    PauseOn         Conditions    `json:"pauseOn,omitempty" yaml:"pauseOn,omitempty"`     //  Pause the console as soon as any of these starts to hold
+    AlertOn         Conditions    `json:"alertOn,omitempty" yaml:"alertOn,omitempty"`     //  Raise an alarm as any of these starts to hold, see alert.go
+    BreakAt         Breakpoints   `json:"breakAt,omitempty" yaml:"breakAt,omitempty"`     //  Chronons the console pauses at, or a snapshot is written at without one, see breakpoints.go
+    MaxTime         time.Duration `json:"maxTime" yaml:"maxTime"`                         //  Wall-clock limit for the run (0 = no limit)
+    ChrononDuration time.Duration `json:"chrononDuration" yaml:"chrononDuration"`         //  Simulated length of a chronon the run is paced to (0 = as fast as possible), see pace.go
+    Snapshot        string        `json:"snapshot,omitempty" yaml:"snapshot,omitempty"`   //  File the final world is written to (optional)
+    BenchReps       int           `json:"benchReps" yaml:"benchReps"`                     //  Number of repetitions of a benchmark run
+    Seed            int64         `json:"seed" yaml:"seed"`                               //  Random seed, always set by parseConfig (0 in a file = seed from the clock)
+    BenchWarmup     int           `json:"benchWarmup" yaml:"benchWarmup"`                 //  Untimed chronons run before measurement starts
+    Diag            bool          `json:"diag,omitempty" yaml:"diag,omitempty"`           //  Profile contention and scheduling during the run and print a digest, see diag.go
+    AutoTune        int           `json:"autoTune,omitempty" yaml:"autoTune,omitempty"`   //  Chronons at the start spent trying worker counts to keep the fastest (0 = off), see autotune.go
+    Batch           int           `json:"batch" yaml:"batch"`                             //  Chronons every worker steps on its own between synchronisation points, see batch.go
+    Strategy        string        `json:"strategy" yaml:"strategy"`                       //  How the bands of the workers are run: threaded or serial, see strategy.go
+    RNG             string        `json:"rng" yaml:"rng"`                                 //  Random number generator: stdlib, pcg or xorshift
+    EntityRNG       bool          `json:"entityRng,omitempty" yaml:"entityRng,omitempty"` //  Draw each creature's choices from a stream of its own, see entityrng.go
+
+    Checkpoint      string `json:"checkpoint,omitempty" yaml:"checkpoint,omitempty"`   //  File checkpoints are written to (optional)
+    CheckpointEvery int    `json:"checkpointEvery" yaml:"checkpointEvery"`             //  Write a checkpoint every N chronons (0 = only at the end)
+    Resume          string `json:"resume,omitempty" yaml:"resume,omitempty"`           //  Checkpoint file to continue from (optional)
+    Console         string `json:"console,omitempty" yaml:"console,omitempty"`         //  Read console commands from standard input ("-") or this Unix socket (optional)
+    Control         string `json:"control,omitempty" yaml:"control,omitempty"`         //  Unix socket accepting the control commands pause, resume, snapshot, stats and quit (optional)
+    HTTP            string `json:"http,omitempty" yaml:"http,omitempty"`               //  Address expvar counters are served on at /debug/vars (optional)
+    OTLP            string `json:"otlp,omitempty" yaml:"otlp,omitempty"`               //  OpenTelemetry collector chronon phases are traced to, e.g. http://localhost:4318 (optional)
+    TraceEvery      int    `json:"traceEvery" yaml:"traceEvery"`                       //  Trace every Nth chronon when tracing
+    AlertURL        string `json:"alertUrl,omitempty" yaml:"alertUrl,omitempty"`       //  Webhook the population alarms are posted to as JSON, see alert.go (optional)
+    HashEvery       int    `json:"hashEvery" yaml:"hashEvery"`                         //  Print World.Hash every N chronons and at the end (0 = never)
+    DebugChecks     string `json:"debugChecks,omitempty" yaml:"debugChecks,omitempty"` //  Invariants checked after each chronon: "all" or a list such as "energy,timers" (optional)
+    EnergyLedger    bool   `json:"energyLedger" yaml:"energyLedger"`                   //  Balance the energy flows of every chronon against the energy of the world, see energy.go
+    Deaths          bool   `json:"deaths" yaml:"deaths"`                               //  Print the deaths of each cause at the end of the run, see mortality.go
+    DeathsEvery     int    `json:"deathsEvery" yaml:"deathsEvery"`                     //  Also print the deaths since the last such line every N chronons (0 = never)
+    EncountersEvery int    `json:"encountersEvery" yaml:"encountersEvery"`             //  Print the predation rate and encounter densities every N chronons (0 = never), see encounters.go
+    Lag             bool   `json:"lag" yaml:"lag"`                                     //  Print how many chronons shark peaks trail fish peaks at the end of the run, see lag.go
+    Lifetimes       string `json:"lifetimes,omitempty" yaml:"lifetimes,omitempty"`     //  CSV file the distribution of lifetimes and offspring counts is written to (optional)
+
+    Blocks      BlockGrid `json:"blocks,omitzero" yaml:"blocks,omitzero"`         //  Block grid the per-block counts are reported for (zero = no per-block counts)
+    BlocksEvery int       `json:"blocksEvery" yaml:"blocksEvery"`                 //  Report the per-block counts every N chronons
+    BlocksCSV   string    `json:"blocksCsv,omitempty" yaml:"blocksCsv,omitempty"` //  CSV file the per-block counts are appended to instead of being printed (optional)
+
+    DumpFrames string `json:"dumpFrames,omitempty" yaml:"dumpFrames,omitempty"` //  JSON Lines file the grid of every sampled chronon is written to, see frames.go (optional)
+    DumpEvery  int    `json:"dumpEvery" yaml:"dumpEvery"`                       //  Write a frame every N chronons
+
+    RotateEvery int      `json:"rotateEvery" yaml:"rotateEvery"`                 //  Start new stats, blocks and frame files every N chronons (0 = never), see rotate.go
+    RotateSize  ByteSize `json:"rotateSize,omitzero" yaml:"rotateSize,omitzero"` //  Start new stats, blocks and frame files once they reach this size (0 = no limit)
+    RotateKeep  int      `json:"rotateKeep" yaml:"rotateKeep"`                   //  Number of rotated files of each output kept (0 = all)
+    OutputQueue int      `json:"outputQueue" yaml:"outputQueue"`                 //  Output jobs queued behind the step loop before it waits (0 = write in the step loop), see pipeline.go
+
+    CrashDump   string `json:"crashDump,omitempty" yaml:"crashDump,omitempty"` //  JSON Lines file the last frames are written to when a species dies out or stopIf holds, see crashring.go (optional)
+    CrashFrames int    `json:"crashFrames" yaml:"crashFrames"`                 //  Number of frames kept for crashDump
+    Rewind      int    `json:"rewind" yaml:"rewind"`                           //  Number of frames kept for the console to rewind through (0 = no rewinding)
+
+    Timelapse       string `json:"timelapse,omitempty" yaml:"timelapse,omitempty"` //  GIF file a condensed animation of the run is written to, see timelapse.go (optional)
+    TimelapseStride int    `json:"timelapseStride" yaml:"timelapseStride"`         //  Add a timelapse frame every N chronons
+    TimelapseScale  int    `json:"timelapseScale" yaml:"timelapseScale"`           //  Pixels per cell in the timelapse
+    Cast            string `json:"cast,omitempty" yaml:"cast,omitempty"`           //  asciinema v2 file the terminal drawing is recorded to, see cast.go (optional)
+    Report          string `json:"report,omitempty" yaml:"report,omitempty"`       //  HTML file a self-contained report of the run is written to at the end, see report.go (optional)
+
+    FishRegion  Region `json:"fishRegion,omitzero" yaml:"fishRegion,omitzero"`   //  Rectangle fish are initially placed in (zero = whole grid)
+    SharkRegion Region `json:"sharkRegion,omitzero" yaml:"sharkRegion,omitzero"` //  Rectangle sharks are initially placed in (zero = whole grid)
+
+    NumOrca      int  `json:"numOrca" yaml:"numOrca"`           //  Orcas placed at the start (0 = no third trophic level)
+    OrcaBreed    int  `json:"orcaBreed" yaml:"orcaBreed"`       //  Chronons between orca reproductions
+    OrcaStarve   int  `json:"orcaStarve" yaml:"orcaStarve"`     //  Maximum orca energy, restored by every meal
+    OrcaEatsFish bool `json:"orcaEatsFish" yaml:"orcaEatsFish"` //  Orcas also eat fish when no shark is adjacent
+
+    FishMature     int `json:"fishMature" yaml:"fishMature"`         //  Chronons a newborn fish stays juvenile before it can breed (0 = no juveniles)
+    JuvenileEnergy int `json:"juvenileEnergy" yaml:"juvenileEnergy"` //  Energy a shark gains from eating a juvenile fish
+
+    FishEggs    int `json:"fishEggs" yaml:"fishEggs"`       //  Chronons the egg a fish lays takes to hatch, see eggs.go (0 = live young, no eggs)
+    SpawnSeason int `json:"spawnSeason" yaml:"spawnSeason"` //  Chronons at the start of every spawnYear that fish breed in (0 = all year)
+    SpawnYear   int `json:"spawnYear" yaml:"spawnYear"`     //  Chronons of a year of spawning seasons
+    chronon     int //  Chronon being stepped, counted from 1, set for every step for the seasons
+
+    Gestation     int `json:"gestation" yaml:"gestation"`         //  Chronons a shark is pregnant before giving birth (0 = give birth at once)
+    GestationCost int `json:"gestationCost" yaml:"gestationCost"` //  Extra energy a pregnant shark uses each chronon
+
+    WeakEnergy int    `json:"weakEnergy" yaml:"weakEnergy"` //  Sharks with less energy than this are weak (0 = never weak)
+    WeakMode   string `json:"weakMode" yaml:"weakMode"`     //  How weakness shows: "skip" (rest every other chronon) or "yield" (lose contested cells)
+
+    CannibalEnergy int `json:"cannibalEnergy" yaml:"cannibalEnergy"` //  Sharks with less energy than this attack neighbouring sharks when no fish is adjacent (0 = never)
+    CannibalGain   int `json:"cannibalGain" yaml:"cannibalGain"`     //  Energy a shark gains from eating another shark
+
+    TerritoryRadius int `json:"territory" yaml:"territory"`           //  Cells around its anchor a shark's moves keep to, see territory.go (0 = no territories)
+    TerritoryDrift  int `json:"territoryDrift" yaml:"territoryDrift"` //  An anchor moves one cell towards its shark with chance 1 in N each chronon (0 = anchors stay)
+
+    CorpseDecay  int `json:"corpseDecay" yaml:"corpseDecay"`   //  Chronons a starved predator's corpse remains (0 = no corpses)
+    CorpseEnergy int `json:"corpseEnergy" yaml:"corpseEnergy"` //  Energy a scavenger gains from eating a corpse
+
+    Topology Topology `json:"topology" yaml:"topology"` //  How the grid's edges connect, torus by default
+
+    Depth      int `json:"depth" yaml:"depth"`           //  Depth layers of the ocean (0 or 1 = a flat ocean)
+    FishDepth  int `json:"fishDepth" yaml:"fishDepth"`   //  Layer fish drift towards, 1 being the surface (0 = no preference)
+    SharkDepth int `json:"sharkDepth" yaml:"sharkDepth"` //  Layer sharks drift towards when not hunting (0 = no preference)
+
+    FoodWeb *FoodWeb `json:"foodWeb,omitempty" yaml:"foodWeb,omitempty"` //  Species loaded from a species file, replacing fish and sharks (optional)
+
+    Explain Region     `json:"explain,omitzero" yaml:"explain,omitzero"` //  Cells whose creatures log their decisions every chronon, see explain.go (zero = none)
+    explain *Explainer //  Collects those decisions while stepping, set by NewSimulator and the console
+
+    Behaviors   []string            `json:"behaviors,omitempty" yaml:"behaviors,omitempty"`     //  Names of the behaviors picking the moves of their species, see behavior.go (optional)
+    Plugins     []string            `json:"plugins,omitempty" yaml:"plugins,omitempty"`         //  Go plugins registering further behaviors (optional)
+    Temperature Temperatures        `json:"temperature,omitempty" yaml:"temperature,omitempty"` //  Chance, by species, that a move is random instead of the behavior's pick, see temperature.go (optional)
+    behaviors   map[Entity]PickFunc //  The pick function of each species with a behavior, set by NewSimulator
+
+    Follow    string `json:"follow,omitempty" yaml:"follow,omitempty"` //  Creature whose history is written to followCsv: its number or the ROW,COL it starts in, see follow.go (optional)
+    FollowCSV string `json:"followCsv" yaml:"followCsv"`               //  CSV file the followed creature's history is written to
+}
+
+//  @brief JSON Schema of configuration files, printed by "config schema"
+//go:embed config.schema.json
+var configSchema string
+
+//  @brief Returns the configuration a run has when no flag is given
+//  Kept in step with the flag defaults in parseConfig. The positional parameters
+//  (populations, breed and starve times, GridSize) have no default and are left 0,
+//  apart from Threads, which subcommands without it also use 1 for.
+func DefaultConfig() Config {
+    return Config{
+        Threads:         1,
+        DrawEvery:       1,
+        BenchReps:       1,
+        Batch:           1,
+        Strategy:        strategyThreaded,
+        RNG:             "stdlib",
+        TraceEvery:      1,
+        JuvenileEnergy:  2,
+        SpawnYear:       100,
+        GestationCost:   1,
+        WeakMode:        weakSkip,
+        CannibalGain:    2,
+        TerritoryDrift:  20,
+        CorpseEnergy:    2,
+        Topology:        TopologyTorus,
+        Depth:           1,
+        BlocksEvery:     1,
+        DumpEvery:       1,
+        OutputQueue:     16,
+        CrashFrames:     100,
+        TimelapseStride: 50,
+        TimelapseScale:  4,
+        Pane:            paneSide,
+        FollowCSV:       "follow.csv",
+    }
+}
+
+//  @brief Orca parameters used when orcas are added without them, as the -orca-breed and -orca-starve defaults
+const (
+    defaultOrcaBreed  = 10
+    defaultOrcaStarve = 8
+)
+
+//  @brief JSON form of a Config: the same fields, with the time limit and chronon duration written as duration strings
+type configJSON struct {
+    configFields
+    MaxTime         string `json:"maxTime,omitempty"`
+    ChrononDuration string `json:"chrononDuration,omitempty"`
+}
+
+//  @brief Config without its methods, so configJSON does not recurse into them
+type configFields Config
+
+//  @brief Encodes the configuration as JSON, the time limit and chronon duration written like "10m" (json.Marshaler)
+func (cfg Config) MarshalJSON() ([]byte, error) {
+    out := configJSON{configFields: configFields(cfg)}
+    if cfg.MaxTime > 0 {
+        out.MaxTime = cfg.MaxTime.String()
+    }
+    if cfg.ChrononDuration > 0 {
+        out.ChrononDuration = cfg.ChrononDuration.String()
+    }
+    return json.Marshal(out)
+}
+
+//  @brief Decodes a configuration over the fields already set in cfg (json.Unmarshaler)
+func (cfg *Config) UnmarshalJSON(data []byte) error {
+    in := configJSON{configFields: configFields(*cfg)}
+    if err := json.Unmarshal(data, &in); err != nil {
+        return err
+    }
+    *cfg = Config(in.configFields)
+    if in.MaxTime != "" {
+        d, err := time.ParseDuration(in.MaxTime)
+        if err != nil {
+            return fmt.Errorf("maxTime: %v", err)
+        }
+        cfg.MaxTime = d
+    }
+    if in.ChrononDuration != "" {
+        d, err := time.ParseDuration(in.ChrononDuration)
+        if err != nil {
+            return fmt.Errorf("chrononDuration: %v", err)
+        }
+        cfg.ChrononDuration = d
+    }
+    return nil
+}
+
+//  @brief Encodes cfg as indented JSON
+func MarshalConfig(cfg Config) ([]byte, error) {
+    return json.MarshalIndent(cfg, "", "  ")
+}
+
+//  @brief Decodes a JSON configuration, filling every missing field from DefaultConfig, and validates it
+//  A food web in the configuration is resolved but not activated, see FoodWeb.Activate
+func UnmarshalConfig(data []byte) (Config, error) {
+    cfg := DefaultConfig()
+    if err := json.Unmarshal(data, &cfg); err != nil {
+        return Config{}, err
+    }
+    // As on the command line, orca parameters only exist when there are orcas
+    if cfg.NumOrca > 0 {
+        cfg.OrcaBreed = cmp.Or(cfg.OrcaBreed, defaultOrcaBreed)
+        cfg.OrcaStarve = cmp.Or(cfg.OrcaStarve, defaultOrcaStarve)
+    } else {
+        cfg.OrcaBreed, cfg.OrcaStarve, cfg.OrcaEatsFish = 0, 0, false
+    }
+    if cfg.FoodWeb != nil {
+        if err := cfg.FoodWeb.resolve(); err != nil {
+            return Config{}, fmt.Errorf("foodWeb: %v", err)
+        }
+    }
+    if err := cfg.Validate(); err != nil {
+        return Config{}, err
+    }
+    return cfg, nil
+}
+
+//  @brief Reads and validates a JSON configuration file
+func LoadConfig(path string) (Config, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return Config{}, err
+    }
+    cfg, err := UnmarshalConfig(data)
+    if err != nil {
+        return Config{}, fmt.Errorf("configuration %s: %v", path, err)
+    }
+    return cfg, nil
+}
+
+//  @brief ConfigError is a rule of Config.Validate that a configuration breaks
+//  The fields the rule is about appear in its format as {name}, by their JSON names, so the
+//  command line can name them by its flags instead (see parseConfig)
+type ConfigError struct {
+    format string
+    args   []any
+}
+
+//  @brief Returns the error of a broken rule, formatted as by fmt.Sprintf once its {fields} are named
+func configErrorf(format string, args ...any) *ConfigError {
+    return &ConfigError{format: format, args: args}
+}
+
+//  @brief Returns the message with every field named by its JSON name
+func (e *ConfigError) Error() string {
+    return e.Named(func(field string) string { return field })
+}
+
+//  @brief Returns the message with every field named by name
+func (e *ConfigError) Named(name func(field string) string) string {
+    var format strings.Builder
+    rest := e.format
+    for {
+        before, after, ok := strings.Cut(rest, "{")
+        field, tail, closed := strings.Cut(after, "}")
+        if !ok || !closed {
+            format.WriteString(rest)
+            break
+        }
+        format.WriteString(before)
+        format.WriteString(strings.ReplaceAll(name(field), "%", "%%"))
+        rest = tail
+    }
+    return fmt.Sprintf(format.String(), e.args...)
+}
+
+//  @brief configField is a numeric field of the configuration, by its JSON name, checked by atLeast and positive
+type configField struct {
+    name  string
+    value int64
+}
+
+//  @brief Returns the field called name holding value
+func field[T ~int | ~int64](name string, value T) configField {
+    return configField{name: name, value: int64(value)}
+}
+
+//  @brief Returns the error naming those of the fields below least, e.g. "{numFish} must be 0 or greater", or nil when there are none
+func atLeast(least int64, fields ...configField) error {
+    return brokenBy(fmt.Sprintf("must be %d or greater", least), func(v int64) bool { return v < least }, fields)
+}
+
+//  @brief Returns the error naming those of the fields that are 0 or less, e.g. "{starve} must be greater than 0", or nil when there are none
+func positive(fields ...configField) error {
+    return brokenBy("must be greater than 0", func(v int64) bool { return v <= 0 }, fields)
+}
+
+//  @brief Returns the error of rule naming the fields whose values are bad, or nil when there are none
+func brokenBy(rule string, bad func(v int64) bool, fields []configField) error {
+    var names []string
+    for _, f := range fields {
+        if bad(f.value) {
+            names = append(names, "{"+f.name+"}")
+        }
+    }
+    if len(names) == 0 {
+        return nil
+    }
+    list := names[0]
+    if n := len(names); n > 1 {
+        list = strings.Join(names[:n-1], ", ") + " and " + names[n-1]
+    }
+    return configErrorf(list + " " + rule)
+}
+
+//  @brief Checks the rules of a configuration, read from a file or from the command line by parseConfig
+//  The errors of broken rules are ConfigErrors, naming fields as they are written in JSON
+func (cfg Config) Validate() error {
+    for _, err := range []error{
+        atLeast(0, field("numShark", cfg.NumShark), field("numFish", cfg.NumFish), field("numOrca", cfg.NumOrca)),
+        positive(field("fishBreed", cfg.FishBreed), field("sharkBreed", cfg.SharkBreed), field("starve", cfg.Starve)),
+        atLeast(1, field("threads", cfg.Threads), field("depth", cfg.Depth), field("benchReps", cfg.BenchReps), field("batch", cfg.Batch),
+            field("traceEvery", cfg.TraceEvery), field("blocksEvery", cfg.BlocksEvery), field("dumpEvery", cfg.DumpEvery), field("crashFrames", cfg.CrashFrames),
+            field("timelapseStride", cfg.TimelapseStride), field("timelapseScale", cfg.TimelapseScale)),
+        atLeast(0, field("corpseDecay", cfg.CorpseDecay), field("corpseEnergy", cfg.CorpseEnergy), field("fishMature", cfg.FishMature), field("juvenileEnergy", cfg.JuvenileEnergy),
+            field("gestation", cfg.Gestation), field("gestationCost", cfg.GestationCost), field("weakEnergy", cfg.WeakEnergy), field("cannibalEnergy", cfg.CannibalEnergy), field("cannibalGain", cfg.CannibalGain),
+            field("territory", cfg.TerritoryRadius), field("territoryDrift", cfg.TerritoryDrift), field("fishEggs", cfg.FishEggs), field("spawnSeason", cfg.SpawnSeason)),
+        atLeast(1, field("spawnYear", cfg.SpawnYear)),
+        atLeast(0, field("statsEvery", cfg.StatsEvery), field("maxTime", cfg.MaxTime), field("chrononDuration", cfg.ChrononDuration), field("benchWarmup", cfg.BenchWarmup),
+            field("autoTune", cfg.AutoTune), field("checkpointEvery", cfg.CheckpointEvery), field("hashEvery", cfg.HashEvery), field("deathsEvery", cfg.DeathsEvery),
+            field("encountersEvery", cfg.EncountersEvery), field("rotateEvery", cfg.RotateEvery), field("rotateSize", cfg.RotateSize), field("rotateKeep", cfg.RotateKeep),
+            field("outputQueue", cfg.OutputQueue), field("rewind", cfg.Rewind)),
+    } {
+        if err != nil {
+            return err
+        }
+    }
+
+    cells := cfg.GridSize * cfg.GridSize * max(cfg.Depth, 1)
+    switch {
+    case cfg.GridSize <= 1:
+        return configErrorf("{gridSize} must be greater than 1")
+    case cfg.NumFish+cfg.NumShark+cfg.NumOrca > cells:
+        return configErrorf("{numFish} + {numShark} + {numOrca} cannot exceed {gridSize} * {gridSize} * {depth}")
+    case cfg.FoodWeb != nil && cfg.FoodWeb.InitialTotal() > cells:
+        return configErrorf("the initial species counts cannot exceed {gridSize} * {gridSize} * {depth}")
+    case cfg.NumOrca > 0 && (cfg.OrcaBreed <= 0 || cfg.OrcaStarve <= 0):
+        return positive(field("orcaBreed", cfg.OrcaBreed), field("orcaStarve", cfg.OrcaStarve))
+    case cfg.SpawnSeason > cfg.SpawnYear:
+        return configErrorf("{spawnSeason} cannot be longer than {spawnYear}")
+    case (cfg.FishEggs > 0 || cfg.SpawnSeason > 0) && cfg.FoodWeb != nil:
+        return configErrorf("{fishEggs} and {spawnSeason} cannot be combined with {foodWeb}")
+    case cfg.TerritoryRadius > 0 && cfg.FoodWeb != nil:
+        return configErrorf("{territory} cannot be combined with {foodWeb}")
+    case slices.ContainsFunc(cfg.BreakAt, func(c int) bool { return c < 1 }):
+        return configErrorf("{breakAt} chronons must be 1 or greater")
+    case len(cfg.PauseOn) > 0 && cfg.Console == "" && !cfg.Mouse:
+        return configErrorf("{pauseOn} needs {console} or {mouse}")
+    case cfg.Mouse && (cfg.Headless || cfg.Console == "-"):
+        return configErrorf("{mouse} cannot be used with {headless} or {console} \"-\"")
+    case cfg.Cast != "" && (cfg.Headless || cfg.DrawEvery < 1):
+        return configErrorf("{cast} records the drawing, so it needs {drawEvery} and cannot be used with {headless}")
+    case cfg.AlertURL != "" && !validAlertURL(cfg.AlertURL):
+        return configErrorf("{alertUrl} must be an http or https URL")
+    case !validOverlay(cfg.Overlay):
+        return configErrorf("{overlay} must be %s, %s or %s", overlayStarve, overlayBreed, overlayBoth)
+    case !validPane(cfg.Pane):
+        return configErrorf("{pane} must be %s, %s or %s", paneSide, paneBottom, paneNone)
+    case !cfg.Temperature.valid():
+        return configErrorf("{temperature} must be between 0 and 1")
+    case !validStrategy(cfg.Strategy):
+        return configErrorf("{strategy} must be %s or %s", strategyThreaded, strategySerial)
+    case cfg.WeakMode != weakSkip && cfg.WeakMode != weakYield:
+        return configErrorf("{weakMode} must be %s or %s", weakSkip, weakYield)
+    case !validTopology(cfg.Topology):
+        return configErrorf("{topology} must be one of %s", topologyNames(", "))
+    case cfg.FishDepth < 0 || cfg.FishDepth > cfg.Depth:
+        return configErrorf("{fishDepth} must be between 0 and {depth}")
+    case cfg.SharkDepth < 0 || cfg.SharkDepth > cfg.Depth:
+        return configErrorf("{sharkDepth} must be between 0 and {depth}")
+    case cfg.Rewind > 0 && cfg.Console == "":
+        return configErrorf("{rewind} needs {console}")
+    case cfg.RotateKeep > 0 && !cfg.Rotation().Enabled():
+        return configErrorf("{rotateKeep} needs {rotateEvery} or {rotateSize}")
+    case (cfg.Console != "" || cfg.Control != "" || cfg.Mouse) && cfg.BenchReps > 1:
+        return configErrorf("{console}, {control} and {mouse} cannot be combined with {benchReps}")
+    case cfg.DeathsEvery > 0 && !cfg.Deaths:
+        return configErrorf("{deathsEvery} needs {deaths}")
+    case (cfg.Deaths || cfg.DeathsEvery > 0 || cfg.EncountersEvery > 0) && cfg.FoodWeb != nil:
+        return configErrorf("{deaths} and {encountersEvery} cannot be combined with {foodWeb}")
+    case cfg.Batch > 1 && (cfg.Lifetimes != "" || cfg.Follow != "" || !cfg.Explain.IsZero()):
+        return configErrorf("{batch} cannot be combined with {lifetimes}, {follow} or {explain}")
+    case cfg.Batch > 1 && (len(cfg.Behaviors) > 0 || len(cfg.Plugins) > 0):
+        return configErrorf("{batch} cannot be combined with {behaviors} or {plugins}")
+    case cfg.Batch > 1 && (cfg.Depth > 1 || cfg.Topology == TopologyMobius):
+        return configErrorf("{batch} needs a {depth} of 1 and a {topology} other than mobius")
+    case cfg.Control != "" && cfg.Control == cfg.Console:
+        return configErrorf("{console} and {control} need different sockets")
+    case !validRNG(cfg.RNG):
+        return configErrorf("{rng} must be one of %s", strings.Join(rngKinds, ", "))
+    }
+    if err := cfg.FishRegion.Validate(cfg.GridSize); err != nil {
+        return configErrorf("{fishRegion}: %v", err)
+    }
+    if err := cfg.SharkRegion.Validate(cfg.GridSize); err != nil {
+        return configErrorf("{sharkRegion}: %v", err)
+    }
+    if cfg.FoodWeb != nil {
+        for _, sp := range cfg.FoodWeb.Species {
+            if sp.Depth > cfg.Depth {
+                return configErrorf("species %s prefers layer %d, but {depth} is %d", sp.Name, sp.Depth, cfg.Depth)
+            }
+        }
+    }
+    if cfg.Follow != "" {
+        if _, _, _, err := parseFollow(cfg.Follow); err != nil {
+            return configErrorf("{follow} %v", err)
+        }
+    }
+    if len(cfg.Behaviors) > 0 || len(cfg.Plugins) > 0 || len(cfg.Temperature) > 0 {
+        if _, err := resolveBehaviors(cfg); err != nil {
+            return err
+        }
+    }
+    if !cfg.Explain.IsZero() {
+        if err := cfg.Explain.Validate(cfg.GridSize); err != nil {
+            return configErrorf("{explain}: %v", err)
+        }
+    }
+    if !cfg.Blocks.IsZero() {
+        if err := cfg.Blocks.Validate(cfg.GridSize); err != nil {
+            return configErrorf("{blocks} %v", err)
+        }
+    } else if cfg.BlocksCSV != "" {
+        return configErrorf("{blocksCsv} needs {blocks}")
+    }
+    if cfg.StatsCSV != "" && cfg.StatsEvery == 0 {
+        return configErrorf("{statsCsv} needs {statsEvery}")
+    }
+    if cfg.DebugChecks != "" {
+        if _, err := newDebugChecker(cfg.DebugChecks); err != nil {
+            return configErrorf("{debugChecks}: %v", err)
+        }
+    }
+    return nil
+}
+
+//  @brief Returns when the stats, blocks and frame files are rotated, see rotate.go
+func (cfg Config) Rotation() Rotation {
+    return Rotation{Every: cfg.RotateEvery, Size: cfg.RotateSize, Keep: cfg.RotateKeep}
+}
+
+//  @brief Entry point of the config subcommand: schema, defaults, check FILE, run FILE or watch FILE
+func runConfigCommand(args []string) {
+    usage := "Usage: wa-tor config schema | defaults | check FILE | run FILE | watch FILE"
+    if len(args) == 0 {
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+
+    switch {
+    case args[0] == "schema" && len(args) == 1:
+        fmt.Print(configSchema)
+    case args[0] == "defaults" && len(args) == 1:
+        data, err := MarshalConfig(DefaultConfig())
+        if err != nil {
+            fmt.Printf("Error: %v\n", err)
+            os.Exit(1)
+        }
+        fmt.Println(string(data))
+    case (args[0] == "check" || args[0] == "run" || args[0] == "watch") && len(args) == 2:
+        cfg, err := LoadConfig(args[1])
+        if err != nil {
+            fmt.Printf("Error: %v.\n", err)
+            os.Exit(1)
+        }
+        if args[0] == "check" {
+            fmt.Printf("%s is a valid configuration\n", args[1])
+            return
+        }
+        if args[0] == "watch" {
+            cfg.Reload = args[1]
+        }
+        runLoadedConfig(cfg)
+    default:
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+}
+
+//  @brief Runs a configuration read from a file, resolving the seed and species the way parseConfig does for the command line
+func runLoadedConfig(cfg Config) {
+    if cfg.Seed == 0 {
+        cfg.Seed = time.Now().UnixNano()
+    }
+    if cfg.FoodWeb != nil {
+        cfg.FoodWeb.Activate()
+    }
+    runConfig(cfg)
 }
@@ -0,0 +1,484 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "net"
+    "os"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+/**
+    @file console.go
+    @brief Interactive console for interrogating and steering a running simulation
+    With -console - commands are read from standard input, with -console PATH
+    they are read from clients of a Unix socket at PATH, e.g.
+
+        wa-tor -headless -console /tmp/wator.sock 300 2000 3 8 5 200 4
+        nc -U /tmp/wator.sock
+
+    Commands are answered between chronons, so every answer describes a
+    complete world and a setting changed takes effect from the next chronon.
+    The commands are listed by consoleHelp.
+    With -rewind K the last K chronons are kept in the frame ring of
+    crashring.go and rewind, forward and live play them back read-only: the
+    run pauses and the frame is printed, the live world is left untouched.
+    -control PATH opens a second kind of socket for scripts managing a long
+    run, accepting only the commands in controlCommands. Every failed command
+    is answered with a line starting "Error:".
+*/
+
+//  @brief Commands understood by the console, printed by "help"
+const consoleHelp = `status                  chronon and population
+count [name]            creatures of one kind: fish, sharks, orcas, corpses or a species name
+cell ROW COL            contents of one cell
+explain [REGION|off]    log the decisions of the creatures in row0,col0,row1,col1 every chronon
+place KIND ROW COL      while paused, put a fish, shark or orca in a cell, or empty it with water
+pause                   stop between chronons
+resume                  continue a paused run
+step [N]                run N chronons (default 1), then pause again
+set [NAME VALUE]        change a parameter, without arguments list them
+reload [FILE]           apply the parameters set can change from a JSON configuration, by default the -reload file
+stats                   chronon, population, elapsed time and average chronons per second
+snapshot FILE           write the world to a JSON snapshot
+rewind [N]              pause and show the world N chronons back (default 1), with -rewind
+forward [N]             show the world N chronons later, up to the live chronon
+live                    leave playback, resume and step always continue from the live world
+quit                    end the run as if it had reached its last chronon`
+
+//  @brief Commands accepted on a -control socket
+var controlCommands = []string{"help", "pause", "resume", "snapshot", "stats", "quit"}
+
+//  @brief A command line waiting to be answered by the simulation loop
+type consoleRequest struct {
+    line    string
+    allowed []string //  Commands the source may use, nil for all of them
+    reply   chan string
+}
+
+//  @brief Console accepts commands from standard input and Unix sockets and answers them between chronons
+type Console struct {
+    requests  chan consoleRequest
+    done      chan struct{}  //  Closed once the run has finished, so late commands are refused
+    listeners []net.Listener //  Unix sockets clients connect to
+    once      sync.Once
+    restore   func() //  Puts the terminal back after ServeMouse (nil without it)
+
+    // Owned by the simulation loop
+    paused  bool
+    steps   int //  Chronons left to run before pausing again
+    quit    bool
+    back    int       //  Chronons the playback is behind the live world, 0 when live
+    started time.Time //  When the first command was polled for, the start of the run
+    first   int       //  Chronon the run started at
+}
+
+//  @brief A parameter that can be changed from the console
+type consoleSetting struct {
+    name  string
+    field func(cfg *Config) *int
+    min   int
+}
+
+//  @brief Parameters the set command can change, all take effect from the next chronon
+var consoleSettings = []consoleSetting{
+    {"fishbreed", func(cfg *Config) *int { return &cfg.FishBreed }, 1},
+    {"sharkbreed", func(cfg *Config) *int { return &cfg.SharkBreed }, 1},
+    {"starve", func(cfg *Config) *int { return &cfg.Starve }, 1},
+    {"orcabreed", func(cfg *Config) *int { return &cfg.OrcaBreed }, 1},
+    {"orcastarve", func(cfg *Config) *int { return &cfg.OrcaStarve }, 1},
+    {"corpseenergy", func(cfg *Config) *int { return &cfg.CorpseEnergy }, 0},
+    {"juvenileenergy", func(cfg *Config) *int { return &cfg.JuvenileEnergy }, 0},
+    {"gestationcost", func(cfg *Config) *int { return &cfg.GestationCost }, 0},
+    {"weakenergy", func(cfg *Config) *int { return &cfg.WeakEnergy }, 0},
+    {"cannibalenergy", func(cfg *Config) *int { return &cfg.CannibalEnergy }, 0},
+    {"cannibalgain", func(cfg *Config) *int { return &cfg.CannibalGain }, 0},
+    {"chronons", func(cfg *Config) *int { return &cfg.Chronons }, 0},
+    {"statsevery", func(cfg *Config) *int { return &cfg.StatsEvery }, 0},
+    {"drawevery", func(cfg *Config) *int { return &cfg.DrawEvery }, 1},
+}
+
+//  @brief Creates a console with no sources, see ServeStdin and Listen
+func NewConsole() *Console {
+    return &Console{
+        requests: make(chan consoleRequest),
+        done:     make(chan struct{}),
+    }
+}
+
+//  @brief Starts reading commands from standard input, answering on standard output
+func (c *Console) ServeStdin() {
+    go c.serve(os.Stdin, os.Stdout, nil)
+}
+
+//  @brief Starts accepting clients of a Unix socket at path
+//  @param allowed Commands the clients may use, nil for all of them
+func (c *Console) Listen(path string, allowed []string) error {
+    listener, err := net.Listen("unix", path)
+    if err != nil {
+        return err
+    }
+    c.listeners = append(c.listeners, listener)
+    go func() {
+        for {
+            conn, err := listener.Accept()
+            if err != nil {
+                return // closed
+            }
+            go func() {
+                defer conn.Close()
+                c.serve(conn, conn, allowed)
+            }()
+        }
+    }()
+    return nil
+}
+
+//  @brief Reads command lines from r and writes each answer to w until r ends or the run finishes
+func (c *Console) serve(r io.Reader, w io.Writer, allowed []string) {
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+
+        req := consoleRequest{line: line, allowed: allowed, reply: make(chan string, 1)}
+        select {
+        case c.requests <- req:
+        case <-c.done:
+            fmt.Fprintln(w, "The simulation has finished.")
+            return
+        }
+        fmt.Fprintln(w, <-req.reply)
+    }
+}
+
+//  @brief Answers the commands waiting for s, blocking while the run is paused
+//  Called by the simulation loop before every chronon; returns true once the run should end
+func (c *Console) Poll(s *Simulator) bool {
+    if c.started.IsZero() {
+        c.started, c.first = time.Now(), s.Chronon
+    }
+
+    for waiting := true; waiting; {
+        select {
+        case req := <-c.requests:
+            req.reply <- c.handle(s, req)
+        default:
+            waiting = false
+        }
+    }
+
+    for c.paused && c.steps == 0 && !c.quit {
+        req := <-c.requests
+        req.reply <- c.handle(s, req)
+    }
+
+    if c.steps > 0 {
+        c.steps--
+    }
+    return c.quit
+}
+
+//  @brief Refuses further commands and removes the Unix sockets
+func (c *Console) Close() {
+    c.once.Do(func() {
+        close(c.done)
+        for _, listener := range c.listeners {
+            listener.Close()
+        }
+        if c.restore != nil {
+            c.restore()
+        }
+    })
+}
+
+//  @brief Pauses the run before the next chronon, as the pause command does; called by the simulation loop
+func (c *Console) Pause() {
+    c.paused, c.steps = true, 0
+}
+
+//  @brief Runs one command line against s and returns the answer
+func (c *Console) handle(s *Simulator, req consoleRequest) string {
+    fields := strings.Fields(req.line)
+    command, args := strings.ToLower(fields[0]), fields[1:]
+    if req.allowed != nil && !slices.Contains(req.allowed, command) {
+        return fmt.Sprintf("Error: %q is not available here, try help.", command)
+    }
+
+    switch command {
+    case "help":
+        return helpFor(req.allowed)
+    case "stats":
+        elapsed := time.Since(c.started)
+        rate := float64(s.Chronon-c.first) / elapsed.Seconds()
+        return fmt.Sprintf("Chronon: %d  %s  Elapsed: %v  Chronons/sec: %.1f  Last chronon: %s",
+            s.Chronon, populationLine(s.World), elapsed.Round(time.Millisecond), rate, s.World.Events)
+    case "status":
+        state := "running"
+        if c.paused {
+            state = "paused"
+        }
+        return fmt.Sprintf("Chronon: %d  %s  (%s)", s.Chronon, populationLine(s.World), state)
+    case "count":
+        if len(args) == 0 {
+            return populationLine(s.World)
+        }
+        e, ok := consoleEntity(s.Config, args[0])
+        if !ok {
+            return fmt.Sprintf("Error: unknown creature %q.", args[0])
+        }
+        return strconv.Itoa(countEntities(s.World, e))
+    case "cell":
+        return consoleCell(s.World, args)
+    case "explain":
+        return consoleExplain(s, args)
+    case "place":
+        if !c.paused {
+            return "Error: place needs a paused run, try pause."
+        }
+        return consolePlace(s, args)
+    case "pause":
+        c.paused, c.steps = true, 0
+        return fmt.Sprintf("Paused at chronon %d.", s.Chronon)
+    case "resume":
+        c.paused, c.steps, c.back = false, 0, 0
+        return fmt.Sprintf("Resumed at chronon %d.", s.Chronon)
+    case "step":
+        n := 1
+        if len(args) > 0 {
+            var err error
+            if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+                return "Error: step needs a number of chronons of 1 or greater."
+            }
+        }
+        c.paused, c.steps, c.back = true, n, 0
+        return fmt.Sprintf("Stepping %d chronons from chronon %d.", n, s.Chronon)
+    case "set":
+        return consoleSet(s, args)
+    case "reload":
+        path := s.Config.Reload
+        if len(args) > 0 {
+            path = args[0]
+        }
+        if path == "" {
+            return "Error: reload needs a file name without -reload."
+        }
+        return s.reloadLine(path)
+    case "snapshot":
+        if len(args) != 1 {
+            return "Error: snapshot needs a file name."
+        }
+        if err := WriteSnapshot(args[0], s.World, s.Chronon); err != nil {
+            return fmt.Sprintf("Error: could not write snapshot %s: %v.", args[0], err)
+        }
+        return fmt.Sprintf("Wrote chronon %d to %s.", s.Chronon, args[0])
+    case "rewind", "forward":
+        n := 1
+        if len(args) > 0 {
+            var err error
+            if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+                return fmt.Sprintf("Error: %s needs a number of chronons of 1 or greater.", command)
+            }
+        }
+        if command == "forward" {
+            n = -n
+        }
+        return c.playback(s, c.back+n)
+    case "live":
+        c.back = 0
+        return fmt.Sprintf("Live at chronon %d.", s.Chronon)
+    case "quit":
+        c.quit = true
+        return fmt.Sprintf("Stopping at chronon %d.", s.Chronon)
+    }
+    return fmt.Sprintf("Error: unknown command %q, try help.", command)
+}
+
+//  @brief Pauses the run and shows the frame back chronons before the live world, clamped to the frames held
+func (c *Console) playback(s *Simulator, back int) string {
+    if s.frames == nil {
+        return "Error: rewinding needs -rewind N."
+    }
+    c.paused, c.steps = true, 0
+    c.back = min(max(back, 0), s.frames.Len()-1)
+    f, _ := s.frames.At(c.back)
+
+    fish, sharks := 0, 0
+    for _, row := range f.Rows {
+        fish += strings.Count(row, string(cellGlyph(Fish)))
+        sharks += strings.Count(row, string(cellGlyph(Shark)))
+    }
+    var b strings.Builder
+    fmt.Fprintf(&b, "Chronon: %d  Fish: %d  Sharks: %d  (%d back from live chronon %d, read-only)\n",
+        f.Chronon, fish, sharks, c.back, s.Chronon)
+    for _, row := range f.Rows {
+        b.WriteString(row)
+        b.WriteByte('\n')
+    }
+    fmt.Fprintf(&b, "Events: %s", f.Events)
+    return b.String()
+}
+
+//  @brief Returns the lines of consoleHelp describing the allowed commands, nil allowing all of them
+func helpFor(allowed []string) string {
+    if allowed == nil {
+        return consoleHelp
+    }
+    var lines []string
+    for _, line := range strings.Split(consoleHelp, "\n") {
+        if slices.Contains(allowed, strings.Fields(line)[0]) {
+            lines = append(lines, line)
+        }
+    }
+    return strings.Join(lines, "\n")
+}
+
+//  @brief Returns the entity called name: fish, sharks, orcas, corpses, eggs or a species of the food web
+func consoleEntity(cfg Config, name string) (Entity, bool) {
+    name = strings.ToLower(name)
+    if cfg.FoodWeb != nil {
+        for _, sp := range cfg.FoodWeb.Species {
+            if sp.Name == name {
+                return sp.entity, true
+            }
+        }
+    }
+    switch strings.TrimSuffix(name, "s") {
+    case "fish":
+        return Fish, true
+    case "shark":
+        return Shark, true
+    case "orca":
+        return Orca, true
+    case "corpse":
+        return Corpse, true
+    case "egg":
+        return Egg, true
+    }
+    return Empty, false
+}
+
+//  @brief Describes the cell at the ROW COL arguments
+func consoleCell(w *World, args []string) string {
+    if len(args) != 2 {
+        return "Error: cell needs a row and a column."
+    }
+    row, err1 := strconv.Atoi(args[0])
+    col, err2 := strconv.Atoi(args[1])
+    if err1 != nil || err2 != nil || row < 0 || row >= w.Rows() || col < 0 || col >= w.Size {
+        return fmt.Sprintf("Error: cell %s %s is not inside the %dx%d grid.", args[0], args[1], w.Rows(), w.Size)
+    }
+
+    cell := w.Cells[row][col]
+    desc := fmt.Sprintf("(%d, %d) %c  BreedTimer: %d  Energy: %d", row, col, cellGlyph(cell.Entity), cell.BreedTimer, cell.Energy)
+    if cell.Stage == Juvenile {
+        desc += "  Juvenile"
+    }
+    if cell.Gestation > 0 {
+        desc += "  Gestation: " + strconv.Itoa(cell.Gestation)
+    }
+    return desc
+}
+
+//  @brief Starts or stops explaining the decisions made in a region, without arguments describes the current one
+func consoleExplain(s *Simulator, args []string) string {
+    if len(args) == 0 {
+        if s.Config.explain == nil {
+            return "Not explaining."
+        }
+        return "Explaining " + s.Config.explain.region.String() + "."
+    }
+    if strings.ToLower(args[0]) == "off" {
+        s.Config.explain = nil
+        return "Stopped explaining."
+    }
+    var r Region
+    err := r.Set(args[0])
+    if err == nil {
+        err = r.Validate(s.World.Size)
+    }
+    if err != nil {
+        return fmt.Sprintf("Error: %v.", err)
+    }
+    s.Config.explain = NewExplainer(r)
+    return fmt.Sprintf("Explaining %s from chronon %d.", r, s.Chronon+1)
+}
+
+//  @brief Puts the creature named by the KIND ROW COL arguments in a cell, a newborn with full energy, or empties it for "water"
+func consolePlace(s *Simulator, args []string) string {
+    if len(args) != 3 {
+        return "Error: place needs a kind, a row and a column."
+    }
+    w := s.World
+    e, ok := Empty, strings.ToLower(args[0]) == "water"
+    if !ok {
+        e, ok = consoleEntity(s.Config, args[0])
+    }
+    switch {
+    case !ok || e == Corpse || e == Egg || e >= firstSpecies:
+        return fmt.Sprintf("Error: can only place fish, sharks, orcas or water, not %q.", args[0])
+    case e == Orca && w.OrcaStarve == 0:
+        return "Error: there are no orcas in this run."
+    }
+    row, err1 := strconv.Atoi(args[1])
+    col, err2 := strconv.Atoi(args[2])
+    if err1 != nil || err2 != nil || row < 0 || row >= w.Rows() || col < 0 || col >= w.Size {
+        return fmt.Sprintf("Error: cell %s %s is not inside the %dx%d grid.", args[1], args[2], w.Rows(), w.Size)
+    }
+
+    cell := Cell{Entity: e}
+    switch e {
+    case Shark:
+        cell.Energy = w.Starve
+    case Orca:
+        cell.Energy = w.OrcaStarve
+    }
+    w.writable(row)[col] = cell
+    s.publish()
+    if s.redraw != nil {
+        s.redraw()
+    }
+    return fmt.Sprintf("(%d, %d) %c", row, col, cellGlyph(e))
+}
+
+//  @brief Changes the parameter named by the arguments, or lists every parameter without arguments
+func consoleSet(s *Simulator, args []string) string {
+    if len(args) == 0 {
+        parts := make([]string, len(consoleSettings))
+        for i, setting := range consoleSettings {
+            parts[i] = setting.name + " " + strconv.Itoa(*setting.field(&s.Config))
+        }
+        return strings.Join(parts, "\n")
+    }
+    if len(args) != 2 {
+        return "Error: set needs a parameter name and a value."
+    }
+
+    name := strings.ToLower(args[0])
+    for _, setting := range consoleSettings {
+        if setting.name != name {
+            continue
+        }
+        if strings.HasPrefix(name, "orca") && s.Config.NumOrca == 0 {
+            return "Error: there are no orcas in this run."
+        }
+        value, err := strconv.Atoi(args[1])
+        if err != nil || value < setting.min {
+            return fmt.Sprintf("Error: %s must be an integer of %d or greater.", name, setting.min)
+        }
+        *setting.field(&s.Config) = value
+
+        // The world carries the rules it was stepped with, so snapshots and checkpoints record the change
+        s.World.FishBreed, s.World.SharkBreed, s.World.Starve = s.Config.FishBreed, s.Config.SharkBreed, s.Config.Starve
+        s.World.OrcaBreed, s.World.OrcaStarve = s.Config.OrcaBreed, s.Config.OrcaStarve
+        return fmt.Sprintf("%s set to %d from chronon %d.", name, value, s.Chronon+1)
+    }
+    return fmt.Sprintf("Error: unknown parameter %q, set without arguments lists them.", args[0])
+}
@@ -0,0 +1,54 @@
+package main
+
+import "sync"
+
+/**
+    @file corpse.go
+    @brief Corpses of starved predators, recycling nutrients back to scavengers
+    When corpses are enabled (CorpseDecay > 0), a shark, orca or food-web
+    predator that starves leaves a corpse in its cell instead of vanishing.
+    The corpse decays away after CorpseDecay chronons unless a scavenger eats
+    it first. Sharks scavenge when no fish is adjacent, and food-web species
+    scavenge when their diet lists "corpse". Eating a corpse gives only
+    CorpseEnergy, a partial meal.
+    A corpse stores the chronons it has left in its Energy field.
+*/
+
+//  @brief Name used in a species diet for eating corpses
+const corpseDiet = "corpse"
+
+//  @brief Leaves a corpse where a predator starved at (row, column), if corpses are enabled
+func leaveCorpse(next *World, row, col int, cfg Config, mu *sync.Mutex) {
+    if cfg.CorpseDecay <= 0 {
+        return
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+
+    // Only a predator hunting the starved one could have moved here already, so it keeps the cell
+    if next.Cells[row][col].Entity == Empty {
+        next.Cells[row][col] = Cell{Entity: Corpse, Energy: cfg.CorpseDecay}
+    }
+}
+
+//  @brief Ages the corpse at (row, column), removing it once it has fully decayed
+func stepCorpse(current *World, next *World, row, col int, mu *sync.Mutex) {
+    remaining := current.Cells[row][col].Energy - 1
+    if remaining <= 0 {
+        return // decayed
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+
+    // A scavenger that already moved in has eaten the corpse
+    if next.Cells[row][col].Entity == Empty {
+        next.Cells[row][col] = Cell{Entity: Corpse, Energy: remaining}
+    }
+}
+
+//  @brief Energy after a scavenger with the given energy eats a corpse, capped at its maximum
+func scavengedEnergy(energy, maximum int, cfg Config) int {
+    return min(energy+cfg.CorpseEnergy, maximum)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+/**
+    @file coupled.go
+    @brief The coupled subcommand: two worlds joined by migration corridors
+    Two basins of the same size and parameters are simulated side by side.
+    The east edge of basin A faces the west edge of basin B, and along each
+    corridor (a range of rows) a creature in an edge cell crosses to the
+    empty facing cell of the other basin with the migration probability each
+    chronon, modelling connected habitats such as two basins joined by a strait.
+    In a layered ocean (-depth) only the surface layers are joined, e.g.
+
+        wa-tor coupled -corridor 40,60 -migrate 0.2 -chronons 500 300 2000 3 8 5 100 4
+*/
+
+//  @brief Corridor is the half-open range of edge rows [Row0, Row1) joining the two basins
+type Corridor struct {
+    Row0, Row1 int
+}
+
+//  @brief Corridors is a repeatable command line flag of "row0,row1" corridors
+type Corridors []Corridor
+
+//  @brief Formats the corridors as they are written on the command line (flag.Value)
+func (cs *Corridors) String() string {
+    parts := make([]string, len(*cs))
+    for i, c := range *cs {
+        parts[i] = strconv.Itoa(c.Row0) + "," + strconv.Itoa(c.Row1)
+    }
+    return strings.Join(parts, " ")
+}
+
+//  @brief Parses one "row0,row1" corridor and adds it (flag.Value)
+func (cs *Corridors) Set(value string) error {
+    parts := strings.Split(value, ",")
+    if len(parts) != 2 {
+        return fmt.Errorf("corridor %q must be row0,row1", value)
+    }
+    var rows [2]int
+    for i, p := range parts {
+        n, err := strconv.Atoi(strings.TrimSpace(p))
+        if err != nil {
+            return fmt.Errorf("corridor %q: %q is not an integer", value, p)
+        }
+        rows[i] = n
+    }
+    *cs = append(*cs, Corridor{Row0: rows[0], Row1: rows[1]})
+    return nil
+}
+
+//  @brief Checks that every corridor is a non-empty range of rows of a grid of the given size
+func (cs Corridors) Validate(size int) error {
+    for _, c := range cs {
+        if c.Row0 < 0 || c.Row1 > size || c.Row0 >= c.Row1 {
+            return fmt.Errorf("corridor %d,%d is not a range of rows in a %dx%d grid", c.Row0, c.Row1, size, size)
+        }
+    }
+    return nil
+}
+
+//  @brief CoupledSimulator runs two basins that exchange creatures through corridors
+type CoupledSimulator struct {
+    A, B      *Simulator
+    Corridors Corridors
+    Migrate   float64 //  Chance per chronon that an edge creature crosses to an empty facing cell
+    Migrated  int     //  Creatures that have crossed so far, in either direction
+
+    rnd RNG //  Decides crossings, derived from basin A's seed
+}
+
+//  @brief Populates both basins from cfg, basin B with its own seed derived from cfg.Seed
+func NewCoupledSimulator(cfg Config, corridors Corridors, migrate float64) (*CoupledSimulator, error) {
+    cfgB := cfg
+    cfgB.Seed = streamSeed(cfg.Seed, "basin-b")
+
+    worldA, err := NewPopulatedWorld(cfg)
+    if err != nil {
+        return nil, fmt.Errorf("basin A: %v", err)
+    }
+    worldB, err := NewPopulatedWorld(cfgB)
+    if err != nil {
+        return nil, fmt.Errorf("basin B: %v", err)
+    }
+
+    if len(corridors) == 0 {
+        corridors = Corridors{{Row0: 0, Row1: cfg.GridSize}}
+    }
+
+    c := &CoupledSimulator{
+        A:         NewSimulator(cfg, worldA),
+        B:         NewSimulator(cfgB, worldB),
+        Corridors: corridors,
+        Migrate:   migrate,
+    }
+    c.rnd = c.A.RNGStream("migration")
+    return c, nil
+}
+
+//  @brief Advances both basins by one chronon, then lets creatures cross the corridors
+//  The freshly stepped worlds have not been handed to anyone else yet, so they can still be modified
+func (c *CoupledSimulator) Step() {
+    c.A.Step()
+    c.B.Step()
+
+    a, b := c.A.World, c.B.World
+    east := a.Size - 1
+    for _, corridor := range c.Corridors {
+        for row := corridor.Row0; row < corridor.Row1; row++ {
+            from, to := &a.Cells[row][east], &b.Cells[row][0]
+            if !migrates(from.Entity) || to.Entity != Empty {
+                from, to = to, from
+            }
+            if migrates(from.Entity) && to.Entity == Empty && chance(c.rnd, c.Migrate) {
+                *to, *from = *from, Cell{}
+                c.Migrated++
+            }
+        }
+    }
+}
+
+//  @brief Reports whether creatures of entity e can migrate, corpses, eggs and empty water cannot
+func migrates(e Entity) bool {
+    return isCreature(e)
+}
+
+//  @brief Returns true with probability p
+func chance(rnd RNG, p float64) bool {
+    return float64(rnd.Uint64()>>11)/(1<<53) < p
+}
+
+//  @brief Runs the coupled simulation until a stop condition is reached, printing both basins
+func (c *CoupledSimulator) Run() {
+    cfg := c.A.Config
+    start := time.Now()
+
+    for {
+        c.Step()
+        chronon := c.A.Chronon
+        a, b := c.A.World, c.B.World
+
+        if cfg.StatsEvery > 0 && chronon%cfg.StatsEvery == 0 {
+            fmt.Printf("Chronon: %d  A: %s  B: %s  Migrated: %d\n", chronon, populationLine(a), populationLine(b), c.Migrated)
+        }
+
+        // stop once the fish or every predator is extinct in both basins
+        fish := countEntities(a, Fish) + countEntities(b, Fish)
+        sharks := countEntities(a, Shark) + countEntities(b, Shark)
+        orcas := countEntities(a, Orca) + countEntities(b, Orca)
+        if cfg.FoodWeb == nil && (fish == 0 || sharks+orcas == 0) {
+            break
+        }
+        if cfg.FoodWeb != nil && cfg.FoodWeb.Surviving(a) < 2 && cfg.FoodWeb.Surviving(b) < 2 {
+            break
+        }
+
+        if cond, ok := cfg.StopIf.FirstMet(fish, sharks); ok {
+            fmt.Printf("Stopping at chronon %d: %s\n", chronon, cond)
+            break
+        }
+
+        if cfg.MaxTime > 0 && time.Since(start) >= cfg.MaxTime {
+            fmt.Printf("Time limit of %v reached at chronon %d\n", cfg.MaxTime, chronon)
+            break
+        }
+
+        if cfg.Chronons > 0 && chronon >= cfg.Chronons {
+            break
+        }
+    }
+
+    fmt.Printf("Threads: %d  Time: %v\n", cfg.Threads, time.Since(start))
+    fmt.Printf("Chronons: %d  A: %s  B: %s  Migrated: %d  Seed: %d\n",
+        c.A.Chronon, populationLine(c.A.World), populationLine(c.B.World), c.Migrated, c.A.Seed)
+}
+
+//  @brief Entry point of the coupled subcommand
+func runCoupled(args []string) {
+    fs := flag.NewFlagSet("coupled", flag.ExitOnError)
+    var corridors Corridors
+    fs.Var(&corridors, "corridor", "Edge rows row0,row1 (end exclusive) joining the basins, repeatable (default the whole edge)")
+    migrateFlag := fs.Float64("migrate", 0.1, "Chance per chronon that a creature at a corridor crosses to the other basin")
+    cfg := parseConfig(fs, args, true)
+
+    if err := corridors.Validate(cfg.GridSize); err != nil {
+        fmt.Printf("Error: -corridor: %v.\n", err)
+        os.Exit(1)
+    }
+    if *migrateFlag < 0 || *migrateFlag > 1 {
+        fmt.Println("Error: -migrate must be between 0 and 1.")
+        os.Exit(1)
+    }
+
+    // Both basins are reported as population lines (see -stats-every), the grids are not drawn
+    fmt.Printf("Loaded configuration: %+v\n", cfg)
+    fmt.Printf("Seed: %d\n", cfg.Seed)
+
+    sim, err := NewCoupledSimulator(cfg, corridors, *migrateFlag)
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+    fmt.Printf("Placed A: %s  B: %s\n", populationLine(sim.A.World), populationLine(sim.B.World))
+    sim.Run()
+}
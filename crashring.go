@@ -0,0 +1,102 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+)
+
+/**
+    @file crashring.go
+    @brief The last frames before a crash, kept in memory with -crash-dump
+    The grid and the events of the last -crash-frames chronons (100 by
+    default) are kept in a ring buffer. When the run ends because a species
+    died out or a -stop-if condition held, the ring is written to the
+    -crash-dump file as JSON Lines, oldest first, in the frame format of
+    frames.go with the births, meals and deaths of that chronon added:
+
+        {"chronon":41,"size":4,"rows":["F~~S","~FF~","~~~~","S~F~"],"events":{"fishBirths":2,...}}
+
+    Runs that end on -chronons, -max-time or the console write nothing, so
+    the lead-up to a crash can be examined without recording the whole run, e.g.
+
+        wa-tor -headless -crash-dump crash.jsonl -crash-frames 50 -stop-if "sharks<20" 300 2000 3 8 5 100 4
+
+    With -rewind K the ring keeps at least K frames for the console, whose
+    rewind, forward and live commands play them back (see console.go).
+*/
+
+//  @brief A frame and the events of the chronon that produced it
+type crashFrame struct {
+    Frame
+    Events Events `json:"events"`
+}
+
+//  @brief FrameRing keeps the last frames of a run
+type FrameRing struct {
+    frames []crashFrame
+    next   int  //  Slot the next frame is written to
+    full   bool //  Whether every slot holds a frame
+}
+
+//  @brief Creates a ring holding up to k frames
+func NewFrameRing(k int) *FrameRing {
+    return &FrameRing{frames: make([]crashFrame, k)}
+}
+
+//  @brief Records the grid of w at chronon and the events of that chronon, replacing the oldest frame when full
+func (r *FrameRing) Add(w *World, chronon int, e Events) {
+    r.frames[r.next] = crashFrame{Frame: NewFrame(w, chronon), Events: e}
+    r.next++
+    if r.next == len(r.frames) {
+        r.next, r.full = 0, true
+    }
+}
+
+//  @brief Returns the frames held, oldest first
+func (r *FrameRing) Frames() []crashFrame {
+    if !r.full {
+        return r.frames[:r.next]
+    }
+    return append(append([]crashFrame(nil), r.frames[r.next:]...), r.frames[:r.next]...)
+}
+
+//  @brief Returns the number of frames held
+func (r *FrameRing) Len() int {
+    if r.full {
+        return len(r.frames)
+    }
+    return r.next
+}
+
+//  @brief Returns the frame back frames before the newest, ok is false beyond the oldest held
+func (r *FrameRing) At(back int) (f crashFrame, ok bool) {
+    if back < 0 || back >= r.Len() {
+        return f, false
+    }
+    i := (r.next - 1 - back + len(r.frames)) % len(r.frames)
+    return r.frames[i], true
+}
+
+//  @brief Writes the newest n frames held (all of them if fewer) to path as JSON Lines, oldest first, and returns how many were written
+func (r *FrameRing) Dump(path string, n int) (int, error) {
+    out, err := CreateOutput(path)
+    if err != nil {
+        return 0, err
+    }
+    buf := bufio.NewWriter(out)
+    enc := json.NewEncoder(buf)
+
+    frames := r.Frames()
+    frames = frames[max(len(frames)-n, 0):]
+    for _, f := range frames {
+        if err := enc.Encode(f); err != nil {
+            out.Close()
+            return 0, err
+        }
+    }
+    if err := buf.Flush(); err != nil {
+        out.Close()
+        return 0, err
+    }
+    return len(frames), out.Close()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "io"
+    "os"
+    "sync"
+    "syscall"
+)
+
+/**
+    @file csvfile.go
+    @brief Safe appending of rows to shared CSV files
+    Several runs (goroutines in one process, or separate processes) may
+    append to the same benchmark or stats file at once. Each append holds
+    an in-process mutex and an exclusive file lock, and writes the header
+    (for a new file) and the row with a single write, so rows never interleave.
+    Files named .gz or .zst are compressed one append at a time.
+*/
+
+//  @brief Serialises appends from goroutines of this process
+var csvMu sync.Mutex
+
+//  @brief Appends one row to the CSV file at path, writing header first if the file is empty
+//  @param "header" Header line without a trailing newline
+//  @param "row" Data line without a trailing newline
+func appendCSVRow(path, header, row string) error {
+    csvMu.Lock()
+    defer csvMu.Unlock()
+
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    // Exclusive lock against other processes appending to the same file
+    if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+        return err
+    }
+    defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+    // The size must be checked under the lock, otherwise two writers could both add a header
+    info, err := f.Stat()
+    if err != nil {
+        return err
+    }
+
+    line := row + "\n"
+    if info.Size() == 0 {
+        line = header + "\n" + line
+    }
+
+    // a compressed file gets a complete member or frame per append, see compress.go
+    w, err := compressWriter(path, f)
+    if err != nil {
+        return err
+    }
+    if _, err := io.WriteString(w, line); err != nil {
+        return err
+    }
+    if err := w.Close(); err != nil {
+        return err
+    }
+    return f.Close()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "strings"
+    "time"
+)
+
+/**
+    @file dashboard.go
+    @brief A live terminal dashboard of a sweep, shown with sweep -dashboard
+    Each time a run finishes the dashboard is redrawn with the number of
+    finished and remaining runs, the longest time fish and sharks have
+    coexisted so far, and a text phase diagram with a row per FishBreed and
+    a column per SharkBreed. Each cell shows the most common outcome of the
+    runs finished there, over every Starve and seed:
+        C  coexistence    F  shark-extinct-then-fish-fill
+        f  fish-only      X  both-extinct
+        .  no run finished yet
+    On a terminal every redraw replaces the previous one; written to a file
+    or pipe the frames follow each other.
+*/
+
+//  @brief Glyph of each outcome in the phase diagram
+var outcomeGlyphs = map[string]byte{
+    outcomeCoexistence: 'C',
+    outcomeFishFill:    'F',
+    outcomeFishOnly:    'f',
+    outcomeBothExtinct: 'X',
+}
+
+//  @brief SweepDashboard follows the runs of a sweep and draws its progress
+type SweepDashboard struct {
+    out      io.Writer
+    ansi     bool          //  Redraw in place using escape sequences
+    ranges   [3]paramRange //  FishBreed, SharkBreed and Starve of the sweep
+    runs     []SweepRun    //  Every run of the sweep, by index
+    finished []bool
+    done     int //  Runs finished, including those of a resumed sweep
+    resumed  int //  Runs already finished when the dashboard started
+    started  time.Time
+}
+
+//  @brief Creates a dashboard of runs, those already finished (from a resumed sweep) being marked in finished
+func NewSweepDashboard(out io.Writer, ansi bool, ranges [3]paramRange, runs []SweepRun, finished map[int]SweepRun) *SweepDashboard {
+    d := &SweepDashboard{
+        out:      out,
+        ansi:     ansi,
+        ranges:   ranges,
+        runs:     append([]SweepRun(nil), runs...),
+        finished: make([]bool, len(runs)),
+        started:  time.Now(),
+    }
+    for i := range finished {
+        if i >= 0 && i < len(runs) {
+            d.finished[i] = true
+            d.done++
+        }
+    }
+    d.resumed = d.done
+    return d
+}
+
+//  @brief Records a finished run and redraws the dashboard
+func (d *SweepDashboard) Finish(r *SweepRun) {
+    if !d.finished[r.Index] {
+        d.finished[r.Index] = true
+        d.done++
+    }
+    d.runs[r.Index] = *r
+    d.Draw()
+}
+
+//  @brief Returns the finished run in which fish and sharks stayed together longest, ok is false before any finishes
+//  Coexisting runs come first, then the one whose first extinction came latest
+func (d *SweepDashboard) best() (best SweepRun, ok bool) {
+    for i, r := range d.runs {
+        if !d.finished[i] {
+            continue
+        }
+        coexists := r.Outcome == outcomeCoexistence
+        if !ok || (coexists && best.Outcome != outcomeCoexistence) ||
+            (coexists == (best.Outcome == outcomeCoexistence) && r.Chronons > best.Chronons) {
+            best, ok = r, true
+        }
+    }
+    return best, ok
+}
+
+//  @brief Returns the most common outcome glyph of the finished runs at each FishBreed (row) and SharkBreed (column)
+//  Ties go to the outcome listed first in sweepOutcomes
+func (d *SweepDashboard) phaseDiagram() [][]byte {
+    fish, sharks := d.ranges[0], d.ranges[1]
+    counts := make([][]map[string]int, fish.Hi-fish.Lo+1)
+    for i := range counts {
+        counts[i] = make([]map[string]int, sharks.Hi-sharks.Lo+1)
+    }
+    for i, r := range d.runs {
+        if !d.finished[i] {
+            continue
+        }
+        cell := &counts[r.FishBreed-fish.Lo][r.SharkBreed-sharks.Lo]
+        if *cell == nil {
+            *cell = make(map[string]int)
+        }
+        (*cell)[r.Outcome]++
+    }
+
+    diagram := make([][]byte, len(counts))
+    for row := range counts {
+        diagram[row] = make([]byte, len(counts[row]))
+        for col, cell := range counts[row] {
+            glyph, most := byte('.'), 0
+            for _, o := range sweepOutcomes {
+                if cell[o] > most {
+                    glyph, most = outcomeGlyphs[o], cell[o]
+                }
+            }
+            diagram[row][col] = glyph
+        }
+    }
+    return diagram
+}
+
+//  @brief Draws the dashboard
+func (d *SweepDashboard) Draw() {
+    var b strings.Builder
+    if d.ansi {
+        b.WriteString(ansiHome + ansiClear)
+    }
+
+    elapsed := time.Since(d.started)
+    fmt.Fprintf(&b, "Sweep: %d of %d runs finished, %d remaining, elapsed %v", d.done, len(d.runs), len(d.runs)-d.done, elapsed.Round(time.Second))
+    if ran := d.done - d.resumed; ran > 0 && d.done < len(d.runs) {
+        left := elapsed / time.Duration(ran) * time.Duration(len(d.runs)-d.done)
+        fmt.Fprintf(&b, ", about %v left", left.Round(time.Second))
+    }
+    b.WriteString("\n")
+
+    if best, ok := d.best(); ok {
+        fmt.Fprintf(&b, "Best coexistence: %d chronons (%s) with FishBreed %d  SharkBreed %d  Starve %d  Seed %d\n",
+            best.Chronons, best.Outcome, best.FishBreed, best.SharkBreed, best.Starve, best.Seed)
+    } else {
+        b.WriteString("Best coexistence: no run finished yet\n")
+    }
+
+    fmt.Fprintf(&b, "\nFishBreed down, SharkBreed %d to %d across\n", d.ranges[1].Lo, d.ranges[1].Hi)
+    for i, row := range d.phaseDiagram() {
+        fmt.Fprintf(&b, "%4d  %s\n", d.ranges[0].Lo+i, row)
+    }
+    b.WriteString("\nC coexistence  F shark-extinct-then-fish-fill  f fish-only  X both-extinct  . pending\n")
+
+    io.WriteString(d.out, b.String())
+}
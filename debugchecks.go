@@ -0,0 +1,163 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+/**
+    @file debugchecks.go
+    @brief Optional validation of the world after every chronon, for developing new rules
+    With -debug-checks the world is checked after each chronon and the run
+    panics at the first broken invariant, naming the chronon, the cell and
+    what is wrong with it. The checks can be chosen individually:
+        energy  energies are between 0 and the occupant's maximum
+        timers  breed timers and pregnancies are not negative or too long
+        cells   every cell holds a known entity, and empty cells hold no leftover state
+    e.g.
+
+        wa-tor -headless -debug-checks all -chronons 500 300 2000 3 8 5 100 4
+        wa-tor -headless -debug-checks energy,timers -chronons 500 300 2000 3 8 5 100 4
+
+    Maximums are taken from the configuration; raising one from the console
+    raises it for the checks as well, lowering one keeps the old maximum so
+    creatures that are still above the new one are not reported.
+*/
+
+//  @brief Names of the individual checks, "all" selects every one
+const (
+    checkEnergy = "energy"
+    checkTimers = "timers"
+    checkCells  = "cells"
+)
+
+//  @brief debugChecker validates worlds against the invariants of the rules
+type debugChecker struct {
+    energy, timers, cells bool
+
+    maxEnergy    map[Entity]int //  Highest energy each kind of occupant may have
+    maxGestation int            //  Longest pregnancy a shark may have left
+}
+
+//  @brief Parses a comma separated list of checks, or "all"
+func newDebugChecker(list string) (*debugChecker, error) {
+    d := &debugChecker{maxEnergy: make(map[Entity]int)}
+    for _, name := range strings.Split(list, ",") {
+        switch strings.ToLower(strings.TrimSpace(name)) {
+        case "all":
+            d.energy, d.timers, d.cells = true, true, true
+        case checkEnergy:
+            d.energy = true
+        case checkTimers:
+            d.timers = true
+        case checkCells:
+            d.cells = true
+        default:
+            return nil, fmt.Errorf("unknown debug check %q, expected all or a list of %s, %s and %s", name, checkEnergy, checkTimers, checkCells)
+        }
+    }
+    return d, nil
+}
+
+//  @brief Returns the checker for a list already validated by newDebugChecker, or nil for an empty list
+func mustDebugChecker(list string) *debugChecker {
+    if list == "" {
+        return nil
+    }
+    d, err := newDebugChecker(list)
+    if err != nil {
+        panic(err)
+    }
+    return d
+}
+
+//  @brief Raises the maximums to those of cfg where they are higher
+func (d *debugChecker) allow(cfg Config) {
+    raise := func(e Entity, maximum int) {
+        d.maxEnergy[e] = max(d.maxEnergy[e], maximum)
+    }
+    raise(Shark, cfg.Starve)
+    raise(Orca, cfg.OrcaStarve)
+    raise(Corpse, cfg.CorpseDecay)
+    if cfg.FoodWeb != nil {
+        for _, sp := range cfg.FoodWeb.Species {
+            raise(sp.entity, sp.Starve)
+        }
+    }
+    d.maxGestation = max(d.maxGestation, cfg.Gestation)
+}
+
+//  @brief Checks every cell of w, panicking with the chronon and coordinates of the first broken invariant
+func (d *debugChecker) Check(w *World, chronon int) {
+    for row := 0; row < w.Rows(); row++ {
+        for col, c := range w.Cells[row] {
+            problem := d.problem(c)
+            if problem == "" {
+                continue
+            }
+            where := fmt.Sprintf("cell (%d, %d)", row%w.Size, col)
+            if w.Depth > 1 {
+                where += fmt.Sprintf(" of layer %d", row/w.Size+1)
+            }
+            panic(fmt.Sprintf("debug check failed after chronon %d: %s %s", chronon, where, problem))
+        }
+    }
+}
+
+//  @brief Describes what is wrong with the cell, or returns "" if nothing is
+func (d *debugChecker) problem(c Cell) string {
+    name := entityName(c.Entity)
+
+    if d.cells {
+        switch {
+        case c.Entity < Empty || (c.Entity > Egg && speciesOf(c.Entity) == nil):
+            return fmt.Sprintf("holds the unknown entity %d", c.Entity)
+        case c.Entity == Empty && c != Cell{}:
+            return fmt.Sprintf("is empty but has breed timer %d, energy %d, gestation %d and stage %d", c.BreedTimer, c.Energy, c.Gestation, c.Stage)
+        case c.Stage != Adult && c.Entity != Fish:
+            return fmt.Sprintf("holds a juvenile %s, only fish have a juvenile stage", name)
+        }
+    }
+
+    if d.energy {
+        if maximum := d.maxEnergy[c.Entity]; c.Energy < 0 || c.Energy > maximum {
+            return fmt.Sprintf("holds a %s with energy %d, outside [0, %d]", name, c.Energy, maximum)
+        }
+    }
+
+    if d.timers {
+        maxGestation := 0
+        if c.Entity == Shark {
+            maxGestation = d.maxGestation
+        }
+        switch {
+        case c.BreedTimer < 0:
+            return fmt.Sprintf("holds a %s with breed timer %d", name, c.BreedTimer)
+        case c.Gestation < 0 || c.Gestation > maxGestation:
+            return fmt.Sprintf("holds a %s with gestation %d, outside [0, %d]", name, c.Gestation, maxGestation)
+        }
+    }
+    return ""
+}
+
+//  @brief Name of an occupant in check failures
+func entityName(e Entity) string {
+    switch e {
+    case Empty:
+        return "nothing"
+    case Fish:
+        return "fish"
+    case Shark:
+        return "shark"
+    case Orca:
+        return "orca"
+    case Corpse:
+        return "corpse"
+    case Egg:
+        return "egg"
+    }
+    if sp := speciesOf(e); sp != nil {
+        return sp.Name
+    }
+    return fmt.Sprintf("entity %d", e)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+    "bytes"
+    "cmp"
+    "fmt"
+    "math"
+    "path/filepath"
+    "runtime"
+    "runtime/metrics"
+    "runtime/pprof"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+)
+
+/**
+    @file diag.go
+    @brief Contention and scheduling diagnostics, enabled with -diag
+    For the duration of the run every mutex contention and every blocking
+    wait is profiled, and after the benchmark line a digest is printed: the
+    total time spent waiting, the sites that waited longest, and how long
+    runnable goroutines waited for a thread, e.g.
+
+        wa-tor -headless -diag -chronons 200 2000 30000 3 2 4 300 8
+
+        Threads: 8  Time: 2.315446532s
+        Diag  mutex 2µs in 1 waits  block 1.94s in 200 waits  scheduling median 100ns  p99 12.58ms
+        Diag  mutex  2µs  1 waits  newEmptyWorldLike (simulation.go:25)
+        Diag  block  1.94s  200 waits  inBands (simulation.go:631)
+
+    Long mutex waits in the step functions point at the shared step mutex,
+    long block waits in inBands at workers waiting for the slowest band, and
+    high scheduling latencies at more threads than the machine has cores,
+    as on the single core the example ran on.
+    Profiling every event slows the run, so timings taken with -diag are not
+    comparable with those taken without.
+*/
+
+//  @brief How many of the longest waiting sites the digest lists, per kind
+const diagSites = 3
+
+//  @brief Scheduling latency histogram of the runtime
+const schedLatencies = "/sched/latencies:seconds"
+
+//  @brief Diagnostics profiles contention and scheduling from NewDiagnostics until Report
+type Diagnostics struct {
+    mutexFraction int                       //  Mutex profile fraction before the run, restored by Report
+    sched         *metrics.Float64Histogram //  Scheduling latencies before the run
+    mutex0, block0 map[diagSite]diagWait     //  Waits recorded before the run, subtracted from those after it
+}
+
+//  @brief Where a goroutine waited: the first function outside the runtime and sync packages
+type diagSite struct {
+    function, line string
+}
+
+//  @brief Time spent waiting at a site and how often
+type diagWait struct {
+    cycles, count int64
+}
+
+//  @brief Starts recording every mutex contention and blocking wait
+func NewDiagnostics() *Diagnostics {
+    d := &Diagnostics{mutexFraction: runtime.SetMutexProfileFraction(1)}
+    runtime.SetBlockProfileRate(1)
+    d.mutex0, d.block0 = profileWaits(runtime.MutexProfile), profileWaits(runtime.BlockProfile)
+    d.sched = readSchedLatencies()
+    return d
+}
+
+//  @brief Stops profiling and prints the digest of what happened since NewDiagnostics
+func (d *Diagnostics) Report() {
+    mutex, block := profileWaits(runtime.MutexProfile), profileWaits(runtime.BlockProfile)
+    runtime.SetMutexProfileFraction(d.mutexFraction)
+    runtime.SetBlockProfileRate(0)
+    sched := readSchedLatencies()
+
+    perSecond := cyclesPerSecond()
+    mutexSites, mutexTotal := waitsSince(mutex, d.mutex0)
+    blockSites, blockTotal := waitsSince(block, d.block0)
+    median, p99 := latencyQuantiles(d.sched, sched)
+    fmt.Printf("Diag  mutex %v in %d waits  block %v in %d waits  scheduling median %v  p99 %v\n",
+        cyclesDuration(mutexTotal.cycles, perSecond), mutexTotal.count, cyclesDuration(blockTotal.cycles, perSecond), blockTotal.count, median, p99)
+    for _, kind := range []struct {
+        name  string
+        sites []diagSiteWait
+    }{{"mutex", mutexSites}, {"block", blockSites}} {
+        for _, s := range kind.sites[:min(diagSites, len(kind.sites))] {
+            fmt.Printf("Diag  %s  %v  %d waits  %s (%s)\n", kind.name, cyclesDuration(s.cycles, perSecond), s.count, s.function, s.line)
+        }
+    }
+}
+
+//  @brief Sums the records of a runtime profile (runtime.MutexProfile or runtime.BlockProfile) by site
+func profileWaits(profile func([]runtime.BlockProfileRecord) (int, bool)) map[diagSite]diagWait {
+    n, _ := profile(nil)
+    records := make([]runtime.BlockProfileRecord, n+50)
+    n, ok := profile(records)
+    if !ok {
+        n = len(records)
+    }
+
+    waits := make(map[diagSite]diagWait)
+    for _, r := range records[:n] {
+        site := siteOf(r.Stack())
+        w := waits[site]
+        w.cycles += r.Cycles
+        w.count += r.Count
+        waits[site] = w
+    }
+    return waits
+}
+
+//  @brief Returns the first frame of stack outside the runtime, sync and internal packages
+func siteOf(stack []uintptr) diagSite {
+    frames := runtime.CallersFrames(stack)
+    for {
+        f, more := frames.Next()
+        pkg := f.Function[:max(strings.LastIndex(f.Function, "/"), 0)]
+        if !strings.HasPrefix(f.Function, "runtime.") && !strings.HasPrefix(f.Function, "sync.") && !strings.HasPrefix(pkg, "internal") {
+            name := strings.TrimPrefix(f.Function, "main.")
+            // closures are reported as the function they are written in
+            name, _, _ = strings.Cut(name, ".func")
+            return diagSite{name, filepath.Base(f.File) + ":" + strconv.Itoa(f.Line)}
+        }
+        if !more {
+            return diagSite{"?", "?"}
+        }
+    }
+}
+
+//  @brief A site and its waits
+type diagSiteWait struct {
+    diagSite
+    diagWait
+}
+
+//  @brief Returns the waits since before, longest first, and their total
+func waitsSince(after, before map[diagSite]diagWait) ([]diagSiteWait, diagWait) {
+    var sites []diagSiteWait
+    var total diagWait
+    for site, w := range after {
+        w.cycles -= before[site].cycles
+        w.count -= before[site].count
+        if w.count <= 0 {
+            continue
+        }
+        sites = append(sites, diagSiteWait{site, w})
+        total.cycles += w.cycles
+        total.count += w.count
+    }
+    slices.SortFunc(sites, func(a, b diagSiteWait) int {
+        return cmp.Or(cmp.Compare(b.cycles, a.cycles), cmp.Compare(a.function, b.function))
+    })
+    return sites, total
+}
+
+//  @brief Returns the rate the profiles count cycles at, read from the header of the text form of the mutex profile
+func cyclesPerSecond() float64 {
+    var buf bytes.Buffer
+    pprof.Lookup("mutex").WriteTo(&buf, 1)
+    for _, line := range strings.Split(buf.String(), "\n") {
+        if v, ok := strings.CutPrefix(line, "cycles/second="); ok {
+            if rate, err := strconv.ParseFloat(v, 64); err == nil && rate > 0 {
+                return rate
+            }
+        }
+    }
+    return 1e9
+}
+
+//  @brief Converts cycles to a duration rounded for the digest
+func cyclesDuration(cycles int64, perSecond float64) time.Duration {
+    return roundDiag(time.Duration(float64(cycles) / perSecond * float64(time.Second)))
+}
+
+//  @brief Rounds d to three significant digits or so, as the digest prints it
+func roundDiag(d time.Duration) time.Duration {
+    switch {
+    case d >= time.Second:
+        return d.Round(10 * time.Millisecond)
+    case d >= time.Millisecond:
+        return d.Round(10 * time.Microsecond)
+    case d >= time.Microsecond:
+        return d.Round(10 * time.Nanosecond)
+    }
+    return d
+}
+
+//  @brief Reads the scheduling latency histogram of the runtime, nil when it has none
+func readSchedLatencies() *metrics.Float64Histogram {
+    sample := []metrics.Sample{{Name: schedLatencies}}
+    metrics.Read(sample)
+    if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+        return nil
+    }
+    return sample[0].Value.Float64Histogram()
+}
+
+//  @brief Returns the median and 99th percentile of the scheduling latencies recorded between two readings
+func latencyQuantiles(before, after *metrics.Float64Histogram) (median, p99 time.Duration) {
+    if before == nil || after == nil {
+        return 0, 0
+    }
+    counts := make([]uint64, len(after.Counts))
+    var total uint64
+    for i, c := range after.Counts {
+        counts[i] = c - before.Counts[i]
+        total += counts[i]
+    }
+    quantile := func(q float64) time.Duration {
+        var seen uint64
+        for i, c := range counts {
+            seen += c
+            if float64(seen) >= q*float64(total) && c > 0 {
+                // the upper edge of the bucket, the last one is unbounded
+                upper := after.Buckets[i+1]
+                if math.IsInf(upper, 1) {
+                    upper = after.Buckets[i]
+                }
+                return roundDiag(time.Duration(upper * float64(time.Second)))
+            }
+        }
+        return 0
+    }
+    if total == 0 {
+        return 0, 0
+    }
+    return quantile(0.5), quantile(0.99)
+}
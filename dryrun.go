@@ -0,0 +1,132 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "unsafe"
+)
+
+/**
+    @file dryrun.go
+    @brief What a run would be, printed by -dry-run instead of running it
+    The configuration is validated as for a run, and then the values that
+    follow from it are printed: the cells and how densely the creatures
+    start out on them, how the rows are split between the workers, and an
+    estimate of the memory the run holds at its peak, e.g.
+
+        wa-tor -dry-run -crash-dump crash.jsonl -timelapse run.gif -chronons 100000 2000000 8000000 3 8 5 10000 16
+
+    The memory estimate counts the two worlds every chronon is stepped
+    between, the worlds the drawing and the output queue keep alive, the
+    frames kept for the console and crash dumps and the timelapse frames.
+    It leaves out the Go runtime and buffers of a few megabytes, so it is a
+    lower bound for big runs rather than an exact figure.
+*/
+
+//  @brief Bytes the memory estimate reserves per cell for drawing: the frame buffer and the previous glyphs
+const dryRunDrawBytes = 2
+
+//  @brief MemoryEstimate is what a run keeps in memory at its peak, in bytes
+type MemoryEstimate struct {
+    Worlds    int64 //  The world being stepped and the next one
+    Held      int64 //  Further worlds the renderer and output queue can keep alive
+    Frames    int64 //  Frames kept for -rewind and -crash-dump
+    Timelapse int64 //  Timelapse frames, kept until the run ends
+    Draw      int64 //  Frame buffer and previous glyphs of the renderer
+}
+
+//  @brief Returns the bytes of every part of the estimate together
+func (m MemoryEstimate) Total() int64 {
+    return m.Worlds + m.Held + m.Frames + m.Timelapse + m.Draw
+}
+
+//  @brief Estimates the memory a run of cfg holds at its peak
+func estimateMemory(cfg Config) MemoryEstimate {
+    cells := int64(cfg.GridSize) * int64(cfg.GridSize) * int64(max(cfg.Depth, 1))
+    world := cells * int64(unsafe.Sizeof(Cell{}))
+
+    m := MemoryEstimate{Worlds: 2 * world}
+    drawing := cfg.DrawEvery > 0 && !cfg.Headless
+    if drawing {
+        // the frame being drawn and the one waiting for it
+        m.Held += 2 * world
+        m.Draw = cells * dryRunDrawBytes
+    }
+    if cfg.OutputQueue > 0 && (cfg.DumpFrames != "" || cfg.Timelapse != "" || !cfg.Blocks.IsZero() && cfg.BlocksCSV != "") {
+        m.Held += int64(cfg.OutputQueue) * world
+    }
+
+    keep := cfg.Rewind
+    if cfg.CrashDump != "" {
+        keep = max(keep, cfg.CrashFrames)
+    }
+    m.Frames = int64(keep) * cells // one glyph per cell
+
+    if cfg.Timelapse != "" && cfg.Chronons > 0 {
+        frames := int64(cfg.Chronons/cfg.TimelapseStride + 2)
+        m.Timelapse = frames * cells * int64(cfg.TimelapseScale) * int64(cfg.TimelapseScale)
+    }
+    return m
+}
+
+//  @brief Formats a number of bytes with a binary unit, e.g. "1.5 GiB"
+func byteLine(n int64) string {
+    const unit = 1024
+    if n < unit {
+        return fmt.Sprintf("%d B", n)
+    }
+    value, suffix := float64(n)/unit, "KiB"
+    for _, s := range []string{"MiB", "GiB", "TiB"} {
+        if value < unit {
+            break
+        }
+        value, suffix = value/unit, s
+    }
+    return fmt.Sprintf("%.1f %s", value, suffix)
+}
+
+//  @brief Returns the settings that follow from cfg, one per line
+func dryRunReport(cfg Config) string {
+    var b strings.Builder
+    rows := cfg.GridSize * max(cfg.Depth, 1)
+    cells := int64(rows) * int64(cfg.GridSize)
+    share := func(n int) string {
+        return fmt.Sprintf("%d (%.2f%%)", n, 100*float64(n)/float64(cells))
+    }
+
+    fmt.Fprintf(&b, "Cells: %d (%d rows of %d", cells, rows, cfg.GridSize)
+    if cfg.Depth > 1 {
+        fmt.Fprintf(&b, ", %d layers", cfg.Depth)
+    }
+    b.WriteString(")\n")
+    if cfg.FoodWeb != nil {
+        for _, sp := range cfg.FoodWeb.Species {
+            fmt.Fprintf(&b, "Initial %s: %s\n", sp.Name, share(sp.Initial))
+        }
+        fmt.Fprintf(&b, "Initial density: %.2f%%\n", 100*float64(cfg.FoodWeb.InitialTotal())/float64(cells))
+    } else {
+        fmt.Fprintf(&b, "Initial fish: %s  sharks: %s", share(cfg.NumFish), share(cfg.NumShark))
+        if cfg.NumOrca > 0 {
+            fmt.Fprintf(&b, "  orcas: %s", share(cfg.NumOrca))
+        }
+        fmt.Fprintf(&b, "\nInitial density: %.2f%%\n", 100*float64(cfg.NumFish+cfg.NumShark+cfg.NumOrca)/float64(cells))
+    }
+
+    workers := workerCount(cfg.Threads, rows)
+    fmt.Fprintf(&b, "Workers: %d of %d threads  Rows per worker: %d  Cells per worker: %d\n",
+        workers, cfg.Threads, rows/workers, cells/int64(workers))
+    if cfg.Batch > 1 {
+        halo := 2 * (batchRadius*(cfg.Batch-1) + 1) // above and below the band, see stepBand
+        fmt.Fprintf(&b, "Batch: %d chronons, each worker steps up to %d halo rows besides its own\n", cfg.Batch, min(halo, rows-rows/workers))
+    }
+    if cfg.Chronons > 0 {
+        fmt.Fprintf(&b, "Chronons: %d  Cell updates: %d\n", cfg.Chronons, cells*int64(cfg.Chronons))
+    } else {
+        b.WriteString("Chronons: until an extinction or a stop condition\n")
+    }
+
+    m := estimateMemory(cfg)
+    fmt.Fprintf(&b, "Memory: about %s  worlds %s  held %s  frames %s  timelapse %s  drawing %s\n",
+        byteLine(m.Total()), byteLine(m.Worlds), byteLine(m.Held), byteLine(m.Frames), byteLine(m.Timelapse), byteLine(m.Draw))
+    return b.String()
+}
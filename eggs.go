@@ -0,0 +1,60 @@
+package main
+
+import "sync"
+
+/**
+    @file eggs.go
+    @brief Fish eggs that hatch after a delay, and spawning seasons, enabled with -fish-eggs and -spawn-season
+    Normally a fish that breeds leaves a young fish behind at once. With
+    -fish-eggs N it lays an egg there instead, which hatches into a fish N
+    chronons later, a juvenile when fish mature. Until it hatches an egg
+    takes up its cell, so fish cannot move into it, and sharks eat eggs
+    like fish, gaining JuvenileEnergy from one, so the recruitment of young
+    fish lags behind the spawning and suffers where sharks hunt.
+
+    With -spawn-season N fish only breed in the first N chronons of every
+    -spawn-year chronons; outside the season a fish ready to breed waits for
+    the next one, so the young of a year arrive together, e.g.
+
+        wa-tor -headless -fish-eggs 6 -spawn-season 10 -spawn-year 40 -chronons 500 300 2000 3 8 5 100 4
+
+    An egg counts its age in BreedTimer. Eggs laid and eaten are counted
+    with the events of the chronon, and a hatching as a fish birth.
+*/
+
+//  @brief Reports whether fish breed in the chronon cfg is stepping, that is whether it lies in a spawning season
+func (cfg Config) spawning() bool {
+    if cfg.SpawnSeason == 0 {
+        return true
+    }
+    // chronons are counted from 1, and 0 is a step outside a run
+    return ((cfg.chronon-1)%cfg.SpawnYear+cfg.SpawnYear)%cfg.SpawnYear < cfg.SpawnSeason
+}
+
+//  @brief Reports whether the cell holds what sharks eat: a fish, or an egg
+func isPrey(c Cell) bool {
+    return c.Entity == Fish || c.Entity == Egg
+}
+
+//  @brief Ages the egg at (row, column), hatching it into a fish once it is cfg.FishEggs chronons old
+func stepEgg(current *World, next *World, row, col int, cfg Config, mu *sync.Mutex) {
+    age := current.Cells[row][col].BreedTimer + 1
+
+    mu.Lock()
+    defer mu.Unlock()
+
+    // A shark that already moved in has eaten the egg
+    if next.Cells[row][col].Entity != Empty {
+        return
+    }
+    if age < cfg.FishEggs {
+        next.Cells[row][col] = Cell{Entity: Egg, BreedTimer: age}
+        return
+    }
+    next.Events.FishBirths++
+    hatched := Cell{Entity: Fish}
+    if cfg.FishMature > 0 {
+        hatched.Stage = Juvenile
+    }
+    next.Cells[row][col] = hatched
+}
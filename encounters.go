@@ -0,0 +1,69 @@
+package main
+
+import (
+    "fmt"
+    "math"
+)
+
+/**
+    @file encounters.go
+    @brief Predation rate and encounter density metrics, printed with -encounters-every
+    Mean-field (Lotka-Volterra) models assume fish are well mixed, so a
+    shark finds prey in proportion to the global fish density. On the grid
+    sharks deplete their surroundings and fish cluster, so every N chronons
+    the run prints, for the chronon just simulated:
+        Predation/shark   fish eaten (by sharks and any orcas) per shark alive at its start
+        Mean field        the rate well mixed fish would give: the chance that
+                          at least one of a shark's neighbours holds a fish
+        Fish near sharks  the mean fraction of sharks' neighbouring cells holding fish
+        Fish density      the fraction of all cells holding fish
+    e.g.
+
+        wa-tor -headless -encounters-every 10 -chronons 500 300 2000 3 8 5 100 4
+*/
+
+//  @brief Encounters describes how sharks met fish during one chronon
+type Encounters struct {
+    Sharks        int     //  Sharks at the start of the chronon
+    FishEaten     int     //  Fish eaten by sharks and orcas during it
+    LocalDensity  float64 //  Mean fraction of a shark's neighbouring cells holding fish
+    GlobalDensity float64 //  Fraction of all cells holding fish
+    MeanField     float64 //  Fish eaten per shark if fish were well mixed
+}
+
+//  @brief Measures the encounter densities of the world at the start of a chronon
+func measureEncounters(w *World) Encounters {
+    var e Encounters
+    fish := countEntities(w, Fish)
+    e.GlobalDensity = float64(fish) / float64(w.Rows()*w.Size)
+
+    var local, meanField float64
+    for _, p := range w.Entities(Shark) {
+        neighbors := w.Neighbors(p.Row, p.Col)
+        near := len(w.filter(neighbors, isEntity(Fish)))
+        e.Sharks++
+        if len(neighbors) > 0 {
+            local += float64(near) / float64(len(neighbors))
+        }
+        meanField += 1 - math.Pow(1-e.GlobalDensity, float64(len(neighbors)))
+    }
+    if e.Sharks > 0 {
+        e.LocalDensity = local / float64(e.Sharks)
+        e.MeanField = meanField / float64(e.Sharks)
+    }
+    return e
+}
+
+//  @brief Fish eaten per shark alive at the start of the chronon, 0 without sharks
+func (e Encounters) PredationRate() float64 {
+    if e.Sharks == 0 {
+        return 0
+    }
+    return float64(e.FishEaten) / float64(e.Sharks)
+}
+
+//  @brief Formats the metrics for the encounters line
+func (e Encounters) String() string {
+    return fmt.Sprintf("Predation/shark: %.3f  Mean field: %.3f  Fish near sharks: %.3f  Fish density: %.3f",
+        e.PredationRate(), e.MeanField, e.LocalDensity, e.GlobalDensity)
+}
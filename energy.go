@@ -0,0 +1,103 @@
+package main
+
+import "fmt"
+
+/**
+    @file energy.go
+    @brief A ledger of the energy of the creatures, enabled with -energy-ledger
+    The rules book every change to the energy the creatures hold as it
+    happens (see Events): what predators gain from their meals and the young
+    start with comes in, what the living use up and what creatures hold when
+    another creature takes their cell goes out. After every chronon the
+    ledger adds up the energy of the world and checks it against the energy
+    before the chronon and what the rules booked in between, e.g.
+
+        wa-tor -headless -energy-ledger -chronons 500 300 2000 3 8 5 100 4
+
+    Booked flows and energies always balance while the rules agree with
+    themselves, so an imbalance points at a rule that gives a young or a
+    meal energy other than it books, or moves or copies creatures without
+    stepping them. The first chronons that do not balance are printed as
+    they happen, and the totals of the run after it.
+
+    A run with -batch and more than one worker does not always balance:
+    where two bands meet, the halo one worker steps and the rows the next
+    worker keeps can settle a cell two creatures claimed differently.
+*/
+
+//  @brief Chronons that do not balance printed before the ledger only counts them
+const energyReports = 10
+
+//  @brief EnergyLedger adds up the energy flows of a run and checks them against the energy of the world
+type EnergyLedger struct {
+    Eaten, Born, Spent, Lost int64
+    Start, End               int64 //  Energy of the world before the first chronon recorded and after the last
+    Imbalance                int64 //  What the flows do not account for, added up over every chronon
+    Unbalanced               int   //  Chronons that did not balance
+    started                  bool
+}
+
+//  @brief Returns the ledger of a run of cfg, or nil without -energy-ledger
+func newEnergyLedger(cfg Config) *EnergyLedger {
+    if !cfg.EnergyLedger {
+        return nil
+    }
+    return &EnergyLedger{}
+}
+
+//  @brief Returns the energy the creature in the cell holds, 0 for fish, corpses and empty cells
+//  A corpse keeps the chronons it has left in Energy, which is not energy anything can gain
+func (c Cell) energy() int {
+    if c.Entity == Corpse {
+        return 0
+    }
+    return c.Energy
+}
+
+//  @brief Returns the energy all creatures of w hold
+func worldEnergy(w *World) int64 {
+    var total int64
+    for _, row := range w.Cells {
+        for _, c := range row {
+            total += int64(c.energy())
+        }
+    }
+    return total
+}
+
+//  @brief Records the step that took the world from energy before to w at chronon, printing it if it does not balance
+func (l *EnergyLedger) Record(before int64, w *World, chronon int) {
+    if l == nil {
+        return
+    }
+    e := w.Events
+    after := worldEnergy(w)
+    if !l.started {
+        l.Start, l.started = before, true
+    }
+    l.End = after
+    l.Eaten += int64(e.EnergyEaten)
+    l.Born += int64(e.EnergyBorn)
+    l.Spent += int64(e.EnergySpent)
+    l.Lost += int64(e.EnergyLost)
+
+    off := after - (before + int64(e.EnergyEaten+e.EnergyBorn) - int64(e.EnergySpent+e.EnergyLost))
+    if off == 0 {
+        return
+    }
+    l.Imbalance += off
+    l.Unbalanced++
+    if l.Unbalanced <= energyReports {
+        fmt.Printf("Chronon: %d  Energy unbalanced by %+d  before %d  eaten %d  born %d  spent %d  lost %d  after %d\n",
+            chronon, off, before, e.EnergyEaten, e.EnergyBorn, e.EnergySpent, e.EnergyLost, after)
+    }
+    if l.Unbalanced == energyReports {
+        fmt.Printf("Energy: further unbalanced chronons are only counted\n")
+    }
+}
+
+//  @brief Formats the totals of the ledger on one line
+func (l *EnergyLedger) Line() string {
+    return fmt.Sprintf("start %d  in: eaten %d, born %d  out: spent %d, lost %d  end %d  imbalance %+d in %d chronons",
+        l.Start, l.Eaten, l.Born, l.Spent, l.Lost, l.End, l.Imbalance, l.Unbalanced)
+}
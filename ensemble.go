@@ -0,0 +1,211 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "runtime"
+    "slices"
+    "strings"
+)
+
+/**
+    @file ensemble.go
+    @brief The ensemble subcommand: replicates of one configuration, summarised chronon by chronon
+    One run depends on its seed as much as on its parameters, so a single
+    trajectory can look stable or doomed by luck. The ensemble subcommand
+    runs -runs replicates of the configuration, with the configured seed and
+    the following ones, each headless for -chronons chronons, and reduces
+    the population of every species at every chronon to the mean, minimum
+    and maximum over the replicates, e.g.
+
+        wa-tor ensemble -runs 20 -out ensemble.csv -chart ensemble.png -chronons 1000 300 2000 3 8 5 100
+
+    The CSV file has one row per chronon, starting from the initial world,
+    and the chart draws every species' mean as a line over the band from its
+    minimum to its maximum. A species that dies out stays in the table at 0,
+    so the replicates keep running to the last chronon. Up to -jobs
+    replicates (one per CPU by default) run at once, each on -threads
+    goroutines, and the results do not depend on how many run at once. They
+    repeat with the seed with -threads 1, the default, or -strategy serial
+    only, as threaded bands settle contested cells in whatever order they
+    reach them (see strategy.go).
+*/
+
+//  @brief Ensemble holds the population series of every replicate of a configuration
+type Ensemble struct {
+    Config Config
+    Runs   []ReportSeries //  One per replicate, replicate i having seed Config.Seed + i
+}
+
+//  @brief Runs cfg headless for cfg.Chronons chronons with the seed of replicate i, recording every chronon
+func ensembleRun(cfg Config, i int) (ReportSeries, error) {
+    cfg.Seed += int64(i)
+    var series ReportSeries
+    w, err := NewPopulatedWorld(cfg)
+    if err != nil {
+        return series, err
+    }
+    sim := NewSimulator(cfg, w)
+    series.Add(cfg, sim.World, 0)
+    for sim.Chronon < cfg.Chronons {
+        sim.Step()
+        series.Add(cfg, sim.World, sim.Chronon)
+    }
+    return series, nil
+}
+
+//  @brief Runs the runs replicates of cfg on up to jobs goroutines, calling done after each with the number finished
+//  done is called from one goroutine at a time. Returns the first error; replicates already started are finished first.
+func RunEnsemble(cfg Config, runs, jobs int, done func(finished int)) (*Ensemble, error) {
+    e := &Ensemble{Config: cfg, Runs: make([]ReportSeries, runs)}
+    finished := 0
+    err := runJobs(runs, jobs, func(i int) error {
+        var err error
+        e.Runs[i], err = ensembleRun(cfg, i)
+        return err
+    }, func(int) {
+        finished++
+        done(finished)
+    })
+    return e, err
+}
+
+//  @brief EnsembleStats is the mean, minimum and maximum of one species at every chronon
+type EnsembleStats struct {
+    Name           string
+    Mean, Min, Max []float64
+}
+
+//  @brief Returns the chronons recorded and the statistics of every species over the replicates, in the order of the names
+//  Every replicate runs to the same chronon, so their series line up
+func (e *Ensemble) Stats() ([]float64, []EnsembleStats) {
+    first := e.Runs[0]
+    stats := make([]EnsembleStats, len(first.Names))
+    for s, name := range first.Names {
+        st := EnsembleStats{Name: name}
+        for t := range first.Chronons {
+            values := make([]float64, len(e.Runs))
+            for r, run := range e.Runs {
+                values[r] = run.Counts[s][t]
+            }
+            mean := 0.0
+            for _, v := range values {
+                mean += v
+            }
+            st.Mean = append(st.Mean, mean/float64(len(values)))
+            st.Min = append(st.Min, slices.Min(values))
+            st.Max = append(st.Max, slices.Max(values))
+        }
+        stats[s] = st
+    }
+    return first.Chronons, stats
+}
+
+//  @brief Returns the number of replicates the species at index s of the names died out in
+func (e *Ensemble) Extinct(s int) int {
+    n := 0
+    for _, run := range e.Runs {
+        if counts := run.Counts[s]; counts[len(counts)-1] == 0 {
+            n++
+        }
+    }
+    return n
+}
+
+//  @brief Returns the species name as a CSV column heading, e.g. Fish
+func ensembleHeading(name string) string {
+    return strings.ToUpper(name[:1]) + name[1:]
+}
+
+//  @brief Writes the statistics of every chronon as CSV
+func writeEnsembleCSV(path string, chronons []float64, stats []EnsembleStats) error {
+    f, err := CreateOutput(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    header := []string{"Chronon"}
+    for _, st := range stats {
+        h := ensembleHeading(st.Name)
+        header = append(header, "Mean"+h, "Min"+h, "Max"+h)
+    }
+    fmt.Fprintln(f, strings.Join(header, ","))
+    for t, chronon := range chronons {
+        fmt.Fprintf(f, "%.0f", chronon)
+        for _, st := range stats {
+            fmt.Fprintf(f, ",%.2f,%.0f,%.0f", st.Mean[t], st.Min[t], st.Max[t])
+        }
+        fmt.Fprintln(f)
+    }
+    return f.Close()
+}
+
+//  @brief Returns the chart of every species' mean over the band of its minimum and maximum, coloured as in the report
+func ensembleChart(chronons []float64, stats []EnsembleStats) *Chart {
+    c := &Chart{Width: reportChartWidth, Height: reportChartHeight, YMin: 0, YMax: 1}
+    for i, st := range stats {
+        col := reportColors[i%len(reportColors)]
+        c.Bands = append(c.Bands, Band{X: chronons, Low: st.Min, High: st.Max, Color: col})
+        c.Series = append(c.Series, Series{X: chronons, Y: st.Mean, Color: col})
+        // counts are shown from zero
+        c.YMax = max(c.YMax, slices.Max(st.Max))
+    }
+    return c
+}
+
+//  @brief Entry point of the ensemble subcommand
+func runEnsemble(args []string) {
+    fs := flag.NewFlagSet("ensemble", flag.ExitOnError)
+    runsFlag := fs.Int("runs", 10, "Replicates to run, with -seed and the following seeds")
+    threadsFlag := fs.Int("threads", 1, "Threads each replicate uses (above 1 only repeatable with -strategy serial)")
+    jobsFlag := fs.Int("jobs", runtime.NumCPU(), "Replicates run at the same time")
+    outFlag := fs.String("out", "", "Write the mean, minimum and maximum of every species at every chronon as CSV to this file")
+    chartFlag := fs.String("chart", "", "Render a PNG chart of the means over their minimum to maximum bands to this file")
+    cfg := parseConfig(fs, args, false)
+
+    switch {
+    case cfg.Chronons <= 0:
+        fmt.Println("Error: ensemble needs -chronons greater than 0.")
+        os.Exit(1)
+    case *runsFlag < 1 || *threadsFlag < 1 || *jobsFlag < 1:
+        fmt.Println("Error: -runs, -threads and -jobs must be 1 or greater.")
+        os.Exit(1)
+    case cfg.Resume != "":
+        fmt.Println("Error: the replicates of an ensemble start from new worlds, it cannot be combined with -resume.")
+        os.Exit(1)
+    }
+
+    cfg.Threads = *threadsFlag
+    cfg.Headless = true
+    fmt.Printf("Loaded configuration: %+v\n", cfg)
+    fmt.Printf("Seed: %d\n", cfg.Seed)
+    fmt.Printf("Running %d replicates of %d chronons on %d jobs\n", *runsFlag, cfg.Chronons, min(*jobsFlag, *runsFlag))
+
+    e, err := RunEnsemble(cfg, *runsFlag, *jobsFlag, func(finished int) {
+        fmt.Printf("Replicate %d of %d done\n", finished, *runsFlag)
+    })
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+
+    chronons, stats := e.Stats()
+    last := len(chronons) - 1
+    for s, st := range stats {
+        fmt.Printf("%s  final mean %.1f  min %.0f  max %.0f  died out in %d of %d runs\n",
+            ensembleHeading(st.Name), st.Mean[last], st.Min[last], st.Max[last], e.Extinct(s), len(e.Runs))
+    }
+
+    if *outFlag != "" {
+        if err := writeEnsembleCSV(*outFlag, chronons, stats); err != nil {
+            fmt.Printf("Could not write ensemble %s: %v\n", *outFlag, err)
+        }
+    }
+    if *chartFlag != "" {
+        if err := WritePNG(*chartFlag, ensembleChart(chronons, stats).Render()); err != nil {
+            fmt.Printf("Could not write ensemble chart %s: %v\n", *chartFlag, err)
+        }
+    }
+}
@@ -3,10 +3,13 @@ package main
 /**
 	@file entity.go
 	@brief Defines the different types of entities in the Wa-Tor simulation
-	The world consists of three possible occupants:
+	The world consists of these possible occupants:
 		Empty (no creature)
 		Fish  (moves and reproduces)
 		Shark (moves, eats fish, starves, and reproduces)
+		Orca  (optional apex predator, eats sharks and possibly fish)
+		Corpse (optional, left by starved predators and eaten by scavengers)
+		Egg   (optional, laid by fish, hatches into a fish or is eaten by sharks)
 */
 
 //	@brief Entity represents what occupies a cell in the world grid.
@@ -16,4 +19,15 @@ const (
     Empty Entity = iota  //	 No creature in this cell, iota automatically increments the values 
     Fish                 //	 Fish entity
     Shark                //	 Shark entity
+    Orca                 //	 Apex predator that eats sharks (optional)
+    Corpse               //	 Remains of a starved predator, decays over time (optional)
+    Egg                  //	 Fish egg, hatches after a number of chronons (optional)
 )
+
+//  @brief Reports whether e is a creature that lives and moves, not water, a corpse or an egg
+func isCreature(e Entity) bool {
+    return e != Empty && e != Corpse && e != Egg
+}
+
+//  @brief First Entity value used for species loaded from a species file (see species.go)
+const firstSpecies Entity = 16
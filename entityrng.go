@@ -0,0 +1,76 @@
+package main
+
+/**
+    @file entityrng.go
+    @brief Random streams of their own for every creature, enabled with -entity-rng
+    Normally a worker draws the choices of all creatures in its rows from one
+    stream, so what a creature does depends on how many creatures were
+    stepped before it and on the thread count. With -entity-rng each creature
+    carries a stream in its Life instead. Creatures of the first world are
+    given one seeded from the run seed and their cell, those born or placed
+    later one seeded from the chronon and the cell they first appear in, and
+    every stream advances once per chronon, whether or not it was drawn from.
+    A creature's moves then depend only on the seed and on what it sees, so
+    adding creatures elsewhere in the ocean or changing the thread count
+    leaves its trajectory alone until something crosses its path, e.g.
+
+        wa-tor -headless -entity-rng -seed 7 -follow 10,12 300 2000 3 8 5 100 4
+
+    writes the same moves for the fish in (10, 12) as with 1 thread, see
+    follow.go. Races for cells between workers remain, see checkpoint.go. The streams are xorshift64*
+    whatever -rng names, as one is set up per creature and chronon.
+    Snapshots and checkpoints store them.
+*/
+
+//  @brief Returns the generator for the creature in cell, or rnd when it has no stream yet
+func entityRNG(cell Cell, rnd RNG) RNG {
+    if cell.Stream == 0 {
+        return rnd
+    }
+    return newSourceRNG(&xorshift{state: cell.Stream})
+}
+
+//  @brief Gives every creature of w without a stream one seeded from seed, chronon and its cell
+func (w *World) seedStreams(seed int64, chronon int) {
+    for row := 0; row < w.Rows(); row++ {
+        for col := range w.Cells[row] {
+            if c := w.Cells[row][col]; !isCreature(c.Entity) || c.Stream != 0 {
+                continue
+            }
+            state := uint64(seed) ^ uint64(chronon)<<32 ^ uint64(row*w.Size+col)
+            w.writable(row)[col].Stream = nonZero(splitmix64(&state))
+        }
+    }
+}
+
+//  @brief Advances the stream of every creature of w, the world chronon produced, and seeds those of the newborns
+func (w *World) advanceStreams(seed int64, chronon int) {
+    for row := 0; row < w.Rows(); row++ {
+        for col := range w.Cells[row] {
+            if state := w.Cells[row][col].Stream; state != 0 {
+                w.writable(row)[col].Stream = nonZero(splitmix64(&state))
+            }
+        }
+    }
+    w.seedStreams(seed, chronon)
+}
+
+//  @brief Reports whether any creature of w has a stream of its own
+func (w *World) hasStreams() bool {
+    for _, cells := range w.Cells {
+        for _, c := range cells {
+            if c.Stream != 0 {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+//  @brief Returns x, or 1 for 0, which marks a creature without a stream and is no xorshift state
+func nonZero(x uint64) uint64 {
+    if x == 0 {
+        return 1
+    }
+    return x
+}
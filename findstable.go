@@ -0,0 +1,353 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+/**
+    @file findstable.go
+    @brief The find-stable subcommand: a search for parameters where fish and sharks coexist
+    Every candidate FishBreed, SharkBreed and Starve is run headless with
+    several seeds (the configured seed, then the following ones) until the
+    target -chronons or until either species dies out. Candidates are ranked
+    by how many seeds kept both species alive to the target, then by the mean
+    chronons survived, e.g.
+
+        wa-tor find-stable -fish-breed 2:6 -shark-breed 4:12 -starve 2:8 -seeds 3 -chronons 1000 300 2000 3 8 5 100
+
+    The default grid method tries every combination in the ranges. The climb
+    method starts from the positional FishBreed, SharkBreed and Starve and
+    repeatedly moves to the best neighbouring candidate (one step in one
+    parameter) until none improves, which needs far fewer runs on wide ranges.
+*/
+
+//  Search methods of find-stable
+const (
+    searchGrid  = "grid"  //  Every combination in the ranges
+    searchClimb = "climb" //  Hill climbing from the positional parameters
+)
+
+//  @brief An inclusive range of values for one searched parameter
+type paramRange struct {
+    Lo, Hi int
+}
+
+//  @brief Parses a positive range written "lo:hi", or a single value "n"
+func parseParamRange(name, s string) (paramRange, error) {
+    lo, hi, found := strings.Cut(strings.TrimSpace(s), ":")
+    if !found {
+        hi = lo
+    }
+    a, err1 := strconv.Atoi(lo)
+    b, err2 := strconv.Atoi(hi)
+    if err1 != nil || err2 != nil || a < 1 || b < a {
+        return paramRange{}, fmt.Errorf("-%s %q must be a range lo:hi with 1 <= lo <= hi, or a single value", name, s)
+    }
+    return paramRange{Lo: a, Hi: b}, nil
+}
+
+//  @brief Parses the -fish-breed, -shark-breed and -starve ranges, in the order of StableSearch.Ranges
+func parseParamRanges(fishBreed, sharkBreed, starve string) ([3]paramRange, error) {
+    var ranges [3]paramRange
+    for i, f := range []struct{ name, value string }{
+        {"fish-breed", fishBreed}, {"shark-breed", sharkBreed}, {"starve", starve},
+    } {
+        r, err := parseParamRange(f.name, f.value)
+        if err != nil {
+            return ranges, err
+        }
+        ranges[i] = r
+    }
+    return ranges, nil
+}
+
+//  @brief Clamps v into the range
+func (r paramRange) clamp(v int) int {
+    return min(max(v, r.Lo), r.Hi)
+}
+
+//  @brief The parameters of one candidate
+type stableParams struct {
+    FishBreed, SharkBreed, Starve int
+}
+
+//  @brief Returns pointers to the parameters in the order of StableSearch.Ranges
+func (p *stableParams) genes() [3]*int {
+    return [3]*int{&p.FishBreed, &p.SharkBreed, &p.Starve}
+}
+
+//  @brief Returns the positional FishBreed, SharkBreed and Starve of cfg, clamped into the ranges
+func startParams(cfg Config, ranges [3]paramRange) stableParams {
+    return stableParams{ranges[0].clamp(cfg.FishBreed), ranges[1].clamp(cfg.SharkBreed), ranges[2].clamp(cfg.Starve)}
+}
+
+//  @brief StableCandidate is a candidate with the outcome of its runs
+type StableCandidate struct {
+    stableParams
+    Survived     int     //  Seeds where both species were alive at the target chronon
+    Seeds        int     //  Seeds run
+    MeanChronons float64 //  Mean chronons before either species died out, capped at the target
+    MeanFish     float64 //  Mean fish at the end of the runs
+    MeanSharks   float64 //  Mean sharks at the end of the runs
+}
+
+//  @brief Reports whether c ranks above o: more surviving seeds, then longer mean survival, then more sharks
+func (c StableCandidate) better(o StableCandidate) bool {
+    if c.Survived != o.Survived {
+        return c.Survived > o.Survived
+    }
+    if c.MeanChronons != o.MeanChronons {
+        return c.MeanChronons > o.MeanChronons
+    }
+    return c.MeanSharks > o.MeanSharks
+}
+
+//  @brief Runs cfg headless until cfg.Chronons or until the fish or the sharks die out
+//  Returns the chronons run and the final fish and shark counts, the counts of every chronon are added to series unless it is nil
+func survivalRun(cfg Config, series *PopulationSeries) (chronons, fish, sharks int, err error) {
+    w, err := NewPopulatedWorld(cfg)
+    if err != nil {
+        return 0, 0, 0, err
+    }
+    sim := NewSimulator(cfg, w)
+    fish, sharks = countEntities(w, Fish), countEntities(w, Shark)
+    for sim.Chronon < cfg.Chronons && fish > 0 && sharks > 0 {
+        sim.Step()
+        fish, sharks = countEntities(sim.World, Fish), countEntities(sim.World, Shark)
+        if series != nil {
+            series.Add(fish, sharks)
+        }
+    }
+    return sim.Chronon, fish, sharks, nil
+}
+
+//  @brief Runs the candidate p with seeds consecutive seeds starting at cfg.Seed
+func evaluateStable(cfg Config, p stableParams, seeds int) (StableCandidate, error) {
+    cfg.FishBreed, cfg.SharkBreed, cfg.Starve = p.FishBreed, p.SharkBreed, p.Starve
+    c := StableCandidate{stableParams: p, Seeds: seeds}
+    base := cfg.Seed
+
+    for i := 0; i < seeds; i++ {
+        cfg.Seed = base + int64(i)
+        chronons, fish, sharks, err := survivalRun(cfg, nil)
+        if err != nil {
+            return c, err
+        }
+        if fish > 0 && sharks > 0 {
+            c.Survived++
+        }
+        c.MeanChronons += float64(chronons)
+        c.MeanFish += float64(fish)
+        c.MeanSharks += float64(sharks)
+    }
+    c.MeanChronons /= float64(seeds)
+    c.MeanFish /= float64(seeds)
+    c.MeanSharks /= float64(seeds)
+    return c, nil
+}
+
+//  @brief StableSearch evaluates candidates at most once each and remembers every outcome
+type StableSearch struct {
+    Config  Config
+    Seeds   int
+    Ranges  [3]paramRange //  FishBreed, SharkBreed and Starve
+    results map[stableParams]StableCandidate
+}
+
+//  @brief Returns the outcome of p, running it unless it was already evaluated
+func (s *StableSearch) evaluate(p stableParams) (StableCandidate, error) {
+    if c, ok := s.results[p]; ok {
+        return c, nil
+    }
+    c, err := evaluateStable(s.Config, p, s.Seeds)
+    if err != nil {
+        return c, err
+    }
+    s.results[p] = c
+    fmt.Printf("FishBreed %d  SharkBreed %d  Starve %d: both alive in %d/%d seeds, mean %.1f chronons\n",
+        p.FishBreed, p.SharkBreed, p.Starve, c.Survived, c.Seeds, c.MeanChronons)
+    return c, nil
+}
+
+//  @brief Evaluates every combination in the ranges
+func (s *StableSearch) grid() error {
+    for fb := s.Ranges[0].Lo; fb <= s.Ranges[0].Hi; fb++ {
+        for sb := s.Ranges[1].Lo; sb <= s.Ranges[1].Hi; sb++ {
+            for st := s.Ranges[2].Lo; st <= s.Ranges[2].Hi; st++ {
+                if _, err := s.evaluate(stableParams{fb, sb, st}); err != nil {
+                    return err
+                }
+            }
+        }
+    }
+    return nil
+}
+
+//  @brief Hill climbs from start, moving to the best neighbour one step away in one parameter while it improves
+func (s *StableSearch) climb(start stableParams) error {
+    here, err := s.evaluate(start)
+    if err != nil {
+        return err
+    }
+    for {
+        best := here
+        for _, n := range s.neighbours(here.stableParams) {
+            c, err := s.evaluate(n)
+            if err != nil {
+                return err
+            }
+            if c.better(best) {
+                best = c
+            }
+        }
+        if best.stableParams == here.stableParams {
+            return nil
+        }
+        here = best
+    }
+}
+
+//  @brief Returns the candidates one step away from p in one parameter that lie within the ranges
+func (s *StableSearch) neighbours(p stableParams) []stableParams {
+    var out []stableParams
+    for i, r := range s.Ranges {
+        for _, d := range []int{-1, 1} {
+            n := p
+            v := n.genes()[i]
+            *v += d
+            if *v >= r.Lo && *v <= r.Hi {
+                out = append(out, n)
+            }
+        }
+    }
+    return out
+}
+
+//  @brief Returns every evaluated candidate, best first
+func (s *StableSearch) Ranked() []StableCandidate {
+    ranked := make([]StableCandidate, 0, len(s.results))
+    for _, c := range s.results {
+        ranked = append(ranked, c)
+    }
+    sort.Slice(ranked, func(i, j int) bool {
+        a, b := ranked[i], ranked[j]
+        if a.better(b) || b.better(a) {
+            return a.better(b)
+        }
+        // equal outcomes are listed in parameter order so the table is stable
+        if a.FishBreed != b.FishBreed {
+            return a.FishBreed < b.FishBreed
+        }
+        if a.SharkBreed != b.SharkBreed {
+            return a.SharkBreed < b.SharkBreed
+        }
+        return a.Starve < b.Starve
+    })
+    return ranked
+}
+
+//  @brief Prints the ranked candidates as an aligned table
+func printStableTable(ranked []StableCandidate) {
+    fmt.Printf("%4s  %9s  %10s  %6s  %8s  %13s  %9s  %10s\n", "Rank", "FishBreed", "SharkBreed", "Starve", "Survived", "MeanChronons", "MeanFish", "MeanSharks")
+    for i, c := range ranked {
+        fmt.Printf("%4d  %9d  %10d  %6d  %8s  %13.1f  %9.1f  %10.1f\n", i+1, c.FishBreed, c.SharkBreed, c.Starve,
+            fmt.Sprintf("%d/%d", c.Survived, c.Seeds), c.MeanChronons, c.MeanFish, c.MeanSharks)
+    }
+}
+
+//  @brief Writes the ranked candidates as CSV
+func writeStableCSV(path string, ranked []StableCandidate) error {
+    f, err := CreateOutput(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    fmt.Fprintln(f, "Rank,FishBreed,SharkBreed,Starve,Survived,Seeds,MeanChronons,MeanFish,MeanSharks")
+    for i, c := range ranked {
+        fmt.Fprintf(f, "%d,%d,%d,%d,%d,%d,%.2f,%.2f,%.2f\n", i+1, c.FishBreed, c.SharkBreed, c.Starve,
+            c.Survived, c.Seeds, c.MeanChronons, c.MeanFish, c.MeanSharks)
+    }
+    return f.Close()
+}
+
+//  @brief Entry point of the find-stable subcommand
+func runFindStable(args []string) {
+    fs := flag.NewFlagSet("find-stable", flag.ExitOnError)
+    fishBreedFlag := fs.String("fish-breed", "1:8", "FishBreed values to search, lo:hi or a single value")
+    sharkBreedFlag := fs.String("shark-breed", "2:12", "SharkBreed values to search, lo:hi or a single value")
+    starveFlag := fs.String("starve", "1:8", "Starve values to search, lo:hi or a single value")
+    seedsFlag := fs.Int("seeds", 3, "Seeds each candidate is run with, starting at -seed")
+    methodFlag := fs.String("method", searchGrid, "Search method: "+searchGrid+" or "+searchClimb)
+    topFlag := fs.Int("top", 10, "Number of ranked candidates to print (0 = all)")
+    threadsFlag := fs.Int("threads", 1, "Threads each run uses (above 1 only repeatable with -strategy serial)")
+    outFlag := fs.String("out", "", "Also write every ranked candidate as CSV to this file")
+    cfg := parseConfig(fs, args, false)
+
+    search := StableSearch{Seeds: *seedsFlag, results: make(map[stableParams]StableCandidate)}
+    ranges, err := parseParamRanges(*fishBreedFlag, *sharkBreedFlag, *starveFlag)
+    if err != nil {
+        fmt.Printf("Error: %v.\n", err)
+        os.Exit(1)
+    }
+    search.Ranges = ranges
+    switch {
+    case cfg.Chronons <= 0:
+        fmt.Println("Error: find-stable needs a target -chronons greater than 0.")
+        os.Exit(1)
+    case *seedsFlag < 1:
+        fmt.Println("Error: -seeds must be 1 or greater.")
+        os.Exit(1)
+    case *threadsFlag < 1:
+        fmt.Println("Error: -threads must be 1 or greater.")
+        os.Exit(1)
+    case *topFlag < 0:
+        fmt.Println("Error: -top cannot be negative.")
+        os.Exit(1)
+    case *methodFlag != searchGrid && *methodFlag != searchClimb:
+        fmt.Printf("Error: -method must be %s or %s.\n", searchGrid, searchClimb)
+        os.Exit(1)
+    case cfg.FoodWeb != nil || cfg.Resume != "":
+        fmt.Println("Error: find-stable searches fish and sharks, it cannot be combined with -species or -resume.")
+        os.Exit(1)
+    }
+
+    // Every candidate runs with the same seeds, so they differ only in their parameters
+    cfg.Threads = *threadsFlag
+    cfg.Headless = true
+    search.Config = cfg
+    fmt.Printf("Loaded configuration: %+v\n", cfg)
+    fmt.Printf("Seed: %d\n", cfg.Seed)
+
+    if *methodFlag == searchClimb {
+        err = search.climb(startParams(cfg, search.Ranges))
+    } else {
+        err = search.grid()
+    }
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+
+    ranked := search.Ranked()
+    fmt.Printf("Evaluated %d candidates over %d seeds to %d chronons\n", len(ranked), search.Seeds, cfg.Chronons)
+    if ranked[0].Survived == 0 {
+        fmt.Println("No candidate kept both species alive to the target in any seed")
+    }
+    top := ranked
+    if *topFlag > 0 && *topFlag < len(top) {
+        top = top[:*topFlag]
+    }
+    printStableTable(top)
+
+    if *outFlag != "" {
+        if err := writeStableCSV(*outFlag, ranked); err != nil {
+            fmt.Printf("Could not write candidates %s: %v\n", *outFlag, err)
+        }
+    }
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+)
+
+/**
+    @file follow.go
+    @brief The full history of one creature, written with -follow
+    -follow ID picks a creature by the number the lifetime tracker gives it
+    (see lifetimes.go, creatures present at the start are numbered row by
+    row from 1), -follow ROW,COL the one in that cell when the run starts.
+    Every chronon of its life becomes one row of the -follow-csv file, until
+    and including its death:
+
+        Chronon,ID,Species,Row,Col,Event,Energy,BreedTimer,Offspring,Detail
+        0,42,shark,10,12,start,5,0,0,
+        1,42,shark,10,13,move,5,0,0,eats fish 43
+        2,42,shark,11,13,move,4,0,1,young 3077
+        5,42,shark,11,14,died,0,0,1,starved
+
+    Event is start, move, stay or died. Detail names the meal (fish, shark or
+    corpse and the prey's number), the young left behind, and the cause of a
+    death: "eaten by" the predator that came to its cell, "overwritten by"
+    the creature that moved into the same empty cell after it, or "starved",
+    e.g.
+
+        wa-tor -headless -follow 10,12 -follow-csv shark.csv -chronons 500 300 2000 3 8 5 100 4
+*/
+
+//  @brief Header of the -follow-csv file
+const followHeader = "Chronon,ID,Species,Row,Col,Event,Energy,BreedTimer,Offspring,Detail"
+
+//  @brief Follower writes the history of one creature, numbered by a LifetimeTracker
+type Follower struct {
+    id   int
+    row  int
+    col  int
+    cell Cell   //  The creature as it was last seen
+    prev *World //  The world it was last seen in
+    out  io.WriteCloser
+    buf  *bufio.Writer
+    done bool //  Whether its death has been written
+}
+
+//  @brief Parses -follow: a creature number, or the ROW,COL of a cell at the start of the run
+func parseFollow(s string) (id int, cell [2]int, byCell bool, err error) {
+    if r, c, ok := strings.Cut(s, ","); ok {
+        row, err1 := strconv.Atoi(strings.TrimSpace(r))
+        col, err2 := strconv.Atoi(strings.TrimSpace(c))
+        if err1 != nil || err2 != nil || row < 0 || col < 0 {
+            return 0, cell, false, fmt.Errorf("%q must be a creature number or ROW,COL", s)
+        }
+        return 0, [2]int{row, col}, true, nil
+    }
+    id, err = strconv.Atoi(strings.TrimSpace(s))
+    if err != nil || id < 1 {
+        return 0, cell, false, fmt.Errorf("%q must be a creature number of 1 or greater or ROW,COL", s)
+    }
+    return id, cell, false, nil
+}
+
+//  @brief Finds the creature -follow names in w, already numbered, and writes its first row to path
+func NewFollower(follow, path string, w *World, chronon int) (*Follower, error) {
+    id, at, byCell, err := parseFollow(follow)
+    if err != nil {
+        return nil, fmt.Errorf("follow %v", err)
+    }
+    f := &Follower{id: id, prev: w}
+    switch {
+    case byCell:
+        if at[0] >= w.Rows() || at[1] >= w.Size || w.Cells[at[0]][at[1]].ID == 0 {
+            return nil, fmt.Errorf("cell %d,%d holds no creature at chronon %d", at[0], at[1], chronon)
+        }
+        f.row, f.col = at[0], at[1]
+        f.id = w.Cells[f.row][f.col].ID
+    case !f.find(w):
+        return nil, fmt.Errorf("there is no creature %d at chronon %d", id, chronon)
+    }
+    f.cell = w.Cells[f.row][f.col]
+
+    out, err := CreateOutput(path)
+    if err != nil {
+        return nil, err
+    }
+    f.out, f.buf = out, bufio.NewWriter(out)
+    f.buf.WriteString(followHeader + "\n")
+    f.write(chronon, "start", "")
+    return f, nil
+}
+
+//  @brief Looks for the creature in w, first where it was and around it, then anywhere
+func (f *Follower) find(w *World) bool {
+    if f.prev != nil && f.id != 0 && f.row < w.Rows() {
+        if w.Cells[f.row][f.col].ID == f.id {
+            return true
+        }
+        for _, n := range w.Neighbors(f.row, f.col) {
+            if c := w.Cells[n[0]][n[1]]; c.ID == f.id && c.Entity != Empty {
+                f.row, f.col = n[0], n[1]
+                return true
+            }
+        }
+    }
+    for row := 0; row < w.Rows(); row++ {
+        for col, c := range w.Cells[row] {
+            if c.ID == f.id && isCreature(c.Entity) {
+                f.row, f.col = row, col
+                return true
+            }
+        }
+    }
+    return false
+}
+
+//  @brief Names the occupant of a cell with its number, corpses are not numbered
+func creatureName(c Cell) string {
+    if c.ID == 0 {
+        return entityName(c.Entity)
+    }
+    return entityName(c.Entity) + " " + strconv.Itoa(c.ID)
+}
+
+//  @brief Writes the row of one chronon
+func (f *Follower) write(chronon int, event, detail string) {
+    c := f.cell
+    fmt.Fprintf(f.buf, "%d,%d,%s,%d,%d,%s,%d,%d,%d,%s\n",
+        chronon, f.id, entityName(c.Entity), f.row, f.col, event, c.Energy, c.BreedTimer, c.Offspring, detail)
+}
+
+//  @brief Records what the creature did in the chronon that produced w, numbered by the tracker already
+func (f *Follower) Observe(w *World, chronon int) {
+    if f.done {
+        return
+    }
+    fromRow, fromCol, before := f.row, f.col, f.cell
+    if !f.find(w) {
+        detail := f.cause(w, fromRow, fromCol)
+        f.cell.Energy = 0
+        f.write(chronon, "died", detail)
+        f.done = true
+        f.prev = nil
+        return
+    }
+
+    f.cell = w.Cells[f.row][f.col]
+    event := "stay"
+    var details []string
+    if f.row != fromRow || f.col != fromCol {
+        event = "move"
+        if prey := f.prev.Cells[f.row][f.col]; prey.Entity != Empty {
+            details = append(details, "eats "+creatureName(prey))
+        }
+    }
+    if f.cell.Offspring > before.Offspring {
+        // the young is left where the parent was, unless something moved over it
+        if young := w.Cells[fromRow][fromCol]; young.Entity == f.cell.Entity {
+            details = append(details, fmt.Sprintf("young %d", young.ID))
+        } else {
+            details = append(details, "young overwritten by "+creatureName(young))
+        }
+    }
+    f.write(chronon, event, strings.Join(details, "; "))
+    f.prev = w
+}
+
+//  @brief Names the cause of a death in the chronon that produced w, the creature last seen at (row, col)
+func (f *Follower) cause(w *World, row, col int) string {
+    if c := w.Cells[row][col]; isCreature(c.Entity) && c.ID != f.prev.Cells[row][col].ID {
+        if c.Entity == Orca || (c.Entity == Shark && f.cell.Entity == Fish) {
+            return "eaten by " + creatureName(c)
+        }
+        return "overwritten by " + creatureName(c)
+    }
+    // a predator on its last energy starves before it could eat
+    if f.cell.Entity != Fish && f.cell.Energy <= 1 {
+        return "starved"
+    }
+    // cells are written one at a time, so a creature moving into the same
+    // empty cell after it overwrote it there
+    for _, n := range w.Neighbors(row, col) {
+        if c := w.Cells[n[0]][n[1]]; isCreature(c.Entity) && f.prev.Cells[n[0]][n[1]].Entity == Empty {
+            return "overwritten by " + creatureName(c)
+        }
+    }
+    if f.cell.Entity == Fish {
+        return "vanished"
+    }
+    return "starved"
+}
+
+//  @brief Flushes the rows and closes the file
+func (f *Follower) Close() error {
+    if err := f.buf.Flush(); err != nil {
+        f.out.Close()
+        return err
+    }
+    return f.out.Close()
+}
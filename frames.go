@@ -0,0 +1,105 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "io"
+)
+
+/**
+    @file frames.go
+    @brief Per-chronon world export as JSON Lines, written with -dump-frames
+    Every -dump-every chronons (and once for the starting world) one compact
+    JSON object holding the whole grid is written on its own line, with the
+    same glyphs as the terminal drawing and snapshots, depth layers stored
+    one below the other:
+
+        {"chronon":10,"size":4,"rows":["F~~S","~FF~","~~~~","S~F~"]}
+
+    Unlike a snapshot a frame leaves out breed timers and energies, so a long
+    run stays a manageable file for offline analysis, e.g.
+
+        wa-tor -headless -dump-frames frames.jsonl -dump-every 10 -chronons 500 300 2000 3 8 5 100 4
+*/
+
+//  @brief Frame is the grid of one chronon
+type Frame struct {
+    Chronon int      `json:"chronon"`
+    Size    int      `json:"size"`
+    Depth   int      `json:"depth,omitempty"` //  Depth layers, stored one below the other in the rows
+    Rows    []string `json:"rows"`
+}
+
+//  @brief Captures the grid of w at the given chronon
+func NewFrame(w *World, chronon int) Frame {
+    f := Frame{Chronon: chronon, Size: w.Size, Rows: make([]string, w.Rows())}
+    if w.Depth > 1 {
+        f.Depth = w.Depth
+    }
+
+    line := make([]byte, w.Size)
+    for row := range f.Rows {
+        for col := range line {
+            line[col] = cellGlyph(w.Cells[row][col].Entity)
+        }
+        f.Rows[row] = string(line)
+    }
+    return f
+}
+
+//  @brief FrameWriter writes frames to a JSON Lines file through a buffer, moving to the next file as it rotates
+type FrameWriter struct {
+    file io.WriteCloser //  The file, behind a compressor when its name asks for one (see compress.go)
+    buf  *bufio.Writer
+    enc  *json.Encoder
+    rot  *rotatingPath //  nil without rotation
+}
+
+//  @brief Creates (or truncates) the frames file at path, or with rotation the current numbered file (see rotate.go)
+func CreateFrameWriter(path string, r Rotation, chronon int) (*FrameWriter, error) {
+    fw := &FrameWriter{rot: newRotatingPath(path, r, chronon)}
+    if fw.rot != nil {
+        path, _ = fw.rot.Path(chronon)
+    }
+    return fw, fw.open(path)
+}
+
+//  @brief Creates the file frames are written to next
+func (fw *FrameWriter) open(path string) error {
+    f, err := CreateOutput(path)
+    if err != nil {
+        return err
+    }
+    fw.file, fw.buf = f, bufio.NewWriter(f)
+    fw.enc = json.NewEncoder(fw.buf)
+    return nil
+}
+
+//  @brief Writes the grid of w at chronon as one line, first starting the next file if the current one is full
+func (fw *FrameWriter) Write(w *World, chronon int) error {
+    if path, rotated := fw.rot.Path(chronon); rotated {
+        if err := fw.Close(); err != nil {
+            return err
+        }
+        if err := fw.open(path); err != nil {
+            return err
+        }
+    }
+    if err := fw.enc.Encode(NewFrame(w, chronon)); err != nil {
+        return err
+    }
+    if fw.rot != nil {
+        // the size of a rotated file is checked on disk, so frames are not left in the buffer
+        return fw.buf.Flush()
+    }
+    return nil
+}
+
+//  @brief Flushes the buffered frames and closes the file
+func (fw *FrameWriter) Close() error {
+    if err := fw.buf.Flush(); err != nil {
+        fw.file.Close()
+        return err
+    }
+    return fw.file.Close()
+}
@@ -1,3 +1,5 @@
 module wator
 
 go 1.24.0
+
+require github.com/klauspost/compress v1.18.0
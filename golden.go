@@ -0,0 +1,180 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+)
+
+/**
+    @file golden.go
+    @brief The golden subcommand: golden-frame regression checks of the rules
+    Each golden case runs a small seeded world for a fixed number of chronons
+    and compares its final snapshot against the file stored for it in the
+    golden directory. Any change to the rules, placement or random streams
+    shows up as a mismatch, so behaviour only changes deliberately: after
+    checking that a change is intended, rerun with -update to regenerate, e.g.
+
+        wa-tor golden
+        wa-tor golden -update -run 'orca|corpse'
+*/
+
+//  @brief A seeded configuration whose world after a number of chronons is stored as a golden file
+type goldenCase struct {
+    name     string
+    chronons int
+    cfg      Config
+}
+
+//  @brief Returns the golden cases: the base rules and each optional rule switched on alone
+func goldenCases() []goldenCase {
+    base := DefaultConfig()
+    base.NumShark, base.NumFish = 20, 100
+    base.FishBreed, base.SharkBreed, base.Starve = 3, 6, 6
+    base.GridSize, base.Seed = 16, 42
+
+    with := func(name string, change func(cfg *Config)) goldenCase {
+        cfg := base
+        change(&cfg)
+        return goldenCase{name: name, chronons: 12, cfg: cfg}
+    }
+
+    return []goldenCase{
+        with("base", func(cfg *Config) {}),
+        // threaded bands settle cells claimed across their edges in whatever order they get there, see strategy.go
+        with("threads", func(cfg *Config) { cfg.Threads, cfg.Strategy = 3, strategySerial }),
+        with("pcg", func(cfg *Config) { cfg.RNG = "pcg" }),
+        with("regions", func(cfg *Config) {
+            cfg.FishRegion = Region{Row0: 0, Col0: 0, Row1: 8, Col1: 16}
+            cfg.SharkRegion = Region{Row0: 8, Col0: 0, Row1: 16, Col1: 16}
+        }),
+        with("orcas", func(cfg *Config) {
+            cfg.NumOrca, cfg.OrcaBreed, cfg.OrcaStarve, cfg.OrcaEatsFish = 3, defaultOrcaBreed, defaultOrcaStarve, true
+        }),
+        with("corpses", func(cfg *Config) { cfg.CorpseDecay = 4 }),
+        with("juveniles", func(cfg *Config) { cfg.FishMature = 2 }),
+        with("eggs", func(cfg *Config) { cfg.FishEggs = 3 }),
+        with("seasons", func(cfg *Config) { cfg.SpawnSeason, cfg.SpawnYear = 3, 6 }),
+        with("gestation", func(cfg *Config) { cfg.Gestation = 2 }),
+        with("weak-skip", func(cfg *Config) { cfg.WeakEnergy = 3 }),
+        with("weak-yield", func(cfg *Config) { cfg.WeakEnergy, cfg.WeakMode = 3, weakYield }),
+        with("cannibal", func(cfg *Config) { cfg.CannibalEnergy = 3 }),
+        with("bounded", func(cfg *Config) { cfg.Topology = TopologyBounded }),
+        with("cylinder", func(cfg *Config) { cfg.Topology = TopologyCylinder }),
+        with("mobius", func(cfg *Config) { cfg.Topology = TopologyMobius }),
+        with("depth", func(cfg *Config) { cfg.Depth, cfg.FishDepth, cfg.SharkDepth = 3, 1, 3 }),
+    }
+}
+
+//  @brief Runs the case and returns the canonical serialization of its final world: the indented snapshot
+func (c goldenCase) frame() ([]byte, error) {
+    w, err := NewPopulatedWorld(c.cfg)
+    if err != nil {
+        return nil, err
+    }
+    sim := NewSimulator(c.cfg, w)
+    for sim.Chronon < c.chronons {
+        sim.Step()
+    }
+    data, err := json.MarshalIndent(NewSnapshot(sim.World, sim.Chronon), "", "  ")
+    return append(data, '\n'), err
+}
+
+//  @brief Describes the first difference between two serialized frames
+func frameDifference(want, got []byte) string {
+    var a, b Snapshot
+    if json.Unmarshal(want, &a) != nil || json.Unmarshal(got, &b) != nil {
+        return "the golden file is not a snapshot"
+    }
+    if a.Size != b.Size || len(a.Rows) != len(b.Rows) || a.Chronon != b.Chronon {
+        return fmt.Sprintf("golden is %d rows of %d at chronon %d, got %d rows of %d at chronon %d",
+            len(a.Rows), a.Size, a.Chronon, len(b.Rows), b.Size, b.Chronon)
+    }
+    for row := range a.Rows {
+        for col := 0; col < a.Size; col++ {
+            switch {
+            case a.Rows[row][col] != b.Rows[row][col]:
+                return fmt.Sprintf("cell (%d, %d) is %c, golden has %c", row, col, b.Rows[row][col], a.Rows[row][col])
+            case a.BreedTimer[row][col] != b.BreedTimer[row][col]:
+                return fmt.Sprintf("cell (%d, %d) has breed timer %d, golden has %d", row, col, b.BreedTimer[row][col], a.BreedTimer[row][col])
+            case a.Energy[row][col] != b.Energy[row][col]:
+                return fmt.Sprintf("cell (%d, %d) has energy %d, golden has %d", row, col, b.Energy[row][col], a.Energy[row][col])
+            }
+        }
+    }
+    return "the life stages, pregnancies or world parameters differ"
+}
+
+//  @brief Checks every case matching pattern against its golden file in dir, or rewrites the files when update is set
+//  Returns a description of every failing case
+func CheckGolden(dir string, update bool, pattern *regexp.Regexp) ([]string, error) {
+    if update {
+        if err := os.MkdirAll(dir, 0o755); err != nil {
+            return nil, err
+        }
+    }
+
+    var failures []string
+    for _, c := range goldenCases() {
+        if !pattern.MatchString(c.name) {
+            continue
+        }
+        got, err := c.frame()
+        if err != nil {
+            return failures, fmt.Errorf("case %s: %v", c.name, err)
+        }
+
+        path := filepath.Join(dir, c.name+".json")
+        if update {
+            if err := os.WriteFile(path, got, 0o644); err != nil {
+                return failures, err
+            }
+            fmt.Printf("updated %s\n", path)
+            continue
+        }
+
+        want, err := os.ReadFile(path)
+        switch {
+        case os.IsNotExist(err):
+            failures = append(failures, fmt.Sprintf("%s: no golden file %s, run with -update to create it", c.name, path))
+        case err != nil:
+            return failures, err
+        case !bytes.Equal(want, got):
+            failures = append(failures, fmt.Sprintf("%s: %s", c.name, frameDifference(want, got)))
+        default:
+            fmt.Printf("ok      %s\n", c.name)
+        }
+    }
+    return failures, nil
+}
+
+//  @brief Entry point of the golden subcommand
+func runGolden(args []string) {
+    fs := flag.NewFlagSet("golden", flag.ExitOnError)
+    dirFlag := fs.String("dir", filepath.Join("testdata", "golden"), "Directory holding the golden files")
+    updateFlag := fs.Bool("update", false, "Regenerate the golden files instead of comparing against them")
+    runFlag := fs.String("run", "", "Only run the cases whose name matches this regular expression")
+    fs.Parse(args)
+
+    pattern, err := regexp.Compile(*runFlag)
+    if err != nil {
+        fmt.Printf("Error: -run: %v.\n", err)
+        os.Exit(1)
+    }
+
+    failures, err := CheckGolden(*dirFlag, *updateFlag, pattern)
+    for _, f := range failures {
+        fmt.Printf("FAIL    %s\n", f)
+    }
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+    if len(failures) > 0 {
+        os.Exit(1)
+    }
+}
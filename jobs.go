@@ -0,0 +1,353 @@
+package main
+
+import (
+    "encoding/json"
+    "expvar"
+    "flag"
+    "fmt"
+    "net/http"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+/**
+    @file jobs.go
+    @brief The serve-jobs subcommand: a small HTTP experiment runner
+    Simulation configurations are posted as the command line arguments of a
+    normal run, queued, and run by a bounded pool of workers, each job in its
+    own child process of the same executable so a failing configuration cannot
+    take the server down, e.g.
+
+        wa-tor serve-jobs -listen localhost:8080 -workers 4 -job-timeout 30m
+        curl -d '{"args": ["-chronons", "500", "300", "2000", "3", "8", "5", "100", "4"]}' localhost:8080/jobs
+        curl localhost:8080/jobs/1
+
+    Endpoints:
+        POST /jobs       queue a job, answered with its id
+        GET  /jobs       every job without its output
+        GET  /jobs/{id}  one job, with its output once it has finished
+        GET  /debug/vars expvar counters, including the jobs in each state (see monitor.go)
+
+    The server has no authentication, so it listens on localhost unless
+    told otherwise, and a job may only set the parameters of the simulation
+    (the flags of jobFlags): flags that load plugins, read or write files or
+    open sockets of their own are refused. A job running longer than
+    -job-timeout is killed, and only the last jobOutputLimit bytes of what
+    it prints are kept.
+*/
+
+//  @brief Bytes of a job's output kept, the end of it where the final population line is
+const jobOutputLimit = 1 << 20
+
+//  @brief The flags a job may use, each mapped to whether it takes a value
+//  Only simulation parameters and headless reporting to the output; everything touching files, sockets or plugins is left out
+var jobFlags = map[string]bool{
+    "chronons": true, "seed": true, "rng": true, "entity-rng": false, "strategy": true, "batch": true,
+    "fish-region": true, "shark-region": true, "orcas": true, "orca-breed": true, "orca-starve": true, "orca-eats-fish": false,
+    "corpse-decay": true, "corpse-energy": true, "fish-mature": true, "juvenile-energy": true,
+    "fish-eggs": true, "spawn-season": true, "spawn-year": true, "gestation": true, "gestation-cost": true,
+    "weak-energy": true, "weak-mode": true, "cannibal-energy": true, "cannibal-gain": true, "territory": true, "territory-drift": true,
+    "topology": true, "depth": true, "fish-depth": true, "shark-depth": true, "behavior": true, "temperature": true,
+    "headless": false, "dry-run": false, "stats-every": true, "stop-if": true, "max-time": true, "alert-on": true,
+    "bench-reps": true, "bench-warmup": true, "auto-tune": true, "diag": false, "hash-every": true, "debug-checks": true,
+    "energy-ledger": false, "deaths": false, "deaths-every": true, "encounters-every": true, "lag": false,
+    "blocks": true, "blocks-every": true, "explain": true,
+}
+
+//  Lifecycle of a job
+const (
+    jobQueued  = "queued"
+    jobRunning = "running"
+    jobDone    = "done"
+    jobFailed  = "failed"
+)
+
+//  @brief JobRequest is the body of POST /jobs
+type JobRequest struct {
+    Args []string `json:"args"` //  Arguments of a normal run: flags, then the positional parameters
+}
+
+//  @brief Job is one queued simulation and, once it has finished, its result
+type Job struct {
+    ID       int       `json:"id"`
+    Args     []string  `json:"args"`
+    Status   string    `json:"status"`
+    Queued   time.Time `json:"queued"`
+    Started  time.Time `json:"started,omitzero"`
+    Finished time.Time `json:"finished,omitzero"`
+    ExitCode int       `json:"exitCode"`
+    Summary  string    `json:"summary,omitempty"` //  Final population line of the run
+    Output   string    `json:"output,omitempty"`  //  Everything the run printed
+}
+
+//  @brief JobQueue holds the jobs of a serve-jobs server and runs them on a bounded pool of workers
+type JobQueue struct {
+    mu      sync.Mutex
+    jobs    []*Job   //  Every job, in id order starting at 1
+    pending chan int      //  Ids of queued jobs waiting for a worker
+    exe     string        //  Executable the jobs are run with
+    timeout time.Duration //  How long a job may run before it is killed (0 = no limit)
+}
+
+//  @brief Creates a queue holding up to capacity waiting jobs, each killed after timeout (0 = never), and starts its workers
+func NewJobQueue(workers, capacity int, timeout time.Duration) (*JobQueue, error) {
+    exe, err := os.Executable()
+    if err != nil {
+        return nil, err
+    }
+
+    q := &JobQueue{pending: make(chan int, capacity), exe: exe, timeout: timeout}
+    for i := 0; i < workers; i++ {
+        go q.work()
+    }
+    return q, nil
+}
+
+//  @brief Queues a run with the given arguments, returning the new job
+//  Fails when the queue is full
+func (q *JobQueue) Submit(args []string) (Job, error) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    job := &Job{ID: len(q.jobs) + 1, Args: args, Status: jobQueued, Queued: time.Now()}
+    select {
+    case q.pending <- job.ID:
+    default:
+        return Job{}, fmt.Errorf("the queue is full, %d jobs are waiting", cap(q.pending))
+    }
+    q.jobs = append(q.jobs, job)
+    return *job, nil
+}
+
+//  @brief Returns a copy of the job with the given id
+func (q *JobQueue) Get(id int) (Job, bool) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    if id < 1 || id > len(q.jobs) {
+        return Job{}, false
+    }
+    return *q.jobs[id-1], true
+}
+
+//  @brief Returns copies of every job without their output
+func (q *JobQueue) List() []Job {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    jobs := make([]Job, len(q.jobs))
+    for i, job := range q.jobs {
+        jobs[i] = *job
+        jobs[i].Output = ""
+    }
+    return jobs
+}
+
+//  @brief Returns the number of jobs in each state, published as an expvar
+func (q *JobQueue) counts() any {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    counts := map[string]int{jobQueued: 0, jobRunning: 0, jobDone: 0, jobFailed: 0}
+    for _, job := range q.jobs {
+        counts[job.Status]++
+    }
+    return counts
+}
+
+//  @brief Runs queued jobs one at a time, forever
+func (q *JobQueue) work() {
+    for id := range q.pending {
+        q.mu.Lock()
+        job := q.jobs[id-1]
+        job.Status, job.Started = jobRunning, time.Now()
+        args := append([]string{"-headless"}, job.Args...)
+        q.mu.Unlock()
+
+        output := &tailWriter{limit: jobOutputLimit}
+        cmd := exec.Command(q.exe, args...)
+        cmd.Stdout, cmd.Stderr = output, output
+        err := cmd.Start()
+        if err == nil {
+            var timer *time.Timer
+            if q.timeout > 0 {
+                timer = time.AfterFunc(q.timeout, func() { cmd.Process.Kill() })
+            }
+            err = cmd.Wait()
+            // a timer that already fired has killed the job
+            if timer != nil && !timer.Stop() {
+                fmt.Fprintf(output, "\nKilled after -job-timeout %v\n", q.timeout)
+            }
+        }
+
+        q.mu.Lock()
+        job.Finished, job.Output, job.Status = time.Now(), output.String(), jobDone
+        job.Summary = runSummary(job.Output)
+        if err != nil {
+            job.Status, job.ExitCode = jobFailed, -1
+            if exit, ok := err.(*exec.ExitError); ok {
+                job.ExitCode = exit.ExitCode()
+            }
+        }
+        q.mu.Unlock()
+    }
+}
+
+//  @brief tailWriter keeps the last limit bytes written to it
+type tailWriter struct {
+    buf     []byte
+    limit   int
+    dropped int //  Bytes written before the ones kept
+}
+
+//  @brief Appends p, dropping the oldest bytes beyond the limit (io.Writer)
+func (t *tailWriter) Write(p []byte) (int, error) {
+    t.buf = append(t.buf, p...)
+    if over := len(t.buf) - t.limit; over > 0 {
+        t.buf = append(t.buf[:0], t.buf[over:]...)
+        t.dropped += over
+    }
+    return len(p), nil
+}
+
+//  @brief Returns the bytes kept, noting how many came before them
+func (t *tailWriter) String() string {
+    if t.dropped == 0 {
+        return string(t.buf)
+    }
+    return fmt.Sprintf("... %d bytes of output dropped ...\n%s", t.dropped, t.buf)
+}
+
+//  @brief Returns the final "Chronons: ..." line a run prints, or "" if it never got that far
+func runSummary(output string) string {
+    lines := strings.Split(output, "\n")
+    for i := len(lines) - 1; i >= 0; i-- {
+        if strings.HasPrefix(lines[i], "Chronons: ") {
+            return lines[i]
+        }
+    }
+    return ""
+}
+
+//  @brief Reports whether name is one of the subcommands selected by the first argument
+func isSubcommand(name string) bool {
+    return subcommand(name) != nil
+}
+
+//  @brief Checks that the flags of args, which end at the first positional argument or "--" as in the flag package, are all in jobFlags
+func checkJobArgs(args []string) error {
+    for i := 0; i < len(args); i++ {
+        arg := args[i]
+        if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+            return nil
+        }
+        name, _, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+        takesValue, ok := jobFlags[name]
+        if !ok {
+            return fmt.Errorf("jobs can only set simulation parameters, -%s is not allowed", name)
+        }
+        if takesValue && !hasValue {
+            // the next argument is its value
+            i++
+        }
+    }
+    return nil
+}
+
+//  @brief Handles POST and GET on /jobs
+func (q *JobQueue) serveJobs(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        writeJSON(w, http.StatusOK, q.List())
+    case http.MethodPost:
+        var req JobRequest
+        if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+            http.Error(w, "could not decode job: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+        if len(req.Args) > 0 && isSubcommand(req.Args[0]) {
+            http.Error(w, fmt.Sprintf("jobs run simulations, %q is a subcommand", req.Args[0]), http.StatusBadRequest)
+            return
+        }
+        if err := checkJobArgs(req.Args); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        job, err := q.Submit(req.Args)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusServiceUnavailable)
+            return
+        }
+        writeJSON(w, http.StatusAccepted, job)
+    default:
+        w.Header().Set("Allow", "GET, POST")
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+//  @brief Handles GET on /jobs/{id}
+func (q *JobQueue) serveJob(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.Atoi(r.PathValue("id"))
+    if err != nil {
+        http.Error(w, "job ids are integers", http.StatusBadRequest)
+        return
+    }
+    job, ok := q.Get(id)
+    if !ok {
+        http.NotFound(w, r)
+        return
+    }
+    writeJSON(w, http.StatusOK, job)
+}
+
+//  @brief Writes v as an indented JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    enc.Encode(v)
+}
+
+//  @brief Entry point of the serve-jobs subcommand
+func runServeJobs(args []string) {
+    fs := flag.NewFlagSet("serve-jobs", flag.ExitOnError)
+    listenFlag := fs.String("listen", "localhost:8080", "Address the HTTP server listens on, which anyone reaching it can queue jobs at")
+    workersFlag := fs.Int("workers", 2, "Number of jobs run at the same time")
+    queueFlag := fs.Int("queue", 1000, "Number of jobs that can wait for a worker")
+    timeoutFlag := fs.Duration("job-timeout", time.Hour, "Kill a job that runs longer than this (0 = no limit)")
+    fs.Parse(args)
+
+    if *workersFlag < 1 || *queueFlag < 1 {
+        fmt.Println("Error: -workers and -queue must be 1 or greater.")
+        os.Exit(1)
+    }
+
+    if *timeoutFlag < 0 {
+        fmt.Println("Error: -job-timeout must be 0 or greater.")
+        os.Exit(1)
+    }
+
+    queue, err := NewJobQueue(*workersFlag, *queueFlag, *timeoutFlag)
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+
+    expvar.Publish("jobs", expvar.Func(queue.counts))
+
+    mux := http.NewServeMux()
+    mux.Handle("/debug/vars", expvar.Handler())
+    mux.HandleFunc("/jobs", queue.serveJobs)
+    mux.HandleFunc("GET /jobs/{id}", queue.serveJob)
+
+    fmt.Printf("Serving jobs on %s with %d workers\n", *listenFlag, *workersFlag)
+    if err := http.ListenAndServe(*listenFlag, mux); err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+}
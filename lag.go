@@ -0,0 +1,123 @@
+package main
+
+import (
+    "fmt"
+    "math"
+)
+
+/**
+    @file lag.go
+    @brief Lag between the fish and shark population cycles, reported with -lag
+    The fish and shark counts of every chronon are kept, and at the end of
+    the run the cross-correlation of the two series is computed for every lag
+    of up to a third of the run. The lag with the highest correlation is how
+    many chronons shark peaks trail fish peaks; a negative lag means the
+    sharks lead, e.g.
+
+        wa-tor -headless -lag -chronons 2000 300 2000 3 8 5 100 4
+*/
+
+//  @brief Fewest chronons a lag is computed for
+const minLagSeries = 10
+
+//  @brief PopulationSeries holds the fish and shark count of every chronon of a run
+type PopulationSeries struct {
+    Fish, Sharks []float64
+}
+
+//  @brief Appends the counts of one chronon
+func (p *PopulationSeries) Add(fish, sharks int) {
+    p.Fish = append(p.Fish, float64(fish))
+    p.Sharks = append(p.Sharks, float64(sharks))
+}
+
+//  @brief Pearson correlation of x[t] with y[t+lag] over the chronons where both exist
+//  Returns NaN when either overlapping part is constant
+func crossCorrelation(x, y []float64, lag int) float64 {
+    if lag < 0 {
+        return crossCorrelation(y, x, -lag)
+    }
+    n := min(len(x), len(y)-lag)
+    if n < 2 {
+        return math.NaN()
+    }
+    x, y = x[:n], y[lag:lag+n]
+
+    var mx, my float64
+    for i := range x {
+        mx += x[i]
+        my += y[i]
+    }
+    mx, my = mx/float64(n), my/float64(n)
+
+    var sxy, sxx, syy float64
+    for i := range x {
+        dx, dy := x[i]-mx, y[i]-my
+        sxy += dx * dy
+        sxx += dx * dx
+        syy += dy * dy
+    }
+    if sxx == 0 || syy == 0 {
+        return math.NaN()
+    }
+    return sxy / math.Sqrt(sxx*syy)
+}
+
+//  @brief Returns the lag of sharks behind fish with the highest correlation, searching up to a third of the series
+//  ok is false when the series is too short or a population never changes
+func (p *PopulationSeries) PeakLag() (lag int, r float64, ok bool) {
+    n := len(p.Fish)
+    if n < minLagSeries {
+        return 0, 0, false
+    }
+
+    maxLag := n / 3
+    best := math.Inf(-1)
+    for k := -maxLag; k <= maxLag; k++ {
+        c := crossCorrelation(p.Fish, p.Sharks, k)
+        // ties go to the smallest lag, the first peak rather than a later cycle
+        if !math.IsNaN(c) && (c > best || (c == best && abs(k) < abs(lag))) {
+            lag, best = k, c
+        }
+    }
+    if math.IsInf(best, -1) {
+        return 0, 0, false
+    }
+    return lag, best, true
+}
+
+//  @brief Returns the oscillation period of the fish: the first peak of their autocorrelation after it turns negative
+//  ok is false when the series is too short, never changes or does not oscillate within half its length
+func (p *PopulationSeries) Period() (period int, ok bool) {
+    if len(p.Fish) < minLagSeries {
+        return 0, false
+    }
+
+    turned := false
+    prev := 1.0
+    for k := 1; k <= len(p.Fish)/2; k++ {
+        c := crossCorrelation(p.Fish, p.Fish, k)
+        switch {
+        case math.IsNaN(c):
+            return 0, false
+        case c < 0:
+            turned = true
+        case turned && c < prev && prev > 0:
+            return k - 1, true
+        }
+        prev = c
+    }
+    return 0, false
+}
+
+//  @brief Describes the peak lag on one line
+func (p *PopulationSeries) LagLine() string {
+    lag, r, ok := p.PeakLag()
+    switch {
+    case !ok:
+        return fmt.Sprintf("Lag: not enough variation over %d chronons to correlate fish and sharks", len(p.Fish))
+    case lag < 0:
+        return fmt.Sprintf("Lag: shark peaks lead fish peaks by %d chronons (correlation %.3f)", -lag, r)
+    }
+    return fmt.Sprintf("Lag: shark peaks trail fish peaks by %d chronons (correlation %.3f)", lag, r)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+/**
+    @file lifetimes.go
+    @brief Lifetime and offspring statistics of every creature, tracked with -lifetimes
+    The tracker numbers each creature the first time it sees it (see Life in
+    cell.go) and notices its death when the number disappears from the grid,
+    whatever the cause. At the end of the run it prints the mean lifetime and
+    offspring count of each species, and writes their joint distribution to a
+    CSV file with one row per species, status, lifetime and offspring count:
+
+        Species,Status,Lifetime,Offspring,Count
+        fish,died,3,1,5012
+
+    Status is "died" for complete lives and "alive" for creatures still alive
+    at the end, whose lifetime so far is given. Creatures present when the run
+    starts (or resumes) are counted as born then, e.g.
+
+        wa-tor -headless -lifetimes lifetimes.csv -chronons 500 300 2000 3 8 5 100 4
+*/
+
+//  @brief A creature the tracker has numbered
+type lifeRecord struct {
+    entity    Entity
+    born      int //  Chronon the creature was first seen at
+    seen      int //  Chronon the creature was last seen at
+    offspring int
+}
+
+//  @brief One cell of the joint distribution of lifetimes and offspring counts
+type lifeKey struct {
+    entity              Entity
+    alive               bool
+    lifetime, offspring int
+}
+
+//  @brief LifetimeTracker follows every creature of a run and counts the lives of each length and offspring count
+type LifetimeTracker struct {
+    lastID int
+    alive  map[int]*lifeRecord
+    lives  map[lifeKey]int
+}
+
+//  @brief Creates a tracker and numbers every creature of w, which are taken to be born at chronon
+func NewLifetimeTracker(w *World, chronon int) *LifetimeTracker {
+    t := &LifetimeTracker{alive: make(map[int]*lifeRecord), lives: make(map[lifeKey]int)}
+    t.Observe(w, chronon)
+    return t
+}
+
+//  @brief Records the world after chronon: numbers the newborns and counts the lives of creatures no longer in it
+func (t *LifetimeTracker) Observe(w *World, chronon int) {
+    for row := 0; row < w.Rows(); row++ {
+        for col := range w.Cells[row] {
+            c := w.Cells[row][col]
+            if !isCreature(c.Entity) {
+                continue
+            }
+            if rec, ok := t.alive[c.ID]; ok {
+                rec.seen, rec.offspring = chronon, c.Offspring
+                continue
+            }
+            t.lastID++
+            c.ID = t.lastID
+            w.writable(row)[col].ID = c.ID
+            t.alive[c.ID] = &lifeRecord{entity: c.Entity, born: chronon, seen: chronon, offspring: c.Offspring}
+        }
+    }
+
+    for id, rec := range t.alive {
+        if rec.seen != chronon {
+            t.lives[lifeKey{rec.entity, false, chronon - rec.born, rec.offspring}]++
+            delete(t.alive, id)
+        }
+    }
+}
+
+//  @brief Returns the distribution, including the creatures still alive at chronon, in a stable order
+func (t *LifetimeTracker) distribution(chronon int) ([]lifeKey, map[lifeKey]int) {
+    counts := make(map[lifeKey]int, len(t.lives))
+    for k, n := range t.lives {
+        counts[k] = n
+    }
+    for _, rec := range t.alive {
+        counts[lifeKey{rec.entity, true, chronon - rec.born, rec.offspring}]++
+    }
+
+    keys := make([]lifeKey, 0, len(counts))
+    for k := range counts {
+        keys = append(keys, k)
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        a, b := keys[i], keys[j]
+        switch {
+        case a.entity != b.entity:
+            return a.entity < b.entity
+        case a.alive != b.alive:
+            return !a.alive
+        case a.lifetime != b.lifetime:
+            return a.lifetime < b.lifetime
+        }
+        return a.offspring < b.offspring
+    })
+    return keys, counts
+}
+
+//  @brief Summarises each species on one line: completed lives with their mean lifetime and offspring, and survivors
+func (t *LifetimeTracker) Summary(chronon int) string {
+    type totals struct{ died, lifetimes, offspring, alive int }
+    var order []Entity
+    bySpecies := make(map[Entity]*totals)
+
+    keys, counts := t.distribution(chronon)
+    for _, k := range keys {
+        s, ok := bySpecies[k.entity]
+        if !ok {
+            s = &totals{}
+            bySpecies[k.entity] = s
+            order = append(order, k.entity)
+        }
+        n := counts[k]
+        if k.alive {
+            s.alive += n
+            continue
+        }
+        s.died += n
+        s.lifetimes += n * k.lifetime
+        s.offspring += n * k.offspring
+    }
+
+    var b strings.Builder
+    b.WriteString("Lifetimes")
+    for _, e := range order {
+        s := bySpecies[e]
+        fmt.Fprintf(&b, "  %s: %d died", entityName(e), s.died)
+        if s.died > 0 {
+            fmt.Fprintf(&b, ", mean lifetime %.2f, mean offspring %.2f", float64(s.lifetimes)/float64(s.died), float64(s.offspring)/float64(s.died))
+        }
+        fmt.Fprintf(&b, ", %d alive", s.alive)
+    }
+    return b.String()
+}
+
+//  @brief Writes the joint distribution of lifetimes and offspring counts at chronon to a CSV file, replacing it
+func (t *LifetimeTracker) WriteCSV(path string, chronon int) error {
+    var b strings.Builder
+    b.WriteString("Species,Status,Lifetime,Offspring,Count\n")
+
+    keys, counts := t.distribution(chronon)
+    for _, k := range keys {
+        status := "died"
+        if k.alive {
+            status = "alive"
+        }
+        fmt.Fprintf(&b, "%s,%s,%d,%d,%d\n", entityName(k.entity), status, k.lifetime, k.offspring, counts[k])
+    }
+    return writeOutput(path, []byte(b.String()))
+}
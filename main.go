@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"	//	Used to find the fields a configuration error names
 	"flag" //	Allows for command line option parsing, used to parse optional parameters
 	"fmt"  //	For printing text to terminal
 	"os"   //	Provides functions interacting with the operating system
 	"strconv"	//	Used to convert string to int 
+	"strings"	//	Used to join option names in messages
+	"time"	//	Used to pick a seed when none is given
 )
 
 /**
@@ -19,7 +22,11 @@ import (
 	@brief this is the program entrypoint
 
 	Here is what happens:
-	Parses input from the user including, Optional flags {chronons, draw, bench}
+	Parses input from the user including, Optional flags {chronons, draw, bench, headless, dry-run, stats-every, stats-csv, stop-if, max-time, chronon-duration, snapshot, bench-reps, bench-warmup, diag, auto-tune, batch, strategy, seed, rng, entity-rng, checkpoint, checkpoint-every, resume,
+	fish-region, shark-region, species, orcas, orca-breed, orca-starve, orca-eats-fish,
+	corpse-decay, corpse-energy, fish-mature, juvenile-energy, fish-eggs, spawn-season, spawn-year, gestation, gestation-cost,
+	weak-energy, weak-mode, cannibal-energy, cannibal-gain, territory, territory-drift, topology, depth, fish-depth, shark-depth, console, control, http, otlp, trace-every, alert-url, hash-every, debug-checks, energy-ledger, deaths, deaths-every, encounters-every, lag, lifetimes, blocks, blocks-every, blocks-csv, dump-frames, dump-every, rotate-every, rotate-size, rotate-keep, output-queue, crash-dump, crash-frames, rewind, timelapse, stride, scale, cast, report, pane, overlay, mouse, reload, pause-on, alert-on, break-at, explain, follow, follow-csv, behavior, plugin, temperature}
+	Subcommands such as bench-scale, coupled, cluster, cluster-worker, serve-jobs, config, presets, golden, find-stable, tune, sweep, ensemble, diff, render and baseline are selected by the first argument
 	The 7 required positional arguments:
 	1. NumShark  
 	2. NumFish  
@@ -33,23 +40,394 @@ import (
 */
 
 func main() {
+	// Subcommands are selected by the first argument, anything else is a normal run
+	if len(os.Args) > 1 {
+		if run := subcommand(os.Args[1]); run != nil {
+			run(os.Args[2:])
+			return
+		}
+	}
+
+	runConfig(parseConfig(flag.CommandLine, os.Args[1:], true))
+}
+
+//	@brief Returns the entry point of the subcommand called name, or nil when name is not one
+//	main and the job queue (see isSubcommand) both select subcommands by this table
+func subcommand(name string) func(args []string) {
+	switch name {
+	case "bench-scale":
+		return runBenchScale
+	case "coupled":
+		return runCoupled
+	case "cluster":
+		return runCluster
+	case "cluster-worker":
+		return runClusterWorkerCommand
+	case "serve-jobs":
+		return runServeJobs
+	case "config":
+		return runConfigCommand
+	case "presets":
+		return runPresets
+	case "golden":
+		return runGolden
+	case "find-stable":
+		return runFindStable
+	case "tune":
+		return runTune
+	case "sweep":
+		return runSweep
+	case "ensemble":
+		return runEnsemble
+	case "diff":
+		return runDiff
+	case "render":
+		return runRender
+	case "baseline":
+		return runBaseline
+	}
+	return nil
+}
+
+//	@brief Runs a validated configuration: repeated benchmark runs, a resumed checkpoint or a new world
+func runConfig(cfg Config) {
+	fmt.Printf("Loaded configuration: %+v\n", cfg)
+	fmt.Printf("Seed: %d\n", cfg.Seed)
+
+	if cfg.DryRun {
+		fmt.Print(dryRunReport(cfg))
+		return
+	}
+
+	if cfg.HTTP != "" {
+		startMonitoring(cfg.HTTP)
+	}
+
+	// Repeated runs report timing statistics instead of a single sample
+	if cfg.BenchReps > 1 {
+		ReportBenchmark(cfg, RunBenchmark(cfg))
+		return
+	}
+
+	// Continue a checkpointed run instead of populating a new world
+	if cfg.Resume != "" {
+		cp, err := ReadCheckpoint(cfg.Resume)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		sim, err := ResumeSimulator(cfg, cp)
+		if err != nil {
+			fmt.Printf("Error: could not resume from %s: %v\n", cfg.Resume, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Resuming from chronon %d with seed %d\n", sim.Chronon, sim.Seed)
+		attachConsole(sim)
+		sim.Run()
+		return
+	}
+
+	world, err := NewPopulatedWorld(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Placed %s\n", populationLine(world))
+	sim := NewSimulator(cfg, world)
+	attachConsole(sim)
+	sim.Run()
+}
+
+//	@brief Starts the console, control socket and mouse input requested with -console, -control and -mouse, if any, and attaches them to sim
+func attachConsole(sim *Simulator) {
+	cfg := sim.Config
+	if cfg.Console == "" && cfg.Control == "" && !cfg.Mouse {
+		return
+	}
+
+	console := NewConsole()
+	switch cfg.Console {
+	case "":
+	case "-":
+		console.ServeStdin()
+	default:
+		if err := console.Listen(cfg.Console, nil); err != nil {
+			fmt.Printf("Error: could not start the console: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.Control != "" {
+		if err := console.Listen(cfg.Control, controlCommands); err != nil {
+			fmt.Printf("Error: could not start the control socket: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.Mouse {
+		if err := console.ServeMouse(cfg.GridSize, cfg.Depth); err != nil {
+			fmt.Printf("Error: could not read the mouse: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	sim.Console = console
+}
+
+//	@brief The positional arguments and flags whose names are not the JSON names of the fields they set, spelt as flags
+var fieldFlags = map[string]string{
+	"numShark":        "NumShark",
+	"numFish":         "NumFish",
+	"fishBreed":       "FishBreed",
+	"sharkBreed":      "SharkBreed",
+	"starve":          "Starve",
+	"gridSize":        "GridSize",
+	"threads":         "Threads",
+	"numOrca":         "-orcas",
+	"drawEvery":       "-draw",
+	"benchFile":       "-bench",
+	"timelapseStride": "-stride",
+	"timelapseScale":  "-scale",
+	"foodWeb":         "-species",
+	"behaviors":       "-behavior",
+	"plugins":         "-plugin",
+}
+
+//	@brief Returns the positional argument or flag setting the configuration field of the given JSON name, e.g. -fish-depth for fishDepth
+func flagName(field string) string {
+	if name, ok := fieldFlags[field]; ok {
+		return name
+	}
+	var name strings.Builder
+	name.WriteByte('-')
+	for _, r := range field {
+		if 'A' <= r && r <= 'Z' {
+			name.WriteByte('-')
+			r += 'a' - 'A'
+		}
+		name.WriteRune(r)
+	}
+	return name.String()
+}
+
+/**
+	@brief Registers the simulation flags on fs, parses args and validates the resulting configuration
+	@param fs           Flag set to register the flags on, subcommands may add their own flags first
+	@param args         Command line arguments without the program (or subcommand) name
+	@param withThreads  Whether Threads is read as the 7th positional argument
+	Exits with an error message if any value is invalid
+*/
+func parseConfig(fs *flag.FlagSet, args []string, withThreads bool) Config {
 	/**
 	    Define command-line flags
     	@param chrononsFlag  Number of chronons to run (0 = infinite)
     	@param drawFlag      Draw every N chronons
     	@param benchFlag     Output benchmark CSV file (optional)
+    	@param headlessFlag  Suppress all per-chronon terminal output
+    	@param dryRunFlag    Print the derived settings instead of running
+    	@param statsFlag     Print a population/timing line every N chronons
+    	@param statsCSV      CSV file a row is appended to with each population/timing line
+    	@param stopIf        Population conditions that end the run (repeatable)
+    	@param maxTimeFlag   Wall-clock limit after which the run ends cleanly
+    	@param chrononDuration  Simulated length of a chronon the run is paced to
+    	@param snapshotFlag  Write the final world to this JSON file (optional)
+    	@param repsFlag      Run the configuration N times and report mean/stddev/min
+    	@param seedFlag      Seed for the random number generator (0 = pick one)
+    	@param warmupFlag    Untimed chronons run before the benchmark timer starts
+    	@param diagFlag      Print the contention and scheduling digest of the run
+    	@param autoTuneFlag  Chronons spent trying worker counts before keeping the fastest
+    	@param batchFlag     Chronons every worker steps on its own between synchronisation points
+    	@param strategyFlag  How the bands of the workers are run: threaded or serial
+    	@param rngFlag       Random number generator algorithm
+    	@param entityRNG     Draw each creature's choices from a random stream of its own
+    	@param checkpointFlag   Checkpoint file, including random generator state (optional)
+    	@param checkpointEvery  Write the checkpoint every N chronons
+    	@param resumeFlag       Continue a run from this checkpoint (optional)
+    	@param fishRegion       Rectangle the fish are initially placed in
+    	@param sharkRegion      Rectangle the sharks are initially placed in
+    	@param speciesFlag      Species definition file replacing fish and sharks (optional)
+    	@param orcasFlag        Number of orcas, an optional apex predator that eats sharks
+    	@param orcaBreed        Chronons between orca reproductions
+    	@param orcaStarve       Maximum orca energy
+    	@param orcaEatsFish     Let orcas eat fish as well as sharks
+    	@param corpseDecay      Chronons a starved predator's corpse remains
+    	@param corpseEnergy     Energy a scavenger gains from a corpse
+    	@param fishMature       Chronons newborn fish stay juvenile
+    	@param juvenileEnergy   Energy a shark gains from a juvenile fish
+    	@param fishEggs         Chronons a fish egg takes to hatch
+    	@param spawnSeason      Chronons of every spawning year that fish breed in
+    	@param spawnYear        Chronons of a spawning year
+    	@param gestationFlag    Chronons a shark is pregnant before giving birth
+    	@param gestationCost    Extra energy a pregnant shark uses each chronon
+    	@param weakEnergy       Energy below which a shark is weak
+    	@param weakMode         How a weak shark's behaviour is reduced
+    	@param cannibalEnergy   Energy below which a shark attacks other sharks
+    	@param cannibalGain     Energy a shark gains from eating another shark
+    	@param territory        Cells around its anchor a shark keeps to, 0 for no territories
+    	@param territoryDrift   Chance 1 in N each chronon that an anchor moves towards its shark
+    	@param topologyFlag     How the grid's edges connect
+    	@param depthFlag        Number of depth layers of the ocean
+    	@param fishDepth        Layer fish drift towards
+    	@param sharkDepth       Layer sharks drift towards when not hunting
+    	@param consoleFlag      Where console commands are read from
+    	@param controlFlag      Unix socket accepting control commands
+    	@param httpFlag         Address the monitoring counters are served on
+    	@param otlpFlag         OpenTelemetry collector the chronon phases are traced to
+    	@param traceEvery       Trace every Nth chronon
+    	@param alertURL         Webhook the population alarms are posted to
+    	@param hashEvery        Print a digest of the world every N chronons
+    	@param debugChecks      Invariants checked after each chronon
+    	@param energyLedger     Balance the energy flows of every chronon
+    	@param deathsFlag       Print the deaths of each cause at the end
+    	@param deathsEvery      Also print the deaths every N chronons
+    	@param encountersEvery  Print the predation rate and encounter densities every N chronons
+    	@param lagFlag          Print the lag between the fish and shark cycles at the end
+    	@param lifetimesFlag    CSV file the lifetime and offspring distribution is written to
+    	@param blocks           Block grid the per-block counts are reported for
+    	@param blocksEvery      Report the per-block counts every N chronons
+    	@param blocksCSV        CSV file the per-block counts are appended to
+    	@param dumpFrames       JSON Lines file the grid of every sampled chronon is written to
+    	@param dumpEvery        Write a frame every N chronons
+    	@param rotateEvery      Start new stats, blocks and frame files every N chronons
+    	@param rotateSize       Start new stats, blocks and frame files once they reach this size
+    	@param rotateKeep       Number of rotated files of each output kept
+    	@param outputQueue      Output jobs queued behind the step loop before it waits
+    	@param crashDump        JSON Lines file the last frames are written to when the run crashes
+    	@param crashFrames      Number of frames kept for the crash dump
+    	@param rewindFlag       Number of frames kept for the console to rewind through
+    	@param timelapse        GIF file a condensed animation of the run is written to
+    	@param stride           Add a timelapse frame every N chronons
+    	@param scale            Pixels per cell in the timelapse
+    	@param castFlag         asciinema v2 file the terminal drawing is recorded to
+    	@param reportFlag       HTML file a report of the run is written to at the end
+    	@param pane             Where the stats pane is drawn when redrawing in place
+    	@param overlayFlag      Timers drawn in place of the glyphs
+    	@param mouseFlag        Edit a paused run with mouse clicks on the terminal
+    	@param reloadFlag       JSON configuration the tunable parameters are reloaded from
+    	@param pauseOn          Population conditions that pause an interactive run (repeatable)
+    	@param alertOn          Population conditions that raise an alarm as they start to hold (repeatable)
+    	@param breakAt          Chronons an interactive run pauses at, otherwise snapshotted at
+    	@param explainRegion    Cells whose creatures log their decisions every chronon
+    	@param follow           Creature whose history is written, by number or starting ROW,COL
+    	@param followCSV        CSV file the followed creature's history is written to
+    	@param behaviors        Behaviors picking the moves of their species
+    	@param plugins          Go plugins registering further behaviors
+    	@param temperatures     Chance by species that a move is random instead of the behavior\'s pick
 	*/
-	chrononsFlag := flag.Int("chronons", 0, "Number of chronons to run (0 = run forever)")
-	drawFlag := flag.Int("draw", 1, "Draw every N chronons")
-	benchFlag := flag.String("bench", "", "Write benchmark CSV to this file")
+	chrononsFlag := fs.Int("chronons", 0, "Number of chronons to run (0 = run forever)")
+	drawFlag := fs.Int("draw", 1, "Draw every N chronons")
+	benchFlag := fs.String("bench", "", "Write benchmark CSV to this file")
+	headlessFlag := fs.Bool("headless", false, "Print only the final summary, overriding -draw")
+	dryRunFlag := fs.Bool("dry-run", false, "Validate the configuration and print the cells, initial density, rows per worker and estimated memory without running")
+	statsFlag := fs.Int("stats-every", 0, "Print a population/timing summary, with the births, meals and starvations since the last one, every N chronons (0 = off)")
+	statsCSV := fs.String("stats-csv", "", "Append the populations, events and spatial entropies of each -stats-every line to this CSV file")
+	var stopIf Conditions
+	fs.Var(&stopIf, "stop-if", "Stop when a population condition holds, e.g. \"fish<100\" (repeatable)")
+	maxTimeFlag := fs.Duration("max-time", 0, "End the run cleanly after this wall-clock time, e.g. 10m (0 = no limit)")
+	chrononDuration := fs.Duration("chronon-duration", 0, "Pace every chronon to this much wall-clock time, e.g. 100ms, and report simulated against wall time (0 = as fast as possible)")
+	snapshotFlag := fs.String("snapshot", "", "Write the final world state to this JSON file")
+	repsFlag := fs.Int("bench-reps", 1, "Run the configuration N times and report mean, stddev and min time")
+	seedFlag := fs.Int64("seed", 0, "Seed for the random number generator (0 = seed from the clock)")
+	warmupFlag := fs.Int("bench-warmup", 0, "Run N untimed warm-up chronons before measurement begins")
+	diagFlag := fs.Bool("diag", false, "Profile mutex contention, blocking and scheduling latency during the run and print a digest after the benchmark line")
+	autoTuneFlag := fs.Int("auto-tune", 0, "Try worker counts during the first N chronons and keep the fastest for the rest of the run (0 = off)")
+	strategyFlag := fs.String("strategy", strategyThreaded, "How the bands of the workers are run: "+strategyThreaded+" (a goroutine each) or "+strategySerial+" (one after another, so a run repeats with its -seed)")
+	batchFlag := fs.Int("batch", 1, "Let every worker step its rows N chronons on its own, with a halo of rows around them, before the workers synchronise")
+	rngFlag := fs.String("rng", "stdlib", "Random number generator: "+strings.Join(rngKinds, "|"))
+	entityRNG := fs.Bool("entity-rng", false, "Draw each creature's choices from a random stream of its own, so neither unrelated creatures nor the thread count change its trajectory")
+	checkpointFlag := fs.String("checkpoint", "", "Write a resumable checkpoint to this file at the end of the run")
+	checkpointEvery := fs.Int("checkpoint-every", 0, "Also write the checkpoint every N chronons (0 = only at the end)")
+	resumeFlag := fs.String("resume", "", "Continue the run saved in this checkpoint file")
+	var fishRegion, sharkRegion Region
+	fs.Var(&fishRegion, "fish-region", "Place fish only in cells row0,col0,row1,col1 (end exclusive, default whole grid)")
+	fs.Var(&sharkRegion, "shark-region", "Place sharks only in cells row0,col0,row1,col1 (end exclusive, default whole grid)")
+	speciesFlag := fs.String("species", "", "Load a food web of species from this JSON file (NumShark, NumFish, FishBreed, SharkBreed and Starve are then ignored)")
+	orcasFlag := fs.Int("orcas", 0, "Add N orcas, an apex predator that eats sharks (0 = fish and sharks only)")
+	orcaBreed := fs.Int("orca-breed", defaultOrcaBreed, "Chronons between orca reproductions")
+	orcaStarve := fs.Int("orca-starve", defaultOrcaStarve, "Maximum orca energy, an orca starves after this many chronons without a meal")
+	orcaEatsFish := fs.Bool("orca-eats-fish", false, "Let orcas eat fish when no shark is adjacent")
+	corpseDecay := fs.Int("corpse-decay", 0, "Starved predators leave a corpse that decays after N chronons (0 = no corpses)")
+	corpseEnergy := fs.Int("corpse-energy", 2, "Energy a scavenging shark or species gains from eating a corpse")
+	fishMature := fs.Int("fish-mature", 0, "Newborn fish are juveniles that cannot breed for N chronons (0 = no juvenile stage)")
+	juvenileEnergy := fs.Int("juvenile-energy", 2, "Energy a shark gains from eating a juvenile fish")
+	fishEggs := fs.Int("fish-eggs", 0, "Fish lay eggs that hatch after N chronons and sharks can eat (0 = live young)")
+	spawnSeason := fs.Int("spawn-season", 0, "Fish only breed in the first N chronons of every -spawn-year (0 = all year)")
+	spawnYear := fs.Int("spawn-year", 100, "Chronons of a year of spawning seasons")
+	gestationFlag := fs.Int("gestation", 0, "Sharks are pregnant for N chronons after their breed time before giving birth (0 = give birth at once)")
+	gestationCost := fs.Int("gestation-cost", 1, "Extra energy a pregnant shark uses each chronon")
+	weakEnergy := fs.Int("weak-energy", 0, "Sharks with less energy than N are weak (0 = never weak)")
+	weakMode := fs.String("weak-mode", weakSkip, "Weak sharks: "+weakSkip+" (rest every other chronon) or "+weakYield+" (lose contested cells)")
+	cannibalEnergy := fs.Int("cannibal-energy", 0, "Sharks with less energy than N eat neighbouring sharks when no fish is adjacent (0 = no cannibalism)")
+	cannibalGain := fs.Int("cannibal-gain", 2, "Energy a shark gains from eating another shark")
+	territory := fs.Int("territory", 0, "Sharks keep to within N cells of an anchor that drifts after them (0 = no territories)")
+	territoryDrift := fs.Int("territory-drift", 20, "An anchor moves one cell towards its shark with chance 1 in N each chronon (0 = anchors stay)")
+	topologyFlag := fs.String("topology", string(TopologyTorus), "How the grid's edges connect: "+topologyNames("|"))
+	depthFlag := fs.Int("depth", 1, "Number of depth layers, each a GridSize x GridSize grid joined to the layers above and below (1 = flat ocean)")
+	fishDepth := fs.Int("fish-depth", 0, "Layer fish drift towards, 1 being the surface (0 = no preference)")
+	sharkDepth := fs.Int("shark-depth", 0, "Layer sharks drift towards when not hunting, 1 being the surface (0 = no preference)")
+	consoleFlag := fs.String("console", "", "Accept commands such as \"count fish\" or \"pause\" while running, from standard input (-) or this Unix socket")
+	controlFlag := fs.String("control", "", "Accept the commands pause, resume, snapshot, stats and quit on this Unix socket, e.g. /tmp/wator.sock")
+	httpFlag := fs.String("http", "", "Serve expvar counters (chronon, populations, rate, allocations) at /debug/vars on this address, e.g. :6060")
+	otlpFlag := fs.String("otlp", "", "Trace chronon phases (step, draw, count, checkpoint) to this OTLP/HTTP collector, e.g. http://localhost:4318")
+	traceEvery := fs.Int("trace-every", 1, "Trace every Nth chronon when -otlp is set")
+	alertURL := fs.String("alert-url", "", "POST a JSON object to this webhook for every -alert-on alarm and for an extinction (optional)")
+	debugChecks := fs.String("debug-checks", "", "Check the world after each chronon and panic at the first broken invariant: all, or a list of energy, timers and cells")
+	energyLedger := fs.Bool("energy-ledger", false, "Balance the energy gained, born, spent and lost in every chronon against the energy of the world and print the totals at the end")
+	deathsFlag := fs.Bool("deaths", false, "Print how many fish and sharks were eaten, starved or lost otherwise at the end of the run")
+	deathsEvery := fs.Int("deaths-every", 0, "Also print the deaths since the last such line every N chronons, implies -deaths (0 = off)")
+	encountersEvery := fs.Int("encounters-every", 0, "Print the predation rate per shark, its mean-field expectation and the fish densities around sharks and overall every N chronons (0 = off)")
+	lagFlag := fs.Bool("lag", false, "Print how many chronons shark peaks trail fish peaks, from the cross-correlation of the two populations, at the end of the run")
+	lifetimesFlag := fs.String("lifetimes", "", "Follow every creature, print the mean lifetime and offspring count of each species and write their distribution to this CSV file")
+	var blocks BlockGrid
+	fs.Var(&blocks, "blocks", "Divide the grid into RxC blocks, e.g. 4x4, and report the fish and sharks in each")
+	blocksEvery := fs.Int("blocks-every", 1, "Report the per-block counts every N chronons when -blocks is set")
+	blocksCSV := fs.String("blocks-csv", "", "Append the per-block counts to this CSV file instead of printing them")
+	dumpFrames := fs.String("dump-frames", "", "Write the grid of the starting world and every -dump-every chronons to this JSON Lines file")
+	dumpEvery := fs.Int("dump-every", 1, "Write a frame every N chronons when -dump-frames is set")
+	rotateEvery := fs.Int("rotate-every", 0, "Start new numbered stats, blocks and frame files every N chronons (0 = never)")
+	var rotateSize ByteSize
+	fs.Var(&rotateSize, "rotate-size", "Start new numbered stats, blocks and frame files once they reach this size, e.g. 100M (0 = no limit)")
+	rotateKeep := fs.Int("rotate-keep", 0, "Keep only the newest N rotated files of each output (0 = all)")
+	outputQueue := fs.Int("output-queue", 16, "Write frames, timelapse frames and the stats and blocks files on a goroutine of its own, with up to N jobs queued before the step loop waits (0 = write them in the step loop)")
+	crashDump := fs.String("crash-dump", "", "Write the last -crash-frames frames to this JSON Lines file when a species dies out or -stop-if holds")
+	crashFrames := fs.Int("crash-frames", 100, "Number of frames kept in memory for -crash-dump")
+	rewindFlag := fs.Int("rewind", 0, "Keep the last N frames in memory for the console's rewind, forward and live commands")
+	timelapse := fs.String("timelapse", "", "Write an animation of every -stride chronons to this GIF file (optional)")
+	stride := fs.Int("stride", 50, "Add a frame to -timelapse every N chronons")
+	scale := fs.Int("scale", 4, "Pixels per cell in the -timelapse animation")
+	reportFlag := fs.String("report", "", "Write a self-contained HTML report with the population chart, phase plot, final world, parameters and summary to this file at the end (optional)")
+	castFlag := fs.String("cast", "", "Record the terminal drawing, frames and their timing, to this asciinema v2 file for replay (optional)")
+	mouseFlag := fs.Bool("mouse", false, "Read keys and mouse clicks from the terminal, clicks on a paused run place or remove creatures")
+	reloadFlag := fs.String("reload", "", "Apply changed breed, starve, draw and other tunable parameters from this JSON configuration mid-run (optional)")
+	var pauseOn Conditions
+	fs.Var(&pauseOn, "pause-on", "Pause the -console or -mouse run when a population condition starts to hold, e.g. \"sharks<50\" (repeatable)")
+	var alertOn Conditions
+	fs.Var(&alertOn, "alert-on", "Print an alarm, and post it to -alert-url, when a population condition starts to hold, e.g. \"sharks<100\" (repeatable)")
+	var breakAt Breakpoints
+	fs.Var(&breakAt, "break-at", "Pause the -console or -mouse run after these chronons, or write a snapshot of them without one, e.g. 1500,3000 (repeatable)")
+	var explainRegion Region
+	fs.Var(&explainRegion, "explain", "Log the neighbours, candidates, random picks and breed checks of the creatures in cells row0,col0,row1,col1 every chronon")
+	follow := fs.String("follow", "", "Write every move, meal, birth and the death of one creature, given by number or the ROW,COL it starts in, to -follow-csv")
+	followCSV := fs.String("follow-csv", "follow.csv", "CSV file the -follow creature's history is written to")
+	var behaviors, plugins Names
+	fs.Var(&behaviors, "behavior", "Pick the moves of a species with the behavior of this name instead of at random, e.g. wator.Schooling (repeatable)")
+	fs.Var(&plugins, "plugin", "Load a Go plugin (.so) registering further behaviors (repeatable)")
+	var temperatures Temperatures
+	fs.Var(&temperatures, "temperature", "Make a move of SPECIES random with chance T instead of its behavior's pick, e.g. shark=0.25 (0 = fully greedy, 1 = random walk, repeatable)")
+	pane := fs.String("pane", paneSide, "Stats pane when redrawing on a terminal: "+paneSide+", "+paneBottom+" or "+paneNone+" (the population line under the grid)")
+	overlayFlag := fs.String("overlay", overlayNone, "Draw the timers of the creatures as digits in place of their glyphs: "+overlayStarve+" (sharks' chronons before starving), "+overlayBreed+" (fish's chronons until breeding) or "+overlayBoth)
+	hashEvery := fs.Int("hash-every", 0, "Print a digest of the world every N chronons and at the end, for comparing runs (0 = off)")
 
 	// Read in user inputted flags for the program
-	flag.Parse()
+	fs.Parse(args)
 
-// Read the 7 required positional arguments
-args := flag.Args()
-if len(args) < 7 {
-    fmt.Println("Usage: wa-tor NumShark NumFish FishBreed SharkBreed Starve GridSize Threads")
+// Read the 7 required positional arguments (6 when Threads is given another way)
+args = fs.Args()
+required := 6
+usage := "Usage: wa-tor " + fs.Name() + " NumShark NumFish FishBreed SharkBreed Starve GridSize"
+if withThreads {
+    required = 7
+    usage = "Usage: wa-tor NumShark NumFish FishBreed SharkBreed Starve GridSize Threads"
+}
+if len(args) < required {
+    fmt.Println(usage)
     os.Exit(1)
 }
 	//@Error checking
@@ -91,67 +469,151 @@ if err != nil {
     os.Exit(1)
 }
 
-threads, err := strconv.Atoi(args[6])
-if err != nil {
-    fmt.Println("Error: Threads must be an integer.")
-    os.Exit(1)
+threads := 1
+if withThreads {
+    threads, err = strconv.Atoi(args[6])
+    if err != nil {
+        fmt.Println("Error: Threads must be an integer.")
+        os.Exit(1)
+    }
 }
 
 
-	//@	Validation
-	// ensuing that there cant be any negative values or any other incorrect configuration for the simulation
-
-if numShark < 0 {
-    fmt.Println("Error: NumShark must be 0 or greater.")
-    os.Exit(1)
+// Pick the seed explicitly so every run, seeded or not, can be reproduced afterwards
+seed := *seedFlag
+if seed == 0 {
+    seed = time.Now().UnixNano()
 }
 
-if numFish < 0 {
-    fmt.Println("Error: NumFish must be 0 or greater.")
-    os.Exit(1)
-}
-
-if fishBreed <= 0 {
-    fmt.Println("Error: FishBreed must be greater than 0.")
-    os.Exit(1)
-}
-
-if sharkBreed <= 0 {
-    fmt.Println("Error: SharkBreed must be greater than 0.")
-    os.Exit(1)
+cfg := Config{
+    NumShark:        numShark,
+    NumFish:         numFish,
+    FishBreed:       fishBreed,
+    SharkBreed:      sharkBreed,
+    Starve:          starve,
+    GridSize:        gridSize,
+    Threads:         threads,
+    Chronons:        *chrononsFlag,
+    DrawEvery:       *drawFlag,
+    BenchFile:       *benchFlag,
+    Headless:        *headlessFlag,
+    DryRun:          *dryRunFlag,
+    StatsEvery:      *statsFlag,
+    StatsCSV:        *statsCSV,
+    StopIf:          stopIf,
+    MaxTime:         *maxTimeFlag,
+    ChrononDuration: *chrononDuration,
+    Snapshot:        *snapshotFlag,
+    BenchReps:       *repsFlag,
+    Seed:            seed,
+    BenchWarmup:     *warmupFlag,
+    Diag:            *diagFlag,
+    AutoTune:        *autoTuneFlag,
+    Batch:           *batchFlag,
+    Strategy:        *strategyFlag,
+    RNG:             *rngFlag,
+    EntityRNG:       *entityRNG,
+    Checkpoint:      *checkpointFlag,
+    CheckpointEvery: *checkpointEvery,
+    Resume:          *resumeFlag,
+    FishRegion:      fishRegion,
+    SharkRegion:     sharkRegion,
+    CorpseDecay:     *corpseDecay,
+    CorpseEnergy:    *corpseEnergy,
+    FishMature:      *fishMature,
+    JuvenileEnergy:  *juvenileEnergy,
+    FishEggs:        *fishEggs,
+    SpawnSeason:     *spawnSeason,
+    SpawnYear:       *spawnYear,
+    Gestation:       *gestationFlag,
+    GestationCost:   *gestationCost,
+    WeakEnergy:      *weakEnergy,
+    WeakMode:        *weakMode,
+    CannibalEnergy:  *cannibalEnergy,
+    CannibalGain:    *cannibalGain,
+    TerritoryRadius: *territory,
+    TerritoryDrift:  *territoryDrift,
+    Topology:        Topology(*topologyFlag),
+    Depth:           *depthFlag,
+    FishDepth:       *fishDepth,
+    SharkDepth:      *sharkDepth,
+    Console:         *consoleFlag,
+    Control:         *controlFlag,
+    HTTP:            *httpFlag,
+    OTLP:            *otlpFlag,
+    TraceEvery:      *traceEvery,
+    AlertURL:        *alertURL,
+    DebugChecks:     *debugChecks,
+    EnergyLedger:    *energyLedger,
+    Deaths:          *deathsFlag || *deathsEvery > 0,
+    DeathsEvery:     *deathsEvery,
+    EncountersEvery: *encountersEvery,
+    Lag:             *lagFlag,
+    Lifetimes:       *lifetimesFlag,
+    Blocks:          blocks,
+    BlocksEvery:     *blocksEvery,
+    BlocksCSV:       *blocksCSV,
+    DumpFrames:      *dumpFrames,
+    DumpEvery:       *dumpEvery,
+    RotateEvery:     *rotateEvery,
+    RotateSize:      rotateSize,
+    RotateKeep:      *rotateKeep,
+    OutputQueue:     *outputQueue,
+    CrashDump:       *crashDump,
+    CrashFrames:     *crashFrames,
+    Rewind:          *rewindFlag,
+    Timelapse:       *timelapse,
+    TimelapseStride: *stride,
+    TimelapseScale:  *scale,
+    Cast:            *castFlag,
+    Report:          *reportFlag,
+    Pane:            *pane,
+    Overlay:         *overlayFlag,
+    Mouse:           *mouseFlag,
+    Reload:          *reloadFlag,
+    PauseOn:         pauseOn,
+    AlertOn:         alertOn,
+    BreakAt:         breakAt,
+    Explain:         explainRegion,
+    Follow:          *follow,
+    FollowCSV:       *followCSV,
+    Behaviors:       behaviors,
+    Plugins:         plugins,
+    Temperature:     temperatures,
+    HashEvery:       *hashEvery,
 }
 
-if starve <= 0 {
-    fmt.Println("Error: Starve must be greater than 0.")
-    os.Exit(1)
+// Orca parameters only matter, and are only shown, when orcas are added
+if *orcasFlag > 0 {
+    cfg.NumOrca = *orcasFlag
+    cfg.OrcaBreed = *orcaBreed
+    cfg.OrcaStarve = *orcaStarve
+    cfg.OrcaEatsFish = *orcaEatsFish
 }
 
-if gridSize <= 1 {
-    fmt.Println("Error: GridSize must be greater than 1.")
-    os.Exit(1)
+// A species file replaces the fixed fish/shark pair
+if *speciesFlag != "" {
+    web, err := LoadFoodWeb(*speciesFlag)
+    if err != nil {
+        fmt.Printf("Error: %v.\n", err)
+        os.Exit(1)
+    }
+    web.Activate()
+    cfg.FoodWeb = web
 }
 
-if threads < 1 {
-    fmt.Println("Error: Threads must be 1 or greater.")
+	//@	Validation
+	// ensuing that there cant be any negative values or any other incorrect configuration for the simulation
+	// The rules live in Config.Validate, which checks configuration files the same way; its errors are reworded with the flags typed
+if err := cfg.Validate(); err != nil {
+    message := err.Error()
+    var fieldErr *ConfigError
+    if errors.As(err, &fieldErr) {
+        message = fieldErr.Named(flagName)
+    }
+    fmt.Printf("Error: %s.\n", message)
     os.Exit(1)
 }
 
-cfg := Config{
-    NumShark:   numShark,
-    NumFish:    numFish,
-    FishBreed:  fishBreed,
-    SharkBreed: sharkBreed,
-    Starve:     starve,
-    GridSize:   gridSize,
-    Threads:    threads,
-    Chronons:   *chrononsFlag,
-    DrawEvery:  *drawFlag,
-    BenchFile:  *benchFlag,
-}
-
-fmt.Printf("Loaded configuration: %+v\n", cfg)
-world := NewWorld(cfg)
-world.Populate(cfg.NumFish, cfg.NumShark)
-RunSimulation(cfg, world)
-
+return cfg
 }
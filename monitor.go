@@ -0,0 +1,124 @@
+package main
+
+import (
+    "encoding/json"
+    "expvar"
+    "fmt"
+    "net/http"
+    "runtime"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+/**
+    @file monitor.go
+    @brief expvar counters for monitoring a run with standard Go tooling
+    A run started with -http publishes its counters under "wator" at
+    /debug/vars, next to the memstats and cmdline variables expvar always
+    provides. serve-jobs runs its simulations in child processes, so it
+    publishes the number of jobs in each state under "jobs" instead, e.g.
+
+        wa-tor -headless -http :6060 300 2000 3 8 5 1000 4
+        curl localhost:6060/debug/vars
+
+    /frame answers with the grid of the last completed chronon in the frame
+    format of frames.go, taken from Simulator.LatestFrame so it is never
+    caught half written.
+*/
+
+//  @brief Counters of the running simulation, updated every chronon once published
+var runVars struct {
+    once    sync.Once
+    enabled atomic.Bool
+
+    chronon, fish, sharks, orcas expvar.Int
+    chrononsPerSec               expvar.Float
+
+    // Owned by the simulation loop
+    lastTime    time.Time
+    lastChronon int
+}
+
+//  @brief The simulator whose frames /frame serves, nil between runs
+var liveRun atomic.Pointer[Simulator]
+
+//  @brief Makes s the run /frame serves, nil once it has finished
+func watchFrames(s *Simulator) {
+    liveRun.Store(s)
+}
+
+//  @brief Serves the latest frame of the running simulation as JSON
+func serveFrame(w http.ResponseWriter, r *http.Request) {
+    var latest *PublishedFrame
+    if s := liveRun.Load(); s != nil {
+        latest = s.LatestFrame()
+    }
+    if latest == nil {
+        http.Error(w, "no simulation is running", http.StatusServiceUnavailable)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(NewFrame(latest.World, latest.Chronon))
+}
+
+//  @brief Registers the "wator" variables, safe to call more than once
+func publishRunVars() {
+    runVars.once.Do(func() {
+        m := expvar.NewMap("wator")
+        m.Set("chronon", &runVars.chronon)
+        m.Set("fish", &runVars.fish)
+        m.Set("sharks", &runVars.sharks)
+        m.Set("orcas", &runVars.orcas)
+        m.Set("chrononsPerSec", &runVars.chrononsPerSec)
+        m.Set("alloc", expvar.Func(allocStats))
+        runVars.enabled.Store(true)
+    })
+}
+
+//  @brief Allocation statistics of the process, read when /debug/vars is requested
+func allocStats() any {
+    var ms runtime.MemStats
+    runtime.ReadMemStats(&ms)
+    return map[string]uint64{
+        "heapAlloc":  ms.HeapAlloc,
+        "totalAlloc": ms.TotalAlloc,
+        "mallocs":    ms.Mallocs,
+        "frees":      ms.Frees,
+        "numGC":      uint64(ms.NumGC),
+    }
+}
+
+//  @brief Records the state after a chronon, the rate is refreshed about once a second
+func recordChronon(chronon, fish, sharks, orcas int) {
+    if !runVars.enabled.Load() {
+        return
+    }
+    runVars.chronon.Set(int64(chronon))
+    runVars.fish.Set(int64(fish))
+    runVars.sharks.Set(int64(sharks))
+    runVars.orcas.Set(int64(orcas))
+
+    now := time.Now()
+    switch {
+    case runVars.lastTime.IsZero() || chronon < runVars.lastChronon:
+        runVars.lastTime, runVars.lastChronon = now, chronon // a new run
+    case now.Sub(runVars.lastTime) >= time.Second:
+        runVars.chrononsPerSec.Set(float64(chronon-runVars.lastChronon) / now.Sub(runVars.lastTime).Seconds())
+        runVars.lastTime, runVars.lastChronon = now, chronon
+    }
+}
+
+//  @brief Serves /debug/vars on addr in the background for the rest of the process
+func startMonitoring(addr string) {
+    publishRunVars()
+
+    mux := http.NewServeMux()
+    mux.Handle("/debug/vars", expvar.Handler())
+    mux.HandleFunc("/frame", serveFrame)
+    go func() {
+        if err := http.ListenAndServe(addr, mux); err != nil {
+            fmt.Printf("Could not serve monitoring on %s: %v\n", addr, err)
+        }
+    }()
+}
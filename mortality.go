@@ -0,0 +1,62 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+/**
+    @file mortality.go
+    @brief A ledger of deaths by cause, printed with -deaths
+    Starvation and predation are counted as they happen (see Events). Every
+    other death is found by balancing the books: whatever the population
+    before a chronon plus its births does not account for afterwards was
+    lost another way. Wa-Tor has no ageing or disease, so in practice these
+    are creatures lost when two of them claim the same cell in one chronon.
+    Every creature moves at once, so prey can escape in the same chronon it
+    was caught; meals only count as deaths up to the creatures really lost,
+    e.g.
+
+        wa-tor -headless -deaths -chronons 500 300 2000 3 8 5 100 4
+        wa-tor -headless -deaths-every 50 -chronons 500 300 2000 3 8 5 100 4
+*/
+
+//  @brief Mortality counts the fish, shark and orca deaths of each cause
+type Mortality struct {
+    FishEaten, FishOther                    int
+    SharksStarved, SharksEaten, SharksOther int
+    OrcasStarved, OrcasOther                int
+}
+
+//  @brief Population of each creature the ledger balances
+type population struct {
+    fish, sharks, orcas int
+}
+
+//  @brief Records a chronon from the populations before and after it and the events that happened in it
+func (m *Mortality) Record(before, after population, e Events) {
+    fish := before.fish + e.FishBirths - after.fish
+    eaten := min(e.FishEaten, fish)
+    m.FishEaten += eaten
+    m.FishOther += fish - eaten
+
+    sharks := before.sharks + e.SharkBirths - after.sharks - e.SharksStarved
+    eaten = min(e.SharksEaten, sharks)
+    m.SharksStarved += e.SharksStarved
+    m.SharksEaten += eaten
+    m.SharksOther += sharks - eaten
+
+    m.OrcasStarved += e.OrcasStarved
+    m.OrcasOther += before.orcas + e.OrcaBirths - after.orcas - e.OrcasStarved
+}
+
+//  @brief Formats the ledger on one line, leaving out orcas when the run has none
+func (m Mortality) Line(orcas bool) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "Fish: eaten %d, other %d  Sharks: starved %d, eaten %d, other %d",
+        m.FishEaten, m.FishOther, m.SharksStarved, m.SharksEaten, m.SharksOther)
+    if orcas {
+        fmt.Fprintf(&b, "  Orcas: starved %d, other %d", m.OrcasStarved, m.OrcasOther)
+    }
+    return b.String()
+}
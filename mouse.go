@@ -0,0 +1,162 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "os/exec"
+    "strings"
+)
+
+/**
+    @file mouse.go
+    @brief Mouse and key input for editing a paused run on the terminal, enabled with -mouse
+    The terminal is put in raw mode (keeping output processing, so frames
+    draw as usual) and asked for mouse reports (SGR
+    encoding). While the run is paused a left click puts the current brush in
+    the clicked cell and a right click empties it, through the console's
+    place command, so what-if experiments need no typed coordinates, e.g.
+
+        wa-tor -mouse -pane side 30 200 3 8 5 40 1
+
+    Keys:
+
+        space       pause or resume
+        n           step one chronon
+        f, s, o     brush: fish, shark or orca
+        q           quit
+
+    The terminal settings are restored when the run ends.
+*/
+
+//  @brief Key bindings of -mouse, printed when it starts
+const mouseHelp = "Mouse: space pause/resume, n step, f/s/o brush fish/shark/orca, left click place, right click empty, q quit"
+
+//  Escape sequences turning mouse reports on and off
+const (
+    mouseOn  = "\x1b[?1000h\x1b[?1006h" //  Report button presses, encoded as ESC [ < b ; x ; y M
+    mouseOff = "\x1b[?1006l\x1b[?1000l"
+)
+
+//  @brief Starts reading keys and mouse clicks from the terminal on standard input
+//  size and depth are those of the world, used to find the cell under a click as drawn by render.go
+func (c *Console) ServeMouse(size, depth int) error {
+    saved, err := stty("-g")
+    if err != nil {
+        return fmt.Errorf("standard input is not a terminal: %v", err)
+    }
+    if _, err := stty("raw", "-echo", "opost"); err != nil {
+        return err
+    }
+    fmt.Print(mouseOn)
+    c.restore = func() {
+        fmt.Print(mouseOff)
+        stty(strings.TrimSpace(saved))
+    }
+    fmt.Println(mouseHelp)
+
+    go c.serveMouse(bufio.NewReader(os.Stdin), size, depth)
+    return nil
+}
+
+//  @brief Runs stty on the terminal of standard input and returns what it printed
+func stty(args ...string) (string, error) {
+    cmd := exec.Command("stty", args...)
+    cmd.Stdin = os.Stdin
+    out, err := cmd.Output()
+    return string(out), err
+}
+
+//  @brief Turns keys and mouse reports into console commands until the run finishes
+func (c *Console) serveMouse(r *bufio.Reader, size, depth int) {
+    brush, paused := "fish", false
+    for {
+        b, err := r.ReadByte()
+        if err != nil {
+            return
+        }
+
+        var line string
+        switch b {
+        case ' ':
+            paused = !paused
+            line = "resume"
+            if paused {
+                line = "pause"
+            }
+        case 'n':
+            paused, line = true, "step"
+        case 'f':
+            brush = "fish"
+        case 's':
+            brush = "shark"
+        case 'o':
+            brush = "orca"
+        case 'q', 3: // q or ctrl-C, which raw mode no longer turns into a signal
+            line = "quit"
+        case 0x1b:
+            button, x, y, ok := readMouseReport(r)
+            if !ok {
+                continue
+            }
+            row, col, inside := screenCell(size, depth, y, x)
+            switch {
+            case !inside:
+            case button == 0:
+                line = fmt.Sprintf("place %s %d %d", brush, row, col)
+            case button == 2:
+                line = fmt.Sprintf("place water %d %d", row, col)
+            }
+        }
+        if line == "" {
+            continue
+        }
+
+        // answers are not shown, they would be drawn over the grid
+        req := consoleRequest{line: line, reply: make(chan string, 1)}
+        select {
+        case c.requests <- req:
+            <-req.reply
+        case <-c.done:
+            return
+        }
+    }
+}
+
+//  @brief Reads the rest of an SGR mouse report after its ESC, ok only for a button press
+func readMouseReport(r *bufio.Reader) (button, x, y int, ok bool) {
+    for _, want := range []byte("[<") {
+        if b, err := r.ReadByte(); err != nil || b != want {
+            return 0, 0, 0, false
+        }
+    }
+    // a press ends in M, a release in m
+    var report []byte
+    for {
+        b, err := r.ReadByte()
+        if err != nil {
+            return 0, 0, 0, false
+        }
+        if b == 'M' || b == 'm' {
+            if _, err := fmt.Sscanf(string(report), "%d;%d;%d", &button, &x, &y); err != nil {
+                return 0, 0, 0, false
+            }
+            return button, x, y, b == 'M'
+        }
+        report = append(report, b)
+    }
+}
+
+//  @brief Returns the grid cell drawn at the 1-based terminal line and column, the inverse of gridLine
+func screenCell(size, depth, line, column int) (row, col int, inside bool) {
+    row, col = line-2, column-1
+    if depth > 1 {
+        // every layer is drawn under a label line of its own
+        layer := row / (size + 1)
+        if row%(size+1) == 0 {
+            return 0, 0, false
+        }
+        row -= layer + 1
+    }
+    return row, col, row >= 0 && row < size*depth && col >= 0 && col < size
+}
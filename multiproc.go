@@ -0,0 +1,89 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+)
+
+/**
+    @file multiproc.go
+    @brief Multi-process mode: the cluster protocol between processes on one machine
+    Instead of waiting for workers on the network, the coordinator starts each
+    worker as a child process of the same executable ("cluster-worker -stdio")
+    and exchanges halo rows with it over the child's stdin and stdout pipes.
+    Each process steps its band on one goroutine, so comparing this mode to
+    threaded runs (see bench-scale -mode) separates the cost of process-level
+    parallelism from goroutine scaling.
+*/
+
+//  @brief A worker running in a child process, talking to the coordinator over its stdin and stdout
+type processWorker struct {
+    cmd *exec.Cmd
+    io.Reader      //  The child's stdout
+    io.WriteCloser //  The child's stdin
+}
+
+//  @brief Closes the child's stdin and waits for it to exit
+func (p *processWorker) Close() error {
+    p.WriteCloser.Close()
+    return p.cmd.Wait()
+}
+
+//  @brief Starts n worker processes, stopping any already started if one fails to start
+func startProcessWorkers(n int) ([]*processWorker, error) {
+    exe, err := os.Executable()
+    if err != nil {
+        return nil, err
+    }
+
+    workers := make([]*processWorker, 0, n)
+    for i := 0; i < n; i++ {
+        cmd := exec.Command(exe, "cluster-worker", "-stdio")
+        cmd.Stderr = os.Stderr
+        stdin, err := cmd.StdinPipe()
+        if err == nil {
+            var stdout io.Reader
+            if stdout, err = cmd.StdoutPipe(); err == nil {
+                if err = cmd.Start(); err == nil {
+                    workers = append(workers, &processWorker{cmd: cmd, Reader: stdout, WriteCloser: stdin})
+                    continue
+                }
+            }
+        }
+
+        for _, w := range workers {
+            w.Close()
+        }
+        return nil, fmt.Errorf("could not start worker process %d: %v", i, err)
+    }
+    return workers, nil
+}
+
+//  @brief Runs cfg split across n worker processes and returns the aggregated result
+func RunProcesses(cfg Config, n int) (RunResult, error) {
+    workers, err := startProcessWorkers(n)
+    if err != nil {
+        return RunResult{}, err
+    }
+
+    conns := make([]io.ReadWriter, n)
+    for i, w := range workers {
+        conns[i] = w
+    }
+    result, err := coordinateCluster(cfg, conns)
+
+    for i, w := range workers {
+        if werr := w.Close(); werr != nil && err == nil {
+            err = fmt.Errorf("worker process %d: %v", i, werr)
+        }
+    }
+    return result, err
+}
+
+//  @brief stdio joins the process's stdin and stdout into the connection of a spawned worker
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
@@ -0,0 +1,90 @@
+package main
+
+/**
+    @file overlay.go
+    @brief Timers of the creatures drawn in place of their glyphs, enabled with -overlay
+    The drawing normally shows only what each cell holds. With an overlay
+    the timers behind the dynamics are drawn instead, as the digit of the
+    chronons left, 9 standing for 9 or more:
+
+        starve  every shark shows the chronons it has left before it starves
+                unless it eats, 0 meaning it starves in the coming chronon
+        breed   every fish shows the chronons until it next breeds, those of
+                reaching maturity included for juveniles
+        both    both at once
+
+    On a terminal fish digits are blue and shark digits red, and a 0 is
+    bold, so the two can be told apart and the creatures about to starve or
+    breed stand out, e.g.
+
+        wa-tor -overlay both -draw 1 300 2000 3 8 5 40 4
+
+    Output to files and pipes has no colours, so there -overlay starve or
+    breed keeps the digits unambiguous.
+*/
+
+//  @brief Overlays of the drawing
+const (
+    overlayNone   = ""
+    overlayStarve = "starve"
+    overlayBreed  = "breed"
+    overlayBoth   = "both"
+)
+
+//  @brief Marks the overlay key of a shark digit, keeping it apart from the same fish digit
+const overlaySharkKey = 0x80
+
+//  @brief ANSI colours of the overlay digits
+const (
+    ansiBlue  = "\x1b[34m"
+    ansiRed   = "\x1b[31m"
+    ansiBold  = "\x1b[1m"
+    ansiReset = "\x1b[0m"
+)
+
+//  @brief Reports whether overlay names an overlay of the drawing
+func validOverlay(overlay string) bool {
+    return overlay == overlayNone || overlay == overlayStarve || overlay == overlayBreed || overlay == overlayBoth
+}
+
+//  @brief Returns the digit of n chronons, 9 standing for 9 or more
+func overlayDigit(n int) byte {
+    return '0' + byte(min(max(n, 0), 9))
+}
+
+//  @brief Returns the key the cell at (row, col) of w is drawn with: its glyph, or its timer digit under the overlay
+//  A shark digit carries overlaySharkKey, so remembered keys tell a changed colour from an unchanged cell
+func (r *Renderer) cellKey(w *World, row, col int) byte {
+    c := w.Cells[row][col]
+    switch {
+    case c.Entity == Shark && (r.overlay == overlayStarve || r.overlay == overlayBoth):
+        // starving once the energy left after the coming chronon's cost is gone
+        return overlayDigit(c.Energy-1) | overlaySharkKey
+    case c.Entity == Fish && (r.overlay == overlayBreed || r.overlay == overlayBoth):
+        left := w.FishBreed - c.BreedTimer - 1
+        if c.Stage == Juvenile {
+            // the breed timer restarts as the fish matures
+            left = w.FishMature - c.BreedTimer - 1 + w.FishBreed
+        }
+        return overlayDigit(left)
+    }
+    return cellGlyph(c.Entity)
+}
+
+//  @brief Appends the cell drawn with key, coloured on a terminal when it is an overlay digit
+func (r *Renderer) appendKey(buf []byte, key byte) []byte {
+    digit := key &^ overlaySharkKey
+    if !r.ansi || r.overlay == overlayNone || digit < '0' || digit > '9' {
+        return append(buf, digit)
+    }
+    if key&overlaySharkKey != 0 {
+        buf = append(buf, ansiRed...)
+    } else {
+        buf = append(buf, ansiBlue...)
+    }
+    if digit == '0' {
+        buf = append(buf, ansiBold...)
+    }
+    buf = append(buf, digit)
+    return append(buf, ansiReset...)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+    "fmt"
+    "time"
+)
+
+/**
+    @file pace.go
+    @brief Chronons paced to a fixed length of wall-clock time, enabled with -chronon-duration
+    Each chronon is taken to last the given simulated time, and the step
+    loop waits before stepping until the chronon is due, so a run plays
+    back at the same speed on a fast laptop and a slow classroom machine,
+    e.g.
+
+        wa-tor -chronon-duration 100ms -chronons 300 300 2000 3 8 5 40 4
+
+    A chronon that starts late, because the machine could not keep up or
+    the run was paused, moves the schedule on instead of the following
+    chronons hurrying to catch up. After the run the simulated time is
+    printed against the wall-clock time with the chronons that started late,
+    e.g. "Pacing  simulated 30s  wall 30.4s  0.99x real time  late 4 of 300
+    waited 21.7s", the time waited showing how much faster the machine is.
+*/
+
+//  @brief Pacer holds the step loop back until each chronon is due
+type Pacer struct {
+    every  time.Duration //  Simulated length of one chronon
+    start  time.Time     //  When the chronon first, or the one the schedule last moved to, was due
+    first  int           //  Chronon start was due at
+    begin  time.Time     //  When pacing began, for the report
+    from   int           //  Chronon pacing began after
+    paced  int           //  Chronons paced
+    late   int           //  Chronons that were due before the loop reached them
+    waited time.Duration //  Time spent waiting for chronons to be due
+}
+
+//  @brief Creates a pacer for chronons lasting every, starting with the chronon after chronon, or returns nil when every is 0
+func NewPacer(every time.Duration, chronon int) *Pacer {
+    if every <= 0 {
+        return nil
+    }
+    now := time.Now()
+    return &Pacer{every: every, start: now, first: chronon, begin: now, from: chronon}
+}
+
+//  @brief Waits until the chronon after chronon is due, moving the schedule on when it is already late
+func (p *Pacer) Wait(chronon int) {
+    if p == nil {
+        return
+    }
+    p.paced++
+    due := p.start.Add(time.Duration(chronon-p.first) * p.every)
+    now := time.Now()
+    switch wait := due.Sub(now); {
+    case wait > 0:
+        time.Sleep(wait)
+        p.waited += wait
+    case -wait > p.every/10:
+        // more than a tenth of a chronon late: start the schedule again from now
+        p.late++
+        p.start, p.first = now, chronon
+    }
+}
+
+//  @brief Waits out the last chronon, up to chronon, so that it too lasts its simulated time
+func (p *Pacer) Finish(chronon int) {
+    if p == nil {
+        return
+    }
+    if wait := time.Until(p.start.Add(time.Duration(chronon-p.first) * p.every)); wait > 0 {
+        time.Sleep(wait)
+        p.waited += wait
+    }
+}
+
+//  @brief Formats the simulated time of the chronons run up to chronon against the wall-clock time since pacing began
+func (p *Pacer) Line(chronon int) string {
+    wall := time.Since(p.begin)
+    simulated := time.Duration(chronon-p.from) * p.every
+    speed := 0.0
+    if wall > 0 {
+        speed = float64(simulated) / float64(wall)
+    }
+    return fmt.Sprintf("Pacing  simulated %v  wall %v  %.2fx real time  late %d of %d  waited %v",
+        simulated, wall.Round(time.Millisecond), speed, p.late, p.paced, p.waited.Round(time.Millisecond))
+}
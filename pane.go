@@ -0,0 +1,88 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+/**
+    @file pane.go
+    @brief Stats pane drawn beside or below the grid when redrawing in place
+    When frames are redrawn on a terminal, the population of every species,
+    the births and deaths of the chronon, the chronons per second and the
+    seed are shown one per line in a pane of their own, -pane side placing it
+    to the right of the grid and -pane bottom below it, e.g.
+
+        wa-tor -pane bottom 300 2000 3 8 5 60 4
+
+    Each line of the pane is redrawn in place on every frame while the grid
+    keeps drawing only the cells that changed, so the two never interleave.
+    -pane none keeps the single population line under the grid. Output to
+    files and pipes always uses that line.
+*/
+
+//  @brief Layouts of the stats pane
+const (
+    paneSide   = "side"
+    paneBottom = "bottom"
+    paneNone   = "none"
+)
+
+//  @brief Columns left between the grid and a side pane
+const paneGap = 3
+
+//  @brief Reports whether layout names a stats pane layout
+func validPane(layout string) bool {
+    return layout == paneSide || layout == paneBottom || layout == paneNone
+}
+
+//  @brief StatsPane draws the live numbers of a run into a fixed region of the terminal
+type StatsPane struct {
+    layout string
+    seed   int64
+    start  time.Time //  When the first frame was drawn
+    first  int       //  Chronon of the first frame
+    drawn  bool
+}
+
+//  @brief Creates a pane with the given layout showing seed, nil for paneNone
+func NewStatsPane(layout string, seed int64) *StatsPane {
+    if layout == paneNone {
+        return nil
+    }
+    return &StatsPane{layout: layout, seed: seed}
+}
+
+//  @brief Returns the lines of the pane for w at chronon
+func (p *StatsPane) lines(w *World, chronon int) []string {
+    if !p.drawn {
+        p.start, p.first, p.drawn = time.Now(), chronon, true
+    }
+    rate := 0.0
+    if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+        rate = float64(chronon-p.first) / elapsed
+    }
+
+    lines := strings.Split(populationLine(w), "  ")
+    lines = append(lines, strings.Split(w.Events.String(), "  ")...)
+    return append(lines, fmt.Sprintf("Chronons/sec: %.1f", rate), "Seed: "+strconv.FormatInt(p.seed, 10))
+}
+
+//  @brief Appends the pane for w at chronon, each line cursor-positioned, and returns the line below everything drawn
+func (p *StatsPane) appendTo(buf []byte, w *World, chronon int) ([]byte, int) {
+    below := gridLine(w, w.Rows()-1) + 1
+    line, col := 2, w.Size+paneGap
+    if p.layout == paneBottom {
+        line, col = below, 1
+    }
+
+    for _, text := range p.lines(w, chronon) {
+        buf = appendCursor(buf, line, col)
+        buf = append(buf, text...)
+        buf = append(buf, ansiClearLine...)
+        line++
+    }
+    return buf, max(below, line)
+}
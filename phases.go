@@ -0,0 +1,49 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+/**
+    @file phases.go
+    @brief Where the time of a run went, printed after the benchmark line
+    The step loop times its phases separately:
+
+        step   computing the chronons in the workers, writes into the next
+               world under the step mutex included (-diag breaks those out)
+        count  counting the populations after every chronon
+        draw   handing the worlds to the renderer
+        io     block, stats and hash lines, handing frame dumps, timelapse
+               samples and the stats and blocks files to the output goroutine
+               (see pipeline.go), and checkpoints
+        other  everything else, such as the console, pauses and the
+               lifetime tracker
+
+    e.g. "Phases  step 412ms 81%  count 61ms 12%  draw 0s 0%  io 3ms 1%
+    other 31ms 6%" shows how much of a small-grid benchmark is not stepping.
+*/
+
+//  @brief PhaseTimes adds up the time the step loop spends in each phase
+type PhaseTimes struct {
+    Step, Count, Draw, IO time.Duration
+}
+
+//  @brief Formats the phases with their share of total, the rest of total being other
+func (p PhaseTimes) Line(total time.Duration) string {
+    other := total - p.Step - p.Count - p.Draw - p.IO
+    var b strings.Builder
+    b.WriteString("Phases")
+    for _, phase := range []struct {
+        name string
+        d    time.Duration
+    }{{"step", p.Step}, {"count", p.Count}, {"draw", p.Draw}, {"io", p.IO}, {"other", max(other, 0)}} {
+        share := 0.0
+        if total > 0 {
+            share = 100 * float64(phase.d) / float64(total)
+        }
+        fmt.Fprintf(&b, "  %s %v %.0f%%", phase.name, phase.d.Round(time.Millisecond), share)
+    }
+    return b.String()
+}
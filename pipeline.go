@@ -0,0 +1,84 @@
+package main
+
+import (
+    "fmt"
+    "time"
+)
+
+/**
+    @file pipeline.go
+    @brief File output of the step loop written on a goroutine of its own
+    Frame dumps, timelapse frames and the stats and blocks files are encoded
+    and written by an output goroutine, in the order the chronons produced
+    them, while the step loop goes on with the next chronon. The jobs read
+    the published view of their chronon (see Simulator.LatestFrame), which
+    nothing writes to any more. Drawing has a goroutine of its own already,
+    see AsyncRenderer.
+
+    Up to -output-queue jobs wait for the output goroutine. A slow disk
+    then holds the step loop back, rather than losing rows or letting the
+    queue grow without bound, and a run that waited says for how long, e.g.
+
+        Output  queue full 42 times, the step loop waited 1.21s
+
+    -output-queue 0 writes everything in the step loop, as before.
+*/
+
+//  @brief OutputPipeline runs output jobs in submission order, on a goroutine of its own unless its depth is 0
+type OutputPipeline struct {
+    jobs chan func()   //  Jobs waiting for the output goroutine, nil when writing in the step loop
+    done chan struct{} //  Closed once the output goroutine has finished
+
+    full   int           //  Submissions that found the queue full
+    waited time.Duration //  Time the step loop spent waiting for room
+}
+
+//  @brief Starts an output goroutine with room for depth jobs, or none for 0
+func NewOutputPipeline(depth int) *OutputPipeline {
+    p := &OutputPipeline{}
+    if depth == 0 {
+        return p
+    }
+    p.jobs = make(chan func(), depth)
+    p.done = make(chan struct{})
+    go func() {
+        defer close(p.done)
+        for job := range p.jobs {
+            job()
+        }
+    }()
+    return p
+}
+
+//  @brief Queues job, waiting while the queue is full, or runs it right away without an output goroutine
+func (p *OutputPipeline) Submit(job func()) {
+    if p.jobs == nil {
+        job()
+        return
+    }
+    select {
+    case p.jobs <- job:
+        return
+    default:
+    }
+    start := time.Now()
+    p.jobs <- job
+    p.full++
+    p.waited += time.Since(start)
+}
+
+//  @brief Runs the jobs still queued and stops the output goroutine
+func (p *OutputPipeline) Close() {
+    if p.jobs == nil {
+        return
+    }
+    close(p.jobs)
+    <-p.done
+}
+
+//  @brief Prints how often and how long the step loop waited for the output goroutine, if it ever did
+func (p *OutputPipeline) Report() {
+    if p.full > 0 {
+        fmt.Printf("Output  queue full %d times, the step loop waited %v\n", p.full, p.waited.Round(time.Millisecond))
+    }
+}
@@ -0,0 +1,53 @@
+package main
+
+import "sync"
+
+/**
+    @file pool.go
+    @brief The pool of goroutines sweep, tune and ensemble share their runs out on
+    Each of them has a number of independent runs, numbered from 0, and a
+    -jobs or -workers limit on how many run at once. runJobs hands the
+    numbers out to that many goroutines and reports every run that
+    succeeds, so the callers only say what one run does.
+*/
+
+//  @brief Calls run for every i from 0 to n-1 on up to jobs goroutines, and done with i after each run that succeeds
+//  done is called from one goroutine at a time, so it may print and write files without further locking; it may be nil.
+//  Returns the first error; runs already started are finished first.
+func runJobs(n, jobs int, run func(i int) error, done func(i int)) error {
+    next := make(chan int)
+    var mu sync.Mutex // serialises done and firstErr
+    var firstErr error
+    var wg sync.WaitGroup
+
+    for j := 0; j < min(jobs, n); j++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range next {
+                err := run(i)
+                mu.Lock()
+                if err != nil && firstErr == nil {
+                    firstErr = err
+                }
+                if err == nil && done != nil {
+                    done(i)
+                }
+                mu.Unlock()
+            }
+        }()
+    }
+
+    for i := 0; i < n; i++ {
+        mu.Lock()
+        failed := firstErr != nil
+        mu.Unlock()
+        if failed {
+            break
+        }
+        next <- i
+    }
+    close(next)
+    wg.Wait()
+    return firstErr
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+/**
+    @file presets.go
+    @brief The presets subcommand: named configurations kept in the user's config directory
+    A preset is saved from the arguments of a normal run and stored as a
+    JSON configuration (see config.go) in wa-tor/presets under the user
+    config directory (e.g. ~/.config on Linux), e.g.
+
+        wa-tor presets save crowded -chronons 500 -headless 3000 20000 3 8 5 200 4
+        wa-tor presets list
+        wa-tor presets run crowded
+
+    A preset saved without -seed keeps seeding each run from the clock.
+*/
+
+//  @brief Characters allowed in a preset name, which is also its file name
+var presetName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+//  @brief Returns the directory presets are stored in
+func presetDir() (string, error) {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "wa-tor", "presets"), nil
+}
+
+//  @brief Returns the file the named preset is stored in
+func presetPath(name string) (string, error) {
+    if !presetName.MatchString(name) {
+        return "", fmt.Errorf("preset names may only contain letters, digits, - and _")
+    }
+    dir, err := presetDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, name+".json"), nil
+}
+
+//  @brief Stores cfg as the named preset, replacing any preset of that name
+func SavePreset(name string, cfg Config) error {
+    path, err := presetPath(name)
+    if err != nil {
+        return err
+    }
+    data, err := MarshalConfig(cfg)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return err
+    }
+    return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+//  @brief Reads and validates the named preset
+func LoadPreset(name string) (Config, error) {
+    path, err := presetPath(name)
+    if err != nil {
+        return Config{}, err
+    }
+    if _, err := os.Stat(path); os.IsNotExist(err) {
+        return Config{}, fmt.Errorf("there is no preset called %s", name)
+    }
+    return LoadConfig(path)
+}
+
+//  @brief Returns the names of every stored preset in alphabetical order
+func ListPresets() ([]string, error) {
+    dir, err := presetDir()
+    if err != nil {
+        return nil, err
+    }
+    entries, err := os.ReadDir(dir)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var names []string
+    for _, e := range entries {
+        if name, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() && presetName.MatchString(name) {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+    return names, nil
+}
+
+//  @brief Entry point of the presets subcommand: save NAME ARGS..., list, show NAME, run NAME or delete NAME
+func runPresets(args []string) {
+    usage := "Usage: wa-tor presets save NAME [flags] NumShark NumFish FishBreed SharkBreed Starve GridSize Threads | list | show NAME | run NAME | delete NAME"
+    if len(args) < 1 || (args[0] != "list" && len(args) < 2) {
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+    command, name := args[0], ""
+    if len(args) > 1 {
+        name = args[1]
+    }
+
+    var err error
+    switch command {
+    case "save":
+        fs := flag.NewFlagSet("presets save", flag.ExitOnError)
+        cfg := parseConfig(fs, args[2:], true)
+
+        // parseConfig always picks a seed, only keep one that was asked for
+        seeded := false
+        fs.Visit(func(f *flag.Flag) { seeded = seeded || f.Name == "seed" })
+        if !seeded {
+            cfg.Seed = 0
+        }
+
+        if err = SavePreset(name, cfg); err == nil {
+            fmt.Printf("Saved preset %s\n", name)
+        }
+    case "list":
+        var names []string
+        if names, err = ListPresets(); err == nil {
+            for _, n := range names {
+                if cfg, err := LoadPreset(n); err != nil {
+                    fmt.Printf("%-20s  (%v)\n", n, err)
+                } else {
+                    fmt.Printf("%-20s  Grid: %d  Fish: %d  Sharks: %d  Chronons: %d\n", n, cfg.GridSize, cfg.NumFish, cfg.NumShark, cfg.Chronons)
+                }
+            }
+        }
+    case "show":
+        var cfg Config
+        if cfg, err = LoadPreset(name); err == nil {
+            var data []byte
+            if data, err = MarshalConfig(cfg); err == nil {
+                fmt.Println(string(data))
+            }
+        }
+    case "run":
+        var cfg Config
+        if cfg, err = LoadPreset(name); err == nil {
+            runLoadedConfig(cfg)
+        }
+    case "delete":
+        var path string
+        if path, err = presetPath(name); err == nil {
+            if err = os.Remove(path); os.IsNotExist(err) {
+                err = fmt.Errorf("there is no preset called %s", name)
+            } else if err == nil {
+                fmt.Printf("Deleted preset %s\n", name)
+            }
+        }
+    default:
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+
+    if err != nil {
+        fmt.Printf("Error: %v.\n", err)
+        os.Exit(1)
+    }
+}
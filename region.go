@@ -0,0 +1,86 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+/**
+    @file region.go
+    @brief Rectangular regions of the grid
+    A region is written "row0,col0,row1,col1" and covers rows row0 to row1-1
+    and columns col0 to col1-1, so on a 100x100 grid the left half is
+    "0,0,100,50". The zero Region stands for the whole grid.
+*/
+
+//  @brief Region is a half-open rectangle of cells
+type Region struct {
+    Row0, Col0 int //  First row and column inside the region
+    Row1, Col1 int //  First row and column past the region
+}
+
+//  @brief Reports whether the region is unset, i.e. means the whole grid
+func (r Region) IsZero() bool {
+    return r == Region{}
+}
+
+//  @brief Returns the region itself, or the whole grid of the given size for the zero Region
+func (r Region) Resolve(size int) Region {
+    if r.IsZero() {
+        return Region{Row0: 0, Col0: 0, Row1: size, Col1: size}
+    }
+    return r
+}
+
+//  @brief Checks that the region is non-empty and lies inside a grid of the given size
+func (r Region) Validate(size int) error {
+    r = r.Resolve(size)
+    if r.Row0 < 0 || r.Col0 < 0 || r.Row1 > size || r.Col1 > size {
+        return fmt.Errorf("region %s does not fit in a %dx%d grid", r, size, size)
+    }
+    if r.Row0 >= r.Row1 || r.Col0 >= r.Col1 {
+        return fmt.Errorf("region %s is empty", r)
+    }
+    return nil
+}
+
+//  @brief Reports whether the cell (row, col) lies inside the region
+func (r Region) Contains(row, col int) bool {
+    return row >= r.Row0 && row < r.Row1 && col >= r.Col0 && col < r.Col1
+}
+
+//  @brief Formats the region as "row0,col0,row1,col1" (flag.Value)
+func (r Region) String() string {
+    return fmt.Sprintf("%d,%d,%d,%d", r.Row0, r.Col0, r.Row1, r.Col1)
+}
+
+//  @brief Parses a region written "row0,col0,row1,col1" (flag.Value)
+func (r *Region) Set(s string) error {
+    parts := strings.Split(s, ",")
+    if len(parts) != 4 {
+        return fmt.Errorf("region %q must be row0,col0,row1,col1", s)
+    }
+
+    var v [4]int
+    for i, part := range parts {
+        n, err := strconv.Atoi(strings.TrimSpace(part))
+        if err != nil {
+            return fmt.Errorf("region %q must contain integers only", s)
+        }
+        v[i] = n
+    }
+
+    *r = Region{Row0: v[0], Col0: v[1], Row1: v[2], Col1: v[3]}
+    return nil
+}
+
+//  @brief Writes the region as "row0,col0,row1,col1", the form used in configuration files (encoding.TextMarshaler)
+func (r Region) MarshalText() ([]byte, error) {
+    return []byte(r.String()), nil
+}
+
+//  @brief Parses a region written "row0,col0,row1,col1" (encoding.TextUnmarshaler)
+func (r *Region) UnmarshalText(text []byte) error {
+    return r.Set(string(text))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
+    "time"
+)
+
+/**
+    @file reload.go
+    @brief Applying changes to a configuration file while the run goes on
+    With -reload FILE (or wa-tor config watch FILE) the file is checked once
+    a second, and when it has been saved again, or the process receives
+    SIGHUP, the parameters the console's set command can change (breed
+    times, starvation, draw frequency and the others of consoleSettings) are
+    read from it and take effect from the next chronon. Every change is
+    logged with its chronon:
+
+        Chronon: 1200  Reloaded run.json  fishbreed 3 -> 4  drawevery 1 -> 10
+
+    Only the fields written in the file are considered, so it can hold just
+    the parameters being tuned; other fields such as the grid size are left
+    as the run started. The console's reload command reads it at once.
+*/
+
+//  @brief How often the reloaded file is checked for changes
+const reloadInterval = time.Second
+
+//  @brief ConfigWatcher notices when a configuration file has been saved again or SIGHUP asks for a reload
+type ConfigWatcher struct {
+    path    string
+    mod     time.Time //  Modification time of the file when last read
+    checked time.Time //  When the file was last looked at
+    hup     chan os.Signal
+}
+
+//  @brief Starts watching the configuration file at path
+func NewConfigWatcher(path string) *ConfigWatcher {
+    cw := &ConfigWatcher{path: path, checked: time.Now(), hup: make(chan os.Signal, 1)}
+    if info, err := os.Stat(path); err == nil {
+        cw.mod = info.ModTime()
+    }
+    signal.Notify(cw.hup, syscall.SIGHUP)
+    return cw
+}
+
+//  @brief Reports whether the file should be read again, at most once per reloadInterval unless SIGHUP arrived
+func (cw *ConfigWatcher) Due() bool {
+    select {
+    case <-cw.hup:
+        return true
+    default:
+    }
+    if time.Since(cw.checked) < reloadInterval {
+        return false
+    }
+    cw.checked = time.Now()
+    info, err := os.Stat(cw.path)
+    if err != nil || info.ModTime().Equal(cw.mod) {
+        return false
+    }
+    cw.mod = info.ModTime()
+    return true
+}
+
+//  @brief Stops listening for SIGHUP
+func (cw *ConfigWatcher) Close() {
+    signal.Stop(cw.hup)
+}
+
+//  @brief Reads the tunable parameters written in the configuration file at path into the run, returning each change as "name old -> new"
+func (s *Simulator) Reload(path string) ([]string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    // fields missing from the file keep the values of the run
+    next := s.Config
+    if err := json.Unmarshal(data, &next); err != nil {
+        return nil, fmt.Errorf("configuration %s: %v", path, err)
+    }
+
+    var changes []string
+    for _, setting := range consoleSettings {
+        old, value := *setting.field(&s.Config), *setting.field(&next)
+        if value == old || (strings.HasPrefix(setting.name, "orca") && s.Config.NumOrca == 0) {
+            continue
+        }
+        if value < setting.min {
+            return nil, fmt.Errorf("configuration %s: %s must be an integer of %d or greater", path, setting.name, setting.min)
+        }
+        changes = append(changes, fmt.Sprintf("%s %d -> %d", setting.name, old, value))
+    }
+    for _, setting := range consoleSettings {
+        if !strings.HasPrefix(setting.name, "orca") || s.Config.NumOrca > 0 {
+            *setting.field(&s.Config) = *setting.field(&next)
+        }
+    }
+
+    // as for the console's set command, the world carries the rules it is stepped with
+    s.World.FishBreed, s.World.SharkBreed, s.World.Starve = s.Config.FishBreed, s.Config.SharkBreed, s.Config.Starve
+    s.World.OrcaBreed, s.World.OrcaStarve = s.Config.OrcaBreed, s.Config.OrcaStarve
+    return changes, nil
+}
+
+//  @brief Reloads path and returns the log line of what changed, the same for the watcher and the console
+func (s *Simulator) reloadLine(path string) string {
+    changes, err := s.Reload(path)
+    switch {
+    case err != nil:
+        return fmt.Sprintf("Chronon: %d  Error: could not reload %v", s.Chronon, err)
+    case len(changes) == 0:
+        return fmt.Sprintf("Chronon: %d  Reloaded %s  no changes", s.Chronon, path)
+    }
+    return fmt.Sprintf("Chronon: %d  Reloaded %s  %s", s.Chronon, path, strings.Join(changes, "  "))
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "time"
+)
+
+/**
+    @file render.go
+    @brief Streaming text renderer for the Wa-Tor world grid
+    A frame (header, grid and population footer) is built in a reusable byte
+    buffer and written with a single Write call. Only very large grids are
+    split into bands of rows, so that no more than one band is ever held
+    in memory no matter how large the world is (10k x 10k and beyond).
+    When writing to a terminal, frames are redrawn in place using ANSI
+    escape sequences so the output becomes an animation rather than a scroll,
+    and after the first frame only the cells that changed are redrawn. The
+    population and other live numbers then go to the stats pane of pane.go.
+    A layered ocean is drawn one depth layer below the other, each under a
+    "Layer N" label. An overlay draws the timers of the creatures instead of
+    their glyphs, see overlay.go.
+    Drawing runs on its own goroutine; when a terminal cannot keep up,
+    frames are dropped instead of stalling the simulation. Output to files
+    and pipes keeps every frame.
+*/
+
+//  @brief Maximum number of bytes buffered before a band is flushed to the output
+const bandBytes = 1 << 20
+
+//  ANSI escape sequences used for in-place redraws
+const (
+    ansiHome      = "\x1b[H"  //  Move the cursor to the top-left corner
+    ansiClear     = "\x1b[2J" //  Clear the whole screen
+    ansiClearLine = "\x1b[K"  //  Clear from the cursor to the end of the line
+)
+
+//  @brief Renderer draws frames of the world to a writer, reusing its buffer between frames
+type Renderer struct {
+    out     io.Writer  //  Destination of every frame
+    buf     []byte     //  Frame buffer, kept between frames to avoid reallocation
+    ansi    bool       //  Redraw frames in place instead of scrolling
+    drawn   bool       //  Whether a frame has already been drawn
+    prev    []byte     //  Keys of the last drawn frame, used to redraw only changed cells
+    pane    *StatsPane //  Live numbers drawn beside or below the grid in ANSI mode, nil for the population footer
+    overlay string     //  Timers drawn in place of the glyphs, see overlay.go
+}
+
+//  @brief Creates a renderer that writes frames to out
+//  @param "ansi" Redraw every frame over the previous one using escape sequences
+func NewRenderer(out io.Writer, ansi bool) *Renderer {
+    return &Renderer{out: out, ansi: ansi}
+}
+
+//  @brief Reports whether f is attached to a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+    info, err := f.Stat()
+    if err != nil {
+        return false
+    }
+    return info.Mode()&os.ModeCharDevice != 0
+}
+
+//  @brief Returns the character used to draw the given entity
+func cellGlyph(e Entity) byte {
+    switch e {
+    case Fish:
+        return 'F'
+    case Shark:
+        return 'S'
+    case Orca:
+        return 'O'
+    case Corpse:
+        return 'x'
+    case Egg:
+        return 'e'
+    }
+    if sp := speciesOf(e); sp != nil {
+        return sp.Glyph[0]
+    }
+    return '~'
+}
+
+//  @brief Returns the entity drawn with the given character, the inverse of cellGlyph
+func entityForGlyph(g byte) (Entity, bool) {
+    switch g {
+    case '~':
+        return Empty, true
+    case 'F':
+        return Fish, true
+    case 'S':
+        return Shark, true
+    case 'O':
+        return Orca, true
+    case 'x':
+        return Corpse, true
+    }
+    if sp := speciesByGlyph(g); sp != nil {
+        return sp.entity, true
+    }
+    // eggs only exist without a food web, whose species may draw themselves with an e
+    if g == 'e' {
+        return Egg, true
+    }
+    return Empty, false
+}
+
+//  @brief Draws one frame: the chronon header, the grid and the population counts
+//  @param "w" The world to render
+//  @param "chronon" The chronon number shown in the header
+func (r *Renderer) Draw(w *World, chronon int) error {
+    buf := r.buf[:0]
+
+    // Return to the top of the screen, clearing it once before the first frame
+    if r.ansi {
+        if !r.drawn {
+            buf = append(buf, ansiClear...)
+        }
+        buf = append(buf, ansiHome...)
+    }
+    r.drawn = true
+
+    buf = append(buf, "Chronon: "...)
+    buf = strconv.AppendInt(buf, int64(chronon), 10)
+    if r.ansi {
+        buf = append(buf, ansiClearLine...)
+    }
+    buf = append(buf, '\n')
+
+    var err error
+    if r.ansi && len(r.prev) == w.Rows()*w.Size {
+        buf, err = r.appendChangedCells(buf, w)
+    } else {
+        buf, err = r.appendAllCells(buf, w)
+    }
+    if err != nil {
+        return err
+    }
+
+    if r.ansi && r.pane != nil {
+        // the pane redraws its own lines, then the cursor rests below it and the grid
+        var below int
+        buf, below = r.pane.appendTo(buf, w, chronon)
+        buf = appendCursor(buf, below+1, 1)
+        r.buf = buf
+        _, err = r.out.Write(buf)
+        return err
+    }
+
+    if r.ansi {
+        // Place the footer below the grid, since a differential frame leaves the cursor anywhere
+        buf = appendCursor(buf, gridLine(w, w.Rows()-1)+1, 1)
+    }
+    buf = append(buf, populationLine(w)...)
+    if r.ansi {
+        // Counts can shrink, so clear what the previous footer left behind
+        buf = append(buf, ansiClearLine...)
+    }
+    buf = append(buf, "\n\n"...)
+
+    r.buf = buf
+    _, err = r.out.Write(buf)
+    return err
+}
+
+//  @brief Writes buf to the output once it holds a full band, returning the buffer to append to next
+//  Flushing bands keeps huge grids from ever needing the whole frame in memory
+func (r *Renderer) flushBand(buf []byte) ([]byte, error) {
+    if len(buf) < bandBytes {
+        return buf, nil
+    }
+    if _, err := r.out.Write(buf); err != nil {
+        return buf, err
+    }
+    return buf[:0], nil
+}
+
+//  @brief Appends every cell of the grid, one line per row
+//  In ANSI mode the glyphs are remembered so the next frame can be drawn differentially
+func (r *Renderer) appendAllCells(buf []byte, w *World) ([]byte, error) {
+    if r.ansi {
+        if len(r.prev) != w.Rows()*w.Size {
+            r.prev = make([]byte, w.Rows()*w.Size)
+        }
+    }
+
+    var err error
+    for row := 0; row < w.Rows(); row++ {
+        if w.Depth > 1 && row%w.Size == 0 {
+            buf = append(buf, "Layer "...)
+            buf = strconv.AppendInt(buf, int64(row/w.Size+1), 10)
+            buf = append(buf, '\n')
+        }
+        for col := 0; col < w.Size; col++ {
+            glyph := r.cellKey(w, row, col)
+            buf = r.appendKey(buf, glyph)
+            if r.ansi {
+                r.prev[row*w.Size+col] = glyph
+            }
+        }
+        buf = append(buf, '\n')
+
+        if buf, err = r.flushBand(buf); err != nil {
+            return buf, err
+        }
+    }
+    return buf, nil
+}
+
+//  @brief Appends cursor-positioned updates for only the cells that changed since the last frame
+func (r *Renderer) appendChangedCells(buf []byte, w *World) ([]byte, error) {
+    var err error
+    for row := 0; row < w.Rows(); row++ {
+        // Column just after the last written cell, where the cursor already is
+        cursorCol := -1
+
+        for col := 0; col < w.Size; col++ {
+            glyph := r.cellKey(w, row, col)
+            index := row*w.Size + col
+            if r.prev[index] == glyph {
+                continue
+            }
+            r.prev[index] = glyph
+
+            // Adjacent changes on a row need no extra cursor movement
+            if col != cursorCol {
+                buf = appendCursor(buf, gridLine(w, row), col+1)
+            }
+            buf = r.appendKey(buf, glyph)
+            cursorCol = col + 1
+        }
+
+        if buf, err = r.flushBand(buf); err != nil {
+            return buf, err
+        }
+    }
+    return buf, nil
+}
+
+//  @brief Returns the 1-based terminal line grid row is drawn on, below the header and any layer labels
+func gridLine(w *World, row int) int {
+    if w.Depth > 1 {
+        return row + 2 + row/w.Size + 1
+    }
+    return row + 2
+}
+
+//  @brief Appends an escape sequence moving the cursor to the 1-based terminal line and column
+func appendCursor(buf []byte, line, col int) []byte {
+    buf = append(buf, "\x1b["...)
+    buf = strconv.AppendInt(buf, int64(line), 10)
+    buf = append(buf, ';')
+    buf = strconv.AppendInt(buf, int64(col), 10)
+    return append(buf, 'H')
+}
+
+//  @brief A completed world waiting to be drawn
+type frame struct {
+    world   *World
+    chronon int
+}
+
+//  @brief AsyncRenderer draws frames on a separate goroutine, skipping frames while it is busy
+//  Worlds are never modified after StepWorld returns them, so they can be drawn while the next chronon is computed
+type AsyncRenderer struct {
+    renderer *Renderer
+    frames   chan frame    //  Holds at most one frame waiting to be drawn
+    done     chan struct{} //  Closed once the drawing goroutine has finished
+    skip     bool          //  Drop frames when behind instead of waiting
+    cast     *Renderer     //  Also draws every frame into a cast recording when the output is not a terminal, nil otherwise (see cast.go)
+    start    time.Time
+
+    drawn   int   //  Frames actually drawn, owned by the drawing goroutine
+    dropped int   //  Frames skipped because the renderer was behind
+    err     error //  First drawing error, owned by the drawing goroutine
+}
+
+//  @brief Starts a drawing goroutine that renders submitted frames with r
+//  @param "skip" Drop frames while the renderer is behind, otherwise Submit waits for it
+func NewAsyncRenderer(r *Renderer, skip bool) *AsyncRenderer {
+    a := &AsyncRenderer{
+        renderer: r,
+        frames:   make(chan frame, 1),
+        done:     make(chan struct{}),
+        skip:     skip,
+        start:    time.Now(),
+    }
+
+    go func() {
+        defer close(a.done)
+        for f := range a.frames {
+            if err := a.renderer.Draw(f.world, f.chronon); err != nil && a.err == nil {
+                a.err = err
+            }
+            if a.cast != nil {
+                if err := a.cast.Draw(f.world, f.chronon); err != nil && a.err == nil {
+                    a.err = err
+                }
+            }
+            a.drawn++
+        }
+    }()
+
+    return a
+}
+
+//  @brief Queues a frame for drawing without blocking
+//  If a frame is still waiting, it is replaced so the newest state is always the one shown
+func (a *AsyncRenderer) Submit(w *World, chronon int) {
+    f := frame{world: w, chronon: chronon}
+    if !a.skip {
+        a.frames <- f
+        return
+    }
+
+    select {
+    case a.frames <- f:
+        return
+    default:
+    }
+
+    select {
+    case <-a.frames:
+        a.dropped++
+    default:
+    }
+    a.frames <- f
+}
+
+//  @brief Draws any frame still waiting, stops the drawing goroutine and returns the first drawing error
+func (a *AsyncRenderer) Close() error {
+    close(a.frames)
+    <-a.done
+    return a.err
+}
+
+//  @brief Prints how many frames were drawn and dropped and the achieved draw rate, must be called after Close
+func (a *AsyncRenderer) Report() {
+    rate := float64(a.drawn) / time.Since(a.start).Seconds()
+    fmt.Printf("Frames drawn: %d  Dropped: %d  Draw rate: %.1f fps\n", a.drawn, a.dropped, rate)
+}
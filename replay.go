@@ -0,0 +1,248 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "image"
+    "image/color"
+    "image/gif"
+    "image/png"
+    "io"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "slices"
+    "strings"
+)
+
+/**
+    @file replay.go
+    @brief The render subcommand: draws a recorded run after the fact
+    A run recorded with -dump-frames keeps the grid of every sampled chronon,
+    so the expensive drawing need not slow the run itself down. render turns
+    the recording, or the numbered files of a rotated one in order, into a
+    GIF animation, an MP4 video or one PNG image per frame, e.g.
+
+        wa-tor -headless -dump-frames run.jsonl.zst -chronons 5000 300 2000 3 8 5 200 4
+        wa-tor render -format gif -scale 2 -stride 10 run.jsonl.zst
+        wa-tor render -format png-seq -out frames -palette night run.jsonl.zst
+
+    -stride keeps only the frames of every Nth chronon, though always the
+    last, -scale sets the pixels per cell and -palette the colours: wator
+    (those of -timelapse), night or grey. -fps sets the speed of the GIF and
+    MP4. The video is encoded by ffmpeg, which must be on the PATH; the PNG
+    images are written to the -out directory as chronon-000010.png and so
+    on. -out defaults to the recording's name with .gif or .mp4, or without
+    its extension for the images.
+*/
+
+//  @brief Output formats of the render subcommand
+const (
+    renderGIF = "gif"
+    renderMP4 = "mp4"
+    renderPNG = "png-seq"
+)
+
+//  @brief Colours frames are rendered in, by name, indexed as by timelapseIndex
+var renderPalettes = map[string]color.Palette{
+    "wator": timelapsePalette,
+    "night": {color.RGBA{8, 24, 58, 255}, color.RGBA{240, 200, 60, 255}, color.RGBA{230, 70, 50, 255}, color.RGBA{250, 250, 250, 255}, color.RGBA{90, 100, 120, 255}},
+    "grey":  {color.RGBA{255, 255, 255, 255}, color.RGBA{170, 170, 170, 255}, color.RGBA{0, 0, 0, 255}, color.RGBA{85, 85, 85, 255}, color.RGBA{220, 220, 220, 255}},
+}
+
+//  @brief Returns the names of the render palettes, sorted
+func renderPaletteNames() []string {
+    names := make([]string, 0, len(renderPalettes))
+    for name := range renderPalettes {
+        names = append(names, name)
+    }
+    slices.Sort(names)
+    return names
+}
+
+//  @brief Returns the palette index of a cell drawn with glyph, grey for species not loaded
+func glyphIndex(glyph byte) uint8 {
+    if e, ok := entityForGlyph(glyph); ok {
+        return timelapseIndex(e)
+    }
+    return timelapseIndex(Corpse)
+}
+
+//  @brief Draws frame f, scale pixels square per cell
+func frameImage(f Frame, scale int, palette color.Palette) *image.Paletted {
+    return cellImage(len(f.Rows), f.Size, scale, palette, func(row, col int) uint8 {
+        return glyphIndex(f.Rows[row][col])
+    })
+}
+
+//  @brief Calls each with every frame of the recordings at paths whose chronon is a multiple of stride, and with the last one
+func readFrames(paths []string, stride int, each func(Frame) error) (int, error) {
+    n := 0
+    var pending *Frame // the newest frame skipped by the stride, drawn if it turns out to be the last
+    for _, path := range paths {
+        in, err := OpenInput(path)
+        if err != nil {
+            return n, err
+        }
+        dec := json.NewDecoder(in)
+        for {
+            var f Frame
+            if err := dec.Decode(&f); err == io.EOF {
+                break
+            } else if err != nil {
+                in.Close()
+                return n, fmt.Errorf("%s: %v", path, err)
+            }
+            if f.Chronon%stride != 0 {
+                pending = &f
+                continue
+            }
+            pending = nil
+            if err := each(f); err != nil {
+                in.Close()
+                return n, err
+            }
+            n++
+        }
+        in.Close()
+    }
+    if pending != nil {
+        if err := each(*pending); err != nil {
+            return n, err
+        }
+        n++
+    }
+    return n, nil
+}
+
+//  @brief Returns the file or directory a recording at path is rendered to in format when -out is not given
+func renderOut(path, format string) string {
+    base := path
+    for _, ext := range []string{compressGzip, compressZstd, ".jsonl", ".json"} {
+        base = strings.TrimSuffix(base, ext)
+    }
+    switch format {
+    case renderGIF:
+        return base + ".gif"
+    case renderMP4:
+        return base + ".mp4"
+    }
+    return base
+}
+
+//  @brief Renders the recordings at paths to a GIF animation at out, returning the frame count
+func renderGIFFile(paths []string, out string, stride, scale, fps int, palette color.Palette) (int, error) {
+    var anim gif.GIF
+    n, err := readFrames(paths, stride, func(f Frame) error {
+        anim.Image = append(anim.Image, frameImage(f, scale, palette))
+        anim.Delay = append(anim.Delay, max(100/fps, 1))
+        return nil
+    })
+    if err != nil {
+        return n, err
+    }
+    if n == 0 {
+        return 0, errors.New("the recording holds no frames")
+    }
+
+    file, err := os.Create(out)
+    if err != nil {
+        return 0, err
+    }
+    if err := gif.EncodeAll(file, &anim); err != nil {
+        file.Close()
+        return 0, err
+    }
+    return n, file.Close()
+}
+
+//  @brief Renders the recordings at paths to one PNG image per frame in the directory out, returning the frame count
+func renderPNGFiles(paths []string, out string, stride, scale int, palette color.Palette) (int, error) {
+    if err := os.MkdirAll(out, 0o755); err != nil {
+        return 0, err
+    }
+    return readFrames(paths, stride, func(f Frame) error {
+        return WritePNG(filepath.Join(out, fmt.Sprintf("chronon-%06d.png", f.Chronon)), frameImage(f, scale, palette))
+    })
+}
+
+//  @brief Renders the recordings at paths to an MP4 video at out by piping PNG images into ffmpeg, returning the frame count
+func renderMP4File(paths []string, out string, stride, scale, fps int, palette color.Palette) (int, error) {
+    if _, err := exec.LookPath("ffmpeg"); err != nil {
+        return 0, errors.New("mp4 is encoded by ffmpeg, which is not on the PATH; render a png-seq or gif instead")
+    }
+    // H.264 in yuv420p needs an even width and height, so odd frames get a pixel of padding
+    cmd := exec.Command("ffmpeg", "-y", "-loglevel", "error", "-f", "image2pipe", "-framerate", fmt.Sprint(fps), "-i", "-",
+        "-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2", "-c:v", "libx264", "-pix_fmt", "yuv420p", out)
+    cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+    pipe, err := cmd.StdinPipe()
+    if err != nil {
+        return 0, err
+    }
+    if err := cmd.Start(); err != nil {
+        return 0, err
+    }
+
+    n, err := readFrames(paths, stride, func(f Frame) error {
+        return png.Encode(pipe, frameImage(f, scale, palette))
+    })
+    pipe.Close()
+    if werr := cmd.Wait(); err == nil && werr != nil {
+        err = fmt.Errorf("ffmpeg: %v", werr)
+    }
+    if err == nil && n == 0 {
+        err = errors.New("the recording holds no frames")
+    }
+    return n, err
+}
+
+//  @brief Entry point of the render subcommand
+func runRender(args []string) {
+    fs := flag.NewFlagSet("render", flag.ExitOnError)
+    formatFlag := fs.String("format", renderGIF, "Output format: "+renderGIF+", "+renderMP4+" (encoded by ffmpeg) or "+renderPNG+" (one PNG per frame)")
+    outFlag := fs.String("out", "", "File, or directory for "+renderPNG+", to render to (default: the recording's name)")
+    scaleFlag := fs.Int("scale", 4, "Pixels per cell")
+    paletteFlag := fs.String("palette", "wator", "Colours of the cells: "+strings.Join(renderPaletteNames(), ", "))
+    strideFlag := fs.Int("stride", 1, "Render the frames of every N chronons, and the last")
+    fpsFlag := fs.Int("fps", 10, "Frames per second of the "+renderGIF+" and "+renderMP4+" output")
+    fs.Parse(args)
+
+    if fs.NArg() == 0 {
+        fmt.Println("Usage: wa-tor render [-format gif|mp4|png-seq] [-out path] [-scale N] [-palette name] [-stride N] [-fps N] frames.jsonl...")
+        os.Exit(1)
+    }
+    palette, ok := renderPalettes[*paletteFlag]
+    switch {
+    case *formatFlag != renderGIF && *formatFlag != renderMP4 && *formatFlag != renderPNG:
+        fmt.Printf("Error: -format must be %s, %s or %s.\n", renderGIF, renderMP4, renderPNG)
+        os.Exit(1)
+    case !ok:
+        fmt.Printf("Error: -palette must be one of %s.\n", strings.Join(renderPaletteNames(), ", "))
+        os.Exit(1)
+    case *scaleFlag < 1 || *strideFlag < 1 || *fpsFlag < 1:
+        fmt.Println("Error: -scale, -stride and -fps must be 1 or greater.")
+        os.Exit(1)
+    }
+
+    out := *outFlag
+    if out == "" {
+        out = renderOut(fs.Arg(0), *formatFlag)
+    }
+    var n int
+    var err error
+    switch *formatFlag {
+    case renderGIF:
+        n, err = renderGIFFile(fs.Args(), out, *strideFlag, *scaleFlag, *fpsFlag, palette)
+    case renderMP4:
+        n, err = renderMP4File(fs.Args(), out, *strideFlag, *scaleFlag, *fpsFlag, palette)
+    case renderPNG:
+        n, err = renderPNGFiles(fs.Args(), out, *strideFlag, *scaleFlag, palette)
+    }
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+    fmt.Printf("Rendered %d frames to %s\n", n, out)
+}
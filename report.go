@@ -0,0 +1,306 @@
+package main
+
+import (
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "html/template"
+    "image"
+    "image/color"
+    "image/png"
+    "math"
+    "os"
+    "slices"
+    "time"
+)
+
+/**
+    @file report.go
+    @brief A self-contained HTML report of a run, written with -report
+    At the end of the run one HTML file is written holding everything needed
+    to look at the run again later: the population of every species over
+    the chronons, the phase plot of the predators against their prey, an
+    image of the final world, the parameters and the summary statistics,
+    e.g.
+
+        wa-tor -headless -report run.html -seed 7 -chronons 2000 300 2000 3 8 5 100 4
+
+    The images are embedded as PNG data, so the file can be archived or
+    mailed on its own and opened in any browser. Long runs are charted
+    from at most reportPoints chronons, evenly spread over the run.
+*/
+
+//  @brief Most chronons a chart of the report plots
+const reportPoints = 2000
+
+//  @brief Width and height in pixels of the charts of the report
+const (
+    reportChartWidth  = 720
+    reportChartHeight = 400
+)
+
+//  @brief Pixels the final world is at most drawn across
+const reportWorldPixels = 600
+
+//  @brief Colours the species are charted in, in the order of their names
+var reportColors = []color.RGBA{chartBlue, chartRed, chartGrey, chartAxis}
+
+//  @brief ReportSeries records the population of every species after every chronon of a run
+type ReportSeries struct {
+    Names    []string    //  Species, sorted
+    Chronons []float64   //  Chronon of every point
+    Counts   [][]float64 //  Count of every species at every point, in the order of Names
+}
+
+//  @brief Records the populations of w at chronon, the species being those of the first call
+func (r *ReportSeries) Add(cfg Config, w *World, chronon int) {
+    counts := populations(cfg, w)
+    if r.Names == nil {
+        for name := range counts {
+            r.Names = append(r.Names, name)
+        }
+        slices.Sort(r.Names)
+        r.Counts = make([][]float64, len(r.Names))
+    }
+    r.Chronons = append(r.Chronons, float64(chronon))
+    for i, name := range r.Names {
+        r.Counts[i] = append(r.Counts[i], float64(counts[name]))
+    }
+}
+
+//  @brief Returns every stride-th value of values, and the last, so that at most about reportPoints are left
+func reportSample(values []float64, stride int) []float64 {
+    if stride <= 1 {
+        return values
+    }
+    var sampled []float64
+    for i := 0; i < len(values); i += stride {
+        sampled = append(sampled, values[i])
+    }
+    if (len(values)-1)%stride != 0 {
+        sampled = append(sampled, values[len(values)-1])
+    }
+    return sampled
+}
+
+//  @brief Returns the index of the species named name, or -1
+func (r *ReportSeries) index(name string) int {
+    return slices.Index(r.Names, name)
+}
+
+//  @brief Returns the pair the phase plot shows, prey along x and predator along y
+//  Sharks are plotted against fish; a food web plots its second species against its first
+func (r *ReportSeries) phasePair() (x, y int, ok bool) {
+    if fish, sharks := r.index("fish"), r.index("sharks"); fish >= 0 && sharks >= 0 {
+        return fish, sharks, true
+    }
+    if len(r.Names) < 2 {
+        return 0, 0, false
+    }
+    return 0, 1, true
+}
+
+//  @brief Report is what the HTML report of a run shows
+type Report struct {
+    Config  Config
+    Seed    int64
+    Result  RunResult
+    Series  *ReportSeries
+    World   *World   //  The final world
+    Summary []string //  Lines of the run summary, as printed
+}
+
+//  @brief One row of a table of the report
+type reportRow struct {
+    Name, Value string
+}
+
+//  @brief A species in the legend of the population chart
+type reportLegend struct {
+    Name  string
+    Color template.CSS
+}
+
+//  @brief What the template of the report is filled in with
+type reportPage struct {
+    Title      string
+    Generated  string
+    Stats      []reportRow
+    Legend     []reportLegend
+    Population template.URL
+    Phase      template.URL
+    PhaseLabel string
+    World      template.URL
+    Params     []reportRow
+    Summary    []string
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 780px; color: #222; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.2em; margin-top: 1.6em; border-bottom: 1px solid #ddd; }
+table { border-collapse: collapse; }
+td { padding: 2px 12px 2px 0; vertical-align: top; }
+td:first-child { color: #666; }
+pre { background: #f6f6f6; padding: 8px; overflow-x: auto; }
+.legend span { margin-right: 1.2em; font-weight: bold; }
+img { display: block; max-width: 100%; image-rendering: pixelated; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Written {{.Generated}}</p>
+
+<h2>Summary</h2>
+<table>
+{{range .Stats}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+
+<h2>Population</h2>
+<p class="legend">{{range .Legend}}<span style="color: {{.Color}}">{{.Name}}</span>{{end}}</p>
+<img src="{{.Population}}" alt="Population of every species over the chronons">
+{{if .Phase}}
+<h2>Phase plot</h2>
+<p>{{.PhaseLabel}}</p>
+<img src="{{.Phase}}" alt="{{.PhaseLabel}}">
+{{end}}
+<h2>Final world</h2>
+<img src="{{.World}}" alt="The world after the last chronon">
+
+<h2>Parameters</h2>
+<table>
+{{range .Params}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+
+<h2>Run output</h2>
+<pre>{{range .Summary}}{{.}}
+{{end}}</pre>
+</body>
+</html>
+`))
+
+//  @brief Writes the report as one HTML file with its images embedded
+func WriteReport(path string, r Report) error {
+    page, err := r.page()
+    if err != nil {
+        return err
+    }
+    var b bytes.Buffer
+    if err := reportTemplate.Execute(&b, page); err != nil {
+        return err
+    }
+    return os.WriteFile(path, b.Bytes(), 0o644)
+}
+
+//  @brief Fills in the template of the report
+func (r Report) page() (reportPage, error) {
+    p := reportPage{
+        Title:     fmt.Sprintf("Wa-Tor run, seed %d", r.Seed),
+        Generated: time.Now().Format(time.RFC1123),
+        Summary:   r.Summary,
+    }
+    p.Stats = append(p.Stats,
+        reportRow{"Chronons", fmt.Sprint(r.Result.Chronons)},
+        reportRow{"Time", r.Result.Elapsed.Round(time.Millisecond).String()},
+        reportRow{"Final populations", populationLine(r.World)},
+    )
+    for i, name := range r.Series.Names {
+        counts := r.Series.Counts[i]
+        if len(counts) == 0 {
+            continue
+        }
+        low, high := slices.Min(counts), slices.Max(counts)
+        mean := 0.0
+        for _, n := range counts {
+            mean += n
+        }
+        mean /= float64(len(counts))
+        p.Stats = append(p.Stats, reportRow{name, fmt.Sprintf("min %.0f  mean %.1f  max %.0f", low, mean, high)})
+    }
+
+    // charts from at most about reportPoints chronons
+    stride := max(1, (len(r.Series.Chronons)+reportPoints-1)/reportPoints)
+    chronons := reportSample(r.Series.Chronons, stride)
+    population := Chart{Width: reportChartWidth, Height: reportChartHeight, YMin: 0}
+    for i, name := range r.Series.Names {
+        col := reportColors[i%len(reportColors)]
+        population.Series = append(population.Series, Series{X: chronons, Y: reportSample(r.Series.Counts[i], stride), Color: col})
+        p.Legend = append(p.Legend, reportLegend{name, template.CSS(fmt.Sprintf("rgb(%d, %d, %d)", col.R, col.G, col.B))})
+    }
+    // counts are shown from zero, like the speedup of bench-scale
+    population.YMax = 1
+    for _, s := range population.Series {
+        if len(s.Y) > 0 {
+            population.YMax = math.Max(population.YMax, slices.Max(s.Y))
+        }
+    }
+    var err error
+    if p.Population, err = pngURL(population.Render()); err != nil {
+        return p, err
+    }
+
+    if x, y, ok := r.Series.phasePair(); ok && len(chronons) > 1 {
+        phase := Chart{Width: reportChartWidth, Height: reportChartHeight, Series: []Series{
+            {X: reportSample(r.Series.Counts[x], stride), Y: reportSample(r.Series.Counts[y], stride), Color: chartRed},
+        }}
+        p.PhaseLabel = fmt.Sprintf("%s (up) against %s (across), one point per chronon", r.Series.Names[y], r.Series.Names[x])
+        if p.Phase, err = pngURL(phase.Render()); err != nil {
+            return p, err
+        }
+    }
+
+    w := r.World
+    scale := max(1, reportWorldPixels/max(w.Rows(), w.Size))
+    img := cellImage(w.Rows(), w.Size, scale, timelapsePalette, func(row, col int) uint8 {
+        return timelapseIndex(w.Cells[row][col].Entity)
+    })
+    if p.World, err = pngURL(img); err != nil {
+        return p, err
+    }
+
+    p.Params, err = reportParams(r.Config)
+    return p, err
+}
+
+//  @brief Returns the settings of cfg as rows, as a configuration file holds them
+func reportParams(cfg Config) ([]reportRow, error) {
+    data, err := MarshalConfig(cfg)
+    if err != nil {
+        return nil, err
+    }
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(data, &fields); err != nil {
+        return nil, err
+    }
+    names := make([]string, 0, len(fields))
+    for name := range fields {
+        names = append(names, name)
+    }
+    slices.Sort(names)
+    rows := make([]reportRow, len(names))
+    for i, name := range names {
+        value := string(fields[name])
+        // strings without their quotes
+        var text string
+        if json.Unmarshal(fields[name], &text) == nil {
+            value = text
+        }
+        rows[i] = reportRow{name, value}
+    }
+    return rows, nil
+}
+
+//  @brief Encodes img as a PNG data URL
+func pngURL(img image.Image) (template.URL, error) {
+    var b bytes.Buffer
+    if err := png.Encode(&b, img); err != nil {
+        return "", err
+    }
+    return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(b.Bytes())), nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+    "encoding"
+    "encoding/binary"
+    "fmt"
+    "hash/fnv"
+    "math/rand"
+    randv2 "math/rand/v2"
+)
+
+/**
+    @file rng.go
+    @brief Selectable random number generators for the simulation
+    The step functions draw random numbers for every occupied cell, so the
+    generator is a measurable cost. Three algorithms are available behind
+    the small RNG interface:
+        stdlib    math/rand (the original generator)
+        pcg       PCG-DXSM from math/rand/v2
+        xorshift  xorshift64*, the cheapest of the three
+    Every generator can save and restore its state, which is what lets a
+    checkpointed run continue along exactly the same random trajectory.
+*/
+
+//  @brief RNG is the random number source used by the simulation
+type RNG interface {
+    Intn(n int) int  //  Uniform integer in [0, n)
+    Uint64() uint64  //  Uniform 64-bit value
+
+    encoding.BinaryMarshaler   //  Saves the generator state
+    encoding.BinaryUnmarshaler //  Restores a state saved by MarshalBinary
+}
+
+//  @brief Names accepted by NewRNG, in the order shown in help text
+var rngKinds = []string{"stdlib", "pcg", "xorshift"}
+
+//  @brief Reports whether kind names a known generator
+func validRNG(kind string) bool {
+    for _, k := range rngKinds {
+        if k == kind {
+            return true
+        }
+    }
+    return false
+}
+
+//  @brief Creates a generator of the given kind seeded with seed
+func NewRNG(kind string, seed int64) (RNG, error) {
+    switch kind {
+    case "stdlib", "":
+        return newStdlibRNG(seed), nil
+    case "pcg":
+        return newSourceRNG(randv2.NewPCG(uint64(seed), uint64(seed)^0x9e3779b97f4a7c15)), nil
+    case "xorshift":
+        return newSourceRNG(newXorshift(uint64(seed))), nil
+    }
+    return nil, fmt.Errorf("unknown random number generator %q", kind)
+}
+
+//  @brief Creates a generator of the given kind, panicking on an unknown kind
+//  The kind is validated when the configuration is parsed, so this cannot fail for a parsed Config
+func mustRNG(kind string, seed int64) RNG {
+    r, err := NewRNG(kind, seed)
+    if err != nil {
+        panic(err)
+    }
+    return r
+}
+
+//  @brief sourceRNG adapts a math/rand/v2 source to the RNG interface
+type sourceRNG struct {
+    src randv2.Source
+    r   *randv2.Rand
+}
+
+//  @brief Wraps src so it can be used as an RNG
+func newSourceRNG(src randv2.Source) *sourceRNG {
+    return &sourceRNG{src: src, r: randv2.New(src)}
+}
+
+//  @brief Returns a uniform integer in [0, n)
+func (s *sourceRNG) Intn(n int) int {
+    return s.r.IntN(n)
+}
+
+//  @brief Returns a uniform 64-bit value
+func (s *sourceRNG) Uint64() uint64 {
+    return s.src.Uint64()
+}
+
+//  @brief Saves the state of the wrapped source
+func (s *sourceRNG) MarshalBinary() ([]byte, error) {
+    m, ok := s.src.(encoding.BinaryMarshaler)
+    if !ok {
+        return nil, fmt.Errorf("random source %T cannot save its state", s.src)
+    }
+    return m.MarshalBinary()
+}
+
+//  @brief Restores a state saved by MarshalBinary
+func (s *sourceRNG) UnmarshalBinary(data []byte) error {
+    u, ok := s.src.(encoding.BinaryUnmarshaler)
+    if !ok {
+        return fmt.Errorf("random source %T cannot restore its state", s.src)
+    }
+    return u.UnmarshalBinary(data)
+}
+
+//  @brief stdlibRNG is a math/rand generator whose state can be saved
+//  math/rand cannot export its state, so the seed and the number of values drawn
+//  are recorded instead, and restoring replays the source up to the same point
+type stdlibRNG struct {
+    *rand.Rand
+    src *countingSource
+}
+
+//  @brief countingSource wraps a math/rand source and counts the values drawn from it
+type countingSource struct {
+    src   rand.Source64
+    seed  int64
+    draws uint64
+}
+
+//  @brief Creates a math/rand generator seeded with seed
+func newStdlibRNG(seed int64) *stdlibRNG {
+    src := &countingSource{src: rand.NewSource(seed).(rand.Source64), seed: seed}
+    return &stdlibRNG{Rand: rand.New(src), src: src}
+}
+
+//  @brief Returns the next 63-bit value (rand.Source)
+func (c *countingSource) Int63() int64 {
+    c.draws++
+    return c.src.Int63()
+}
+
+//  @brief Returns the next 64-bit value (rand.Source64)
+func (c *countingSource) Uint64() uint64 {
+    c.draws++
+    return c.src.Uint64()
+}
+
+//  @brief Reseeds the source and resets the draw count (rand.Source)
+func (c *countingSource) Seed(seed int64) {
+    c.src.Seed(seed)
+    c.seed = seed
+    c.draws = 0
+}
+
+//  @brief Saves the seed and the number of values drawn so far
+func (r *stdlibRNG) MarshalBinary() ([]byte, error) {
+    data := make([]byte, 16)
+    binary.BigEndian.PutUint64(data[0:], uint64(r.src.seed))
+    binary.BigEndian.PutUint64(data[8:], r.src.draws)
+    return data, nil
+}
+
+//  @brief Restores a saved state by reseeding and drawing the same number of values again
+func (r *stdlibRNG) UnmarshalBinary(data []byte) error {
+    if len(data) != 16 {
+        return fmt.Errorf("stdlib generator state must be 16 bytes, got %d", len(data))
+    }
+    draws := binary.BigEndian.Uint64(data[8:])
+    r.src.Seed(int64(binary.BigEndian.Uint64(data[0:])))
+
+    // Each Int63 or Uint64 call advances the underlying source by exactly one step
+    for i := uint64(0); i < draws; i++ {
+        r.src.src.Uint64()
+    }
+    r.src.draws = draws
+    return nil
+}
+
+//  @brief xorshift64* generator (Vigna), a randv2.Source with 64 bits of state
+type xorshift struct {
+    state uint64
+}
+
+//  @brief Advances a splitmix64 state and returns the next well-mixed value
+//  splitmix64 turns consecutive or small seeds into unrelated values, which makes it
+//  suitable for deriving many independent seeds from one master seed
+func splitmix64(state *uint64) uint64 {
+    *state += 0x9e3779b97f4a7c15
+    z := *state
+    z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+    z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+    return z ^ (z >> 31)
+}
+
+//  @brief Derives n seeds deterministically from a master seed
+func deriveSeeds(master int64, n int) []int64 {
+    state := uint64(master)
+    seeds := make([]int64, n)
+    for i := range seeds {
+        seeds[i] = int64(splitmix64(&state))
+    }
+    return seeds
+}
+
+//  @brief Derives the seed of a named sub-stream from the master seed
+//  Each name maps to its own seed, so streams are independent of each other and
+//  of the order in which they are created
+func streamSeed(master int64, name string) int64 {
+    h := fnv.New64a()
+    h.Write([]byte(name))
+    state := uint64(master) ^ h.Sum64()
+    return int64(splitmix64(&state))
+}
+
+//  @brief Returns the random stream used for the initial placement of the world
+//  It is the same stream Simulator.RNGStream("placement") would give for this seed
+func placementRNG(cfg Config) RNG {
+    return mustRNG(cfg.RNG, streamSeed(cfg.Seed, "placement"))
+}
+
+//  @brief Creates a xorshift generator, mixing the seed so that small or zero seeds still work
+func newXorshift(seed uint64) *xorshift {
+    // xorshift must never have an all-zero state
+    z := splitmix64(&seed)
+    if z == 0 {
+        z = 1
+    }
+    return &xorshift{state: z}
+}
+
+//  @brief Returns the next 64-bit value
+func (x *xorshift) Uint64() uint64 {
+    x.state ^= x.state >> 12
+    x.state ^= x.state << 25
+    x.state ^= x.state >> 27
+    return x.state * 0x2545f4914f6cdd1d
+}
+
+//  @brief Saves the 64-bit state
+func (x *xorshift) MarshalBinary() ([]byte, error) {
+    return binary.BigEndian.AppendUint64(nil, x.state), nil
+}
+
+//  @brief Restores a state saved by MarshalBinary
+func (x *xorshift) UnmarshalBinary(data []byte) error {
+    if len(data) != 8 {
+        return fmt.Errorf("xorshift state must be 8 bytes, got %d", len(data))
+    }
+    x.state = binary.BigEndian.Uint64(data)
+    return nil
+}
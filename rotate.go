@@ -0,0 +1,152 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+/**
+    @file rotate.go
+    @brief Rotation of the stats, blocks and frame files of long runs
+    With -rotate-every N a new file is started every N chronons, and with
+    -rotate-size S once the current file has reached S bytes (K, M and G
+    suffixes allowed, sizes are as written to disk, after any compression).
+    The files are numbered before their first extension:
+
+        stats.csv        ->  stats-0001.csv, stats-0002.csv, ...
+        frames.jsonl.gz  ->  frames-0001.jsonl.gz, ...
+
+    With -rotate-keep K only the newest K files of each output are kept, the
+    older ones are deleted as a new one is started. A run whose files already
+    exist continues with the highest numbered one, e.g.
+
+        wa-tor -headless -stats-every 10 -stats-csv stats.csv -rotate-every 100000 -rotate-keep 5 300 2000 3 8 5 100 4
+*/
+
+//  @brief Rotation says when a new output file is started and how many are kept, the zero Rotation never rotates
+type Rotation struct {
+    Every int      //  Start a new file every N chronons (0 = never)
+    Size  ByteSize //  Start a new file once the current one holds this many bytes (0 = no limit)
+    Keep  int      //  Number of files kept (0 = all)
+}
+
+//  @brief Reports whether files are rotated at all
+func (r Rotation) Enabled() bool {
+    return r.Every > 0 || r.Size > 0
+}
+
+//  @brief rotatingPath tracks the current file of one rotated output
+type rotatingPath struct {
+    base    string
+    rot     Rotation
+    index   int //  Number of the current file
+    started int //  Chronon the current file was started at
+}
+
+//  @brief Returns the rotated output of base, or nil when rotation is off, continuing with the highest numbered existing file
+func newRotatingPath(base string, r Rotation, chronon int) *rotatingPath {
+    if base == "" || !r.Enabled() {
+        return nil
+    }
+    p := &rotatingPath{base: base, rot: r, index: 1, started: chronon}
+    for {
+        if _, err := os.Stat(p.numbered(p.index + 1)); err != nil {
+            break
+        }
+        p.index++
+    }
+    return p
+}
+
+//  @brief Returns the name of file number i: base with -NNNN inserted before its first extension
+func (p *rotatingPath) numbered(i int) string {
+    dir, name := filepath.Split(p.base)
+    stem, ext, _ := strings.Cut(name, ".")
+    if ext != "" {
+        ext = "." + ext
+    }
+    return fmt.Sprintf("%s%s-%04d%s", dir, stem, i, ext)
+}
+
+//  @brief Returns the file to write at chronon, starting the next one when the current file is old or large enough
+//  rotated reports whether a new file was started; path is the unnumbered base when p is nil
+func (p *rotatingPath) Path(chronon int) (path string, rotated bool) {
+    if p == nil {
+        return "", false
+    }
+    current := p.numbered(p.index)
+    full := p.rot.Every > 0 && chronon-p.started >= p.rot.Every
+    if !full && p.rot.Size > 0 {
+        info, err := os.Stat(current)
+        full = err == nil && info.Size() >= int64(p.rot.Size)
+    }
+    if !full {
+        return current, false
+    }
+
+    p.index++
+    p.started = chronon
+    if p.rot.Keep > 0 && p.index > p.rot.Keep {
+        os.Remove(p.numbered(p.index - p.rot.Keep))
+    }
+    return p.numbered(p.index), true
+}
+
+//  @brief Returns the path to write an output to at chronon: the base itself without rotation, otherwise the current file
+func rotatedPath(base string, p *rotatingPath, chronon int) string {
+    if p == nil {
+        return base
+    }
+    path, _ := p.Path(chronon)
+    return path
+}
+
+//  @brief ByteSize is a size in bytes written with an optional K, M or G suffix (powers of 1024), e.g. "100M"
+type ByteSize int64
+
+//  @brief Size suffixes in the order they are tried when formatting
+var byteSuffixes = []struct {
+    suffix string
+    size   ByteSize
+}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}}
+
+//  @brief Formats the size with the largest suffix that divides it exactly (flag.Value)
+func (b ByteSize) String() string {
+    for _, s := range byteSuffixes {
+        if b != 0 && b%s.size == 0 {
+            return strconv.FormatInt(int64(b/s.size), 10) + s.suffix
+        }
+    }
+    return strconv.FormatInt(int64(b), 10)
+}
+
+//  @brief Parses a size such as "4096", "512K", "100M" or "2GB" (flag.Value)
+func (b *ByteSize) Set(s string) error {
+    t := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+    mult := ByteSize(1)
+    for _, suf := range byteSuffixes {
+        if strings.HasSuffix(t, suf.suffix) {
+            t, mult = strings.TrimSuffix(t, suf.suffix), suf.size
+            break
+        }
+    }
+    n, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+    if err != nil || n < 0 {
+        return fmt.Errorf("size %q must be a number of bytes of 0 or greater, optionally followed by K, M or G", s)
+    }
+    *b = ByteSize(n) * mult
+    return nil
+}
+
+//  @brief Writes the size as formatted by String, the form used in configuration files (encoding.TextMarshaler)
+func (b ByteSize) MarshalText() ([]byte, error) {
+    return []byte(b.String()), nil
+}
+
+//  @brief Parses a size written as for Set (encoding.TextUnmarshaler)
+func (b *ByteSize) UnmarshalText(text []byte) error {
+    return b.Set(string(text))
+}
@@ -2,9 +2,10 @@ package main
 
 import (
     "fmt"
-    "math/rand"
+    "io"
     "os"
     "sync"
+    "sync/atomic"
     "time"
 )
 
@@ -20,41 +21,533 @@ import (
 
 //  @brief Creates a new World with the same size and parameters as an existing one, but with all cells empty
 func newEmptyWorldLike(w *World) *World {
-    cells := make([][]Cell, w.Size)
+    cells := make([][]Cell, w.Rows())
     for row := range cells {
         cells[row] = make([]Cell, w.Size)
     }
+    return worldLike(w, cells)
+}
+
+//  @brief Returns a world with the parameters of w holding cells
+func worldLike(w *World, cells [][]Cell) *World {
     return &World{
-        Size:       w.Size,
-        Cells:      cells,
-        FishBreed:  w.FishBreed,
-        SharkBreed: w.SharkBreed,
-        Starve:     w.Starve,
+        Size:        w.Size,
+        Cells:       cells,
+        FishBreed:   w.FishBreed,
+        SharkBreed:  w.SharkBreed,
+        Starve:      w.Starve,
+        OrcaBreed:   w.OrcaBreed,
+        OrcaStarve:  w.OrcaStarve,
+        CorpseDecay: w.CorpseDecay,
+        FishMature:  w.FishMature,
+        FishEggs:    w.FishEggs,
+        Gestation:   w.Gestation,
+        Topology:    w.Topology,
+        Depth:       w.Depth,
+    }
+}
+
+//  @brief Summary of a finished simulation run
+type RunResult struct {
+    Chronons int           //  Chronons actually simulated
+    Fish     int           //  Fish alive at the end
+    Sharks   int           //  Sharks alive at the end
+    Elapsed  time.Duration //  Wall-clock time of the step loop
+}
+
+//  @brief Simulator owns a running simulation: the current world, the chronon counter and the random streams
+type Simulator struct {
+    Config  Config
+    World   *World
+    Chronon int      //  Chronons simulated so far
+    Seed    int64    //  Effective seed of the run
+    Console *Console //  Answers commands between chronons (optional)
+
+    rngs    []RNG          //  One persistent random stream per worker goroutine
+    streams map[string]RNG //  Named sub-streams handed out by RNGStream
+    checks  *debugChecker  //  Validates the world after each chronon (nil without -debug-checks)
+    energy  *EnergyLedger  //  Balances the energy of each chronon (nil without -energy-ledger)
+    lives   *LifetimeTracker //  Follows every creature during Run (nil without -lifetimes)
+    frames  *FrameRing       //  The last frames, for -crash-dump and console rewinding (nil without either)
+    redraw  func()           //  Draws the current world again after the console changed it (nil when not drawing)
+
+    latest atomic.Pointer[PublishedFrame] //  The world of the last completed chronon, see LatestFrame
+}
+
+//  @brief PublishedFrame is a completed world and its chronon, read-only for whoever holds it
+type PublishedFrame struct {
+    World   *World //  A View, so later changes to the running world never show in it
+    Chronon int
+}
+
+//  @brief Returns the world of the last completed chronon, safe to call from any goroutine while the run goes on
+//  Renderers and HTTP handlers get a whole grid, never one half written; nil before the simulator has published one
+func (s *Simulator) LatestFrame() *PublishedFrame {
+    return s.latest.Load()
+}
+
+//  @brief Publishes the current world for LatestFrame, called by the simulation goroutine only
+func (s *Simulator) publish() {
+    s.latest.Store(&PublishedFrame{World: s.World.View(), Chronon: s.Chronon})
+}
+
+//  @brief Creates a simulator that starts at chronon 0 from the world w
+func NewSimulator(cfg Config, w *World) *Simulator {
+    seed := cfg.Seed
+    if seed == 0 {
+        seed = time.Now().UnixNano()
+    }
+    if !cfg.Explain.IsZero() {
+        cfg.explain = NewExplainer(cfg.Explain)
+    }
+    // already validated, see Config.Validate
+    cfg.behaviors, _ = resolveBehaviors(cfg)
+    if cfg.EntityRNG {
+        w.seedStreams(seed, 0)
+    }
+    return &Simulator{
+        Config: cfg,
+        World:  w,
+        Seed:   seed,
+        rngs:   newWorkerRNGs(cfg, workerCount(cfg.Threads, w.Size), seed),
+        checks: mustDebugChecker(cfg.DebugChecks),
+        energy: newEnergyLedger(cfg),
     }
 }
 
+//  @brief Creates one random stream per worker goroutine, seeded deterministically from the master seed
+//  Streams live for the whole run so their state can be saved in checkpoints; the same seed
+//  and thread count (and so the same row partitioning) always gives the same streams. The
+//  run itself only repeats with -strategy serial: threaded bands still race for the cells
+//  creatures of two bands claim in one chronon, see strategy.go
+func newWorkerRNGs(cfg Config, workers int, seed int64) []RNG {
+    rngs := make([]RNG, workers)
+    for t, workerSeed := range deriveSeeds(seed, workers) {
+        rngs[t] = mustRNG(cfg.RNG, workerSeed)
+    }
+    return rngs
+}
+
+//  @brief Returns the named random sub-stream of this run, creating it on first use
+//  Each stream is derived from the master seed and its name only (e.g. "placement", "events"),
+//  so adding a new random consumer never perturbs the sequences of existing ones.
+//  Named streams are saved in checkpoints. Not safe for concurrent use.
+func (s *Simulator) RNGStream(name string) RNG {
+    if r, ok := s.streams[name]; ok {
+        return r
+    }
+    if s.streams == nil {
+        s.streams = make(map[string]RNG)
+    }
+    r := mustRNG(s.Config.RNG, streamSeed(s.Seed, name))
+    s.streams[name] = r
+    return r
+}
+
+//  @brief Advances the simulation by one chronon
+func (s *Simulator) Step() {
+    var before int64
+    if s.energy != nil {
+        before = worldEnergy(s.World)
+    }
+    if k := s.batchLength(); k > 1 {
+        // several chronons without synchronising the workers, see batch.go
+        s.World = stepBatch(s.World, s.Config, s.rngs, k, s.Seed, s.Chronon)
+        s.Chronon += k
+    } else {
+        cfg := s.Config
+        cfg.chronon = s.Chronon + 1
+        s.World = StepWorld(s.World, cfg, s.rngs)
+        s.Chronon++
+        if s.Config.EntityRNG {
+            s.World.advanceStreams(s.Seed, s.Chronon)
+        }
+    }
+    if s.Config.explain != nil {
+        s.Config.explain.Flush(s.Chronon)
+    }
+
+    if s.checks != nil {
+        s.checks.allow(s.Config)
+        s.checks.Check(s.World, s.Chronon)
+    }
+    s.energy.Record(before, s.World, s.Chronon)
+    if s.lives != nil {
+        s.lives.Observe(s.World, s.Chronon)
+    }
+    s.publish()
+}
+
 //  @brief Runs the Wa-Tor simulation using the given configuration
 //   @param "cfg" The simulation configuration
 //  @param "w" The initial world
-func RunSimulation(cfg Config, w *World) {
+func RunSimulation(cfg Config, w *World) RunResult {
+    return NewSimulator(cfg, w).Run()
+}
+
+//  @brief Steps the simulation until a stop condition is reached, then prints and records the results
+func (s *Simulator) Run() RunResult {
+    cfg := s.Config
+
+    // Untimed warm-up chronons on a throwaway trajectory, so caches, page faults and
+    // CPU frequency have settled before measuring; the world itself is never modified by StepWorld
+    if cfg.BenchWarmup > 0 {
+        warm := &Simulator{Config: cfg, World: s.World, rngs: newWorkerRNGs(cfg, len(s.rngs), s.Seed+1)}
+        for i := 0; i < cfg.BenchWarmup; i++ {
+            warm.Step()
+        }
+    }
+
+    // following one creature relies on the numbers the lifetime tracker gives them
+    if cfg.Lifetimes != "" || cfg.Follow != "" {
+        s.lives = NewLifetimeTracker(s.World, s.Chronon)
+    }
+    var follower *Follower
+    if cfg.Follow != "" {
+        var err error
+        if follower, err = NewFollower(cfg.Follow, cfg.FollowCSV, s.World, s.Chronon); err != nil {
+            fmt.Printf("Could not follow %s: %v\n", cfg.Follow, err)
+        }
+    }
+
+    s.publish()
+    watchFrames(s)
+    defer watchFrames(nil)
+
+    var diag *Diagnostics
+    if cfg.Diag {
+        diag = NewDiagnostics()
+    }
+
+    // periodic checkpoints are written while the run goes on, see checkpoint.go
+    checkpoints := &checkpointWriter{path: cfg.Checkpoint}
+    defer checkpoints.Wait()
+
+    // the first chronons try out worker counts, see autotune.go
+    var tuner *AutoTuner
+    if cfg.AutoTune > 0 {
+        tuner = NewAutoTuner(s, cfg.AutoTune)
+    }
+
     start := time.Now()
-    rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+    lastStats := start
+    var phases PhaseTimes     // where the time of the step loop went, see phases.go
+    var throughput Throughput // cells and creatures stepped, see throughput.go
+    alive := creatureCount(cfg, s.World)
+    throughput.StartAllocs()
+    var events Events // since the last stats line
+
+    // mortality ledger of the whole run and since the last deaths line
+    var deaths, recentDeaths Mortality
+    var before population
+
+    // fish and shark counts of every chronon, for the lag between their cycles
+    var series PopulationSeries
+    if cfg.Deaths {
+        before = population{countEntities(s.World, Fish), countEntities(s.World, Shark), countEntities(s.World, Orca)}
+    }
+
+    // populations of every chronon for the HTML report, see report.go
+    var report *ReportSeries
+    if cfg.Report != "" {
+        report = &ReportSeries{}
+        report.Add(cfg, s.World, s.Chronon)
+    }
+
+    var renderer *AsyncRenderer
+    var cast *CastWriter // the terminal drawing, recorded for replay, see cast.go
+    if cfg.DrawEvery > 0 && !cfg.Headless {
+        tty := isTerminal(os.Stdout)
+        if cfg.Cast != "" {
+            var err error
+            width, height := castSize(s.World, cfg.Pane)
+            if cast, err = CreateCastWriter(cfg.Cast, width, height, castTitle(s.Seed)); err != nil {
+                fmt.Printf("Could not write cast %s: %v\n", cfg.Cast, err)
+            }
+        }
 
-    chronon := 0
+        var out io.Writer = os.Stdout
+        if cast != nil && tty {
+            out = io.MultiWriter(os.Stdout, cast)
+        }
+        r := NewRenderer(out, tty)
+        r.overlay = cfg.Overlay
+        if tty {
+            r.pane = NewStatsPane(cfg.Pane, s.Seed)
+        }
+        renderer = NewAsyncRenderer(r, tty)
+        if cast != nil && !tty {
+            // output to files and pipes is not drawn in place, so the cast gets frames of its own
+            renderer.cast = NewRenderer(cast, true)
+            renderer.cast.pane = NewStatsPane(cfg.Pane, s.Seed)
+            renderer.cast.overlay = cfg.Overlay
+        }
+        s.redraw = func() { renderer.Submit(s.World, s.Chronon) }
+    }
+
+    var tracer *Tracer
+    if cfg.OTLP != "" {
+        tracer = NewTracer(cfg.OTLP, cfg.TraceEvery)
+    }
+    alerter := NewAlerter(cfg.AlertURL, cfg.AlertOn, s.Seed) // population alarms, see alert.go
+
+    // the last frames, written out if the run ends in a crash and played back by the console
+    var crash string // what ended the run, when it is worth a crash dump
+    keep := cfg.Rewind
+    if cfg.CrashDump != "" {
+        keep = max(keep, cfg.CrashFrames)
+    }
+    if keep > 0 {
+        s.frames = NewFrameRing(keep)
+        s.frames.Add(s.World, s.Chronon, Events{})
+    }
+
+    // the stats and blocks files start a new file as they rotate, see rotate.go
+    statsFiles := newRotatingPath(cfg.StatsCSV, cfg.Rotation(), s.Chronon)
+    blockFiles := newRotatingPath(cfg.BlocksCSV, cfg.Rotation(), s.Chronon)
 
+    // the grid of every sampled chronon, starting with the world the run begins from
+    var frames *FrameWriter
+    if cfg.DumpFrames != "" {
+        var err error
+        if frames, err = CreateFrameWriter(cfg.DumpFrames, cfg.Rotation(), s.Chronon); err == nil {
+            err = frames.Write(s.World, s.Chronon)
+        }
+        if err != nil {
+            fmt.Printf("Could not write frames %s: %v\n", cfg.DumpFrames, err)
+            frames = nil
+        }
+    }
+
+    // every stride chronons of the run, written as an animation once it ends
+    var lapse *Timelapse
+    if cfg.Timelapse != "" {
+        lapse = NewTimelapse(cfg.Timelapse, cfg.TimelapseStride, cfg.TimelapseScale)
+        lapse.Sample(s.World, s.Chronon)
+    }
+
+    // frames, timelapse frames and the stats and blocks files are written behind the step loop, see pipeline.go
+    output := NewOutputPipeline(cfg.OutputQueue)
+    var framesErr error // the first error writing frames, owned by the jobs
+
+    // the configuration file tunable parameters are reloaded from
+    pauseMet := false // whether a -pause-on condition held after the previous chronon
+    var watch *ConfigWatcher
+    if cfg.Reload != "" {
+        watch = NewConfigWatcher(cfg.Reload)
+        defer watch.Close()
+    }
+
+    pacer := NewPacer(cfg.ChrononDuration, s.Chronon) // chronons of a fixed length of time, see pace.go
     for {
-        chronon++
+        // answer console commands, which may pause the run or change its parameters
+        if s.Console != nil {
+            if s.Console.Poll(s) {
+                fmt.Printf("Stopped from the console at chronon %d\n", s.Chronon)
+                break
+            }
+            cfg = s.Config
+        }
+        if watch != nil && watch.Due() {
+            fmt.Println(s.reloadLine(cfg.Reload))
+            cfg = s.Config
+        }
+        pacer.Wait(s.Chronon)
+
+        // encounter densities describe the world the sharks hunt in, before the chronon
+        var encounters Encounters
+        measure := cfg.EncountersEvery > 0 && (s.Chronon+1)%cfg.EncountersEvery == 0
+        if measure {
+            encounters = measureEncounters(s.World)
+        }
 
         // advance one chronon (potentially using multiple threads)
-        w = StepWorld(w, cfg, rnd)
+        span := tracer.StartChronon(s.Chronon + 1)
+        phase := span.Child("step")
+        if tuner != nil {
+            tuner.Before(s)
+        }
+        t, stepped := time.Now(), s.Chronon
+        s.Step()
+        phases.Step += time.Since(t)
+        if tuner != nil {
+            tuner.After(s, time.Since(t))
+        }
+        throughput.Add(s.World, alive, s.Chronon-stepped)
+        phase.End()
+        w, chronon := s.World, s.Chronon
+        if follower != nil {
+            follower.Observe(w, chronon)
+        }
+
+        // draw occasionally, frames are skipped if a terminal can't keep up
+        if renderer != nil && chronon%cfg.DrawEvery == 0 {
+            phase = span.Child("draw")
+            t = time.Now()
+            renderer.Submit(w, chronon)
+            phases.Draw += time.Since(t)
+            phase.End()
+        }
+
+        phase = span.Child("count")
+        t = time.Now()
+        fish := countEntities(w, Fish)
+        sharks := countEntities(w, Shark)
+        orcas := 0
+        if cfg.NumOrca > 0 {
+            orcas = countEntities(w, Orca)
+        }
+        alive = fish + sharks + orcas
+        if cfg.FoodWeb != nil {
+            alive = creatureCount(cfg, w)
+        }
+        phases.Count += time.Since(t)
+        phase.End()
+        span.SetInt("fish", fish)
+        span.SetInt("sharks", sharks)
+
+        recordChronon(chronon, fish, sharks, orcas)
+        events.Add(w.Events)
+        if s.frames != nil {
+            s.frames.Add(w, chronon, w.Events)
+        }
+        if cfg.Lag {
+            series.Add(fish, sharks)
+        }
+        if report != nil {
+            report.Add(cfg, w, chronon)
+        }
+
+        // per-block counts for spatial analysis
+        t = time.Now()
+        view := s.LatestFrame().World // what the output jobs of this chronon read
+        if !cfg.Blocks.IsZero() && chronon%cfg.BlocksEvery == 0 {
+            if cfg.BlocksCSV == "" {
+                blockFish, blockSharks := cfg.Blocks.Counts(w)
+                fmt.Println(blockLine(chronon, blockFish, blockSharks))
+            } else {
+                output.Submit(func() {
+                    blockFish, blockSharks := cfg.Blocks.Counts(view)
+                    if err := appendCSVRow(rotatedPath(cfg.BlocksCSV, blockFiles, chronon), blockHeader, blockRows(chronon, blockFish, blockSharks)); err != nil {
+                        fmt.Printf("Could not write blocks file %s: %v\n", cfg.BlocksCSV, err)
+                    }
+                })
+            }
+        }
+
+        if measure {
+            encounters.FishEaten = w.Events.FishEaten
+            fmt.Printf("Chronon: %d  %s\n", chronon, encounters)
+        }
+
+        if cfg.Deaths {
+            after := population{fish, sharks, orcas}
+            deaths.Record(before, after, w.Events)
+            recentDeaths.Record(before, after, w.Events)
+            before = after
+            if cfg.DeathsEvery > 0 && chronon%cfg.DeathsEvery == 0 {
+                fmt.Printf("Chronon: %d  Deaths  %s\n", chronon, recentDeaths.Line(cfg.NumOrca > 0))
+                recentDeaths = Mortality{}
+            }
+        }
+
+        if lapse != nil && chronon%cfg.TimelapseStride == 0 {
+            output.Submit(func() { lapse.Sample(view, chronon) })
+        }
+
+        if frames != nil && chronon%cfg.DumpEvery == 0 {
+            output.Submit(func() {
+                if framesErr != nil {
+                    return
+                }
+                if framesErr = frames.Write(view, chronon); framesErr != nil {
+                    fmt.Printf("Could not write frames %s: %v\n", cfg.DumpFrames, framesErr)
+                    frames.Close()
+                }
+            })
+        }
+
+        // digest of the grid, for comparing runs without writing snapshots
+        if cfg.HashEvery > 0 && chronon%cfg.HashEvery == 0 {
+            fmt.Printf("Chronon: %d  Hash: %016x\n", chronon, w.Hash())
+        }
+
+        // periodic one-line summary, independent of drawing and allowed in headless mode
+        if cfg.StatsEvery > 0 && chronon%cfg.StatsEvery == 0 {
+            now := time.Now()
+            rate := float64(cfg.StatsEvery) / now.Sub(lastStats).Seconds()
+            fmt.Printf("Chronon: %d  %s  Elapsed: %v  Chronons/sec: %.1f  %s\n",
+                chronon, populationLine(w), now.Sub(start).Round(time.Millisecond), rate, events)
+            if cfg.StatsCSV != "" {
+                elapsed, events := now.Sub(start), events
+                output.Submit(func() {
+                    if err := appendCSVRow(rotatedPath(cfg.StatsCSV, statsFiles, chronon), statsHeader, statsRow(cfg, view, chronon, elapsed, events)); err != nil {
+                        fmt.Printf("Could not write stats file %s: %v\n", cfg.StatsCSV, err)
+                    }
+                })
+            }
+            lastStats, events = now, Events{}
+        }
+
+        // periodic checkpoint so long runs can be resumed
+        if cfg.Checkpoint != "" && cfg.CheckpointEvery > 0 && chronon%cfg.CheckpointEvery == 0 {
+            phase = span.Child("checkpoint")
+            checkpoints.Write(s)
+            phase.End()
+        }
+        phases.IO += time.Since(t)
+        span.End()
+
+        // chronon breakpoints pause an interactive run and snapshot any other
+        if cfg.BreakAt.Has(chronon) {
+            if s.Console != nil {
+                fmt.Printf("Breakpoint at chronon %d\n", chronon)
+                s.Console.Pause()
+            } else {
+                path := breakpointPath(cfg.Snapshot, chronon)
+                if err := WriteSnapshot(path, w, chronon); err != nil {
+                    fmt.Printf("Could not write breakpoint snapshot %s: %v\n", path, err)
+                } else {
+                    fmt.Printf("Breakpoint at chronon %d: wrote %s\n", chronon, path)
+                }
+            }
+        }
+
+        alerter.Check(cfg, w, chronon, fish, sharks)
+
+        // stop if the fish or every predator is extinct, or in a food web once fewer than two species survive
+        if cfg.FoodWeb != nil {
+            if cfg.FoodWeb.Surviving(w) < 2 {
+                crash = "an extinction"
+                alerter.Extinction(cfg, w, chronon)
+                break
+            }
+        } else if fish == 0 || sharks+orcas == 0 {
+            crash = "an extinction"
+            alerter.Extinction(cfg, w, chronon)
+            break
+        }
+
+        // stop on user supplied population thresholds
+        if c, ok := cfg.StopIf.FirstMet(fish, sharks); ok {
+            fmt.Printf("Stopping at chronon %d: %s\n", chronon, c)
+            crash = c.String()
+            break
+        }
 
-        // draw occasionally (only with small grids / Threads=1 ideally)
-        if cfg.DrawEvery > 0 && chronon%cfg.DrawEvery == 0 {
-            drawWorld(w, chronon)
+        // pause an interactive run as a trigger starts to hold, so stepping on past it does not pause again
+        if s.Console != nil && len(cfg.PauseOn) > 0 {
+            c, met := cfg.PauseOn.FirstMet(fish, sharks)
+            if met && !pauseMet {
+                fmt.Printf("Pausing at chronon %d: %s\n", chronon, c)
+                s.Console.Pause()
+            }
+            pauseMet = met
         }
 
-        // stop if either species is extinct
-        if countEntities(w, Fish) == 0 || countEntities(w, Shark) == 0 {
+        // wall-clock limit, the run still finishes normally
+        if cfg.MaxTime > 0 && time.Since(start) >= cfg.MaxTime {
+            fmt.Printf("Time limit of %v reached at chronon %d\n", cfg.MaxTime, chronon)
             break
         }
 
@@ -64,38 +557,138 @@ func RunSimulation(cfg Config, w *World) {
         }
     }
 
+    pacer.Finish(s.Chronon)
     elapsed := time.Since(start)
-    fmt.Printf("Threads: %d  Time: %v\n", cfg.Threads, elapsed)
+    throughput.StopAllocs()
+    if s.Console != nil {
+        s.Console.Close()
+    }
+    tracer.Close()
+    alerter.Close()
+    output.Close()
+    output.Report()
+    cfg.Threads = s.Config.Threads // as auto-tuning left it, once the output jobs are done with cfg
+    if frames != nil && framesErr == nil {
+        if err := frames.Close(); err != nil {
+            fmt.Printf("Could not write frames %s: %v\n", cfg.DumpFrames, err)
+        }
+    }
+    if lapse != nil {
+        if n, err := lapse.Finish(s.World, s.Chronon); err != nil {
+            fmt.Printf("Could not write timelapse %s: %v\n", cfg.Timelapse, err)
+        } else {
+            fmt.Printf("Wrote %d timelapse frames to %s\n", n, cfg.Timelapse)
+        }
+    }
+
+    if renderer != nil {
+        if err := renderer.Close(); err != nil {
+            fmt.Printf("Could not draw world: %v\n", err)
+        }
+        renderer.Report()
+    }
+    if cast != nil {
+        if err := cast.Close(); err != nil {
+            fmt.Printf("Could not write cast %s: %v\n", cfg.Cast, err)
+        }
+    }
+
+    result := RunResult{
+        Chronons: s.Chronon,
+        Fish:     countEntities(s.World, Fish),
+        Sharks:   countEntities(s.World, Shark),
+        Elapsed:  elapsed,
+    }
+    // the summary lines are kept for the report as well
+    var summary []string
+    say := func(format string, a ...any) {
+        line := fmt.Sprintf(format, a...)
+        fmt.Println(line)
+        summary = append(summary, line)
+    }
+    say("Threads: %d  Time: %v", cfg.Threads, elapsed)
+    say("%s", phases.Line(elapsed))
+    say("%s", throughput.Line(elapsed))
+    if pacer != nil {
+        say("%s", pacer.Line(s.Chronon))
+    }
+    if diag != nil {
+        diag.Report()
+    }
+    say("Chronons: %d  %s  Seed: %d", result.Chronons, populationLine(s.World), s.Seed)
+    if cfg.HashEvery > 0 {
+        say("Hash: %016x", s.World.Hash())
+    }
+    if cfg.Deaths {
+        say("Deaths  %s", deaths.Line(cfg.NumOrca > 0))
+    }
+    if s.energy != nil {
+        say("Energy  %s", s.energy.Line())
+    }
+    if cfg.Lag {
+        say("%s", series.LagLine())
+    }
+    if s.lives != nil && cfg.Lifetimes != "" {
+        fmt.Println(s.lives.Summary(s.Chronon))
+        if err := s.lives.WriteCSV(cfg.Lifetimes, s.Chronon); err != nil {
+            fmt.Printf("Could not write lifetimes %s: %v\n", cfg.Lifetimes, err)
+        }
+    }
+    s.lives = nil
+    if follower != nil {
+        if err := follower.Close(); err != nil {
+            fmt.Printf("Could not write %s: %v\n", cfg.FollowCSV, err)
+        }
+    }
+
+    if report != nil {
+        r := Report{Config: cfg, Seed: s.Seed, Result: result, Series: report, World: s.World, Summary: summary}
+        if err := WriteReport(cfg.Report, r); err != nil {
+            fmt.Printf("Could not write report %s: %v\n", cfg.Report, err)
+        } else {
+            fmt.Printf("Wrote report to %s\n", cfg.Report)
+        }
+    }
+
+    if cfg.CrashDump != "" && crash != "" {
+        if n, err := s.frames.Dump(cfg.CrashDump, cfg.CrashFrames); err != nil {
+            fmt.Printf("Could not write crash frames %s: %v\n", cfg.CrashDump, err)
+        } else {
+            fmt.Printf("Wrote the last %d frames before %s to %s\n", n, crash, cfg.CrashDump)
+        }
+    }
 
     // If a benchmark file was provided, append a CSV line
-    writeBenchmarkLine(cfg, elapsed)
-}
+    writeBenchmarkLine(cfg, s.Seed, elapsed, throughput)
 
-//  @brief Writes one line of benchmark CSV if BenchFile is set
-func writeBenchmarkLine(cfg Config, elapsed time.Duration) {
-    if cfg.BenchFile == "" {
-        return
+    if cfg.Snapshot != "" {
+        if err := WriteSnapshot(cfg.Snapshot, s.World, s.Chronon); err != nil {
+            fmt.Printf("Could not write snapshot %s: %v\n", cfg.Snapshot, err)
+        }
     }
 
-    f, err := os.OpenFile(cfg.BenchFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-    if err != nil {
-        fmt.Printf("Could not open benchmark file %s: %v\n", cfg.BenchFile, err)
-        return
+    if cfg.Checkpoint != "" {
+        checkpoints.Wait()
+        if err := WriteCheckpoint(cfg.Checkpoint, s); err != nil {
+            fmt.Printf("Could not write checkpoint %s: %v\n", cfg.Checkpoint, err)
+        }
     }
-    defer f.Close()
 
-    // If file is empty, write a header row
-    info, err := f.Stat()
-    if err == nil && info.Size() == 0 {
-        fmt.Fprintln(f, "Threads,GridSize,NumFish,NumShark,FishBreed,SharkBreed,Starve,Chronons,TimeMillis")
+    return result
+}
+
+//  @brief Writes one line of benchmark CSV if BenchFile is set
+func writeBenchmarkLine(cfg Config, seed int64, elapsed time.Duration, throughput Throughput) {
+    if cfg.BenchFile == "" {
+        return
     }
 
     millis := elapsed.Milliseconds()
+    cells, creatures := throughput.Rates(elapsed)
 
     // One CSV row per run
-    fmt.Fprintf(
-        f,
-        "%d,%d,%d,%d,%d,%d,%d,%d,%d\n",
+    row := fmt.Sprintf(
+        "%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%.0f,%.0f,%.0f",
         cfg.Threads,
         cfg.GridSize,
         cfg.NumFish,
@@ -105,36 +698,34 @@ func writeBenchmarkLine(cfg Config, elapsed time.Duration) {
         cfg.Starve,
         cfg.Chronons,
         millis,
+        seed,
+        cells,
+        creatures,
+        throughput.AllocsPerChronon(),
     )
-}
-
-//  @brief Prints the current world grid to the terminal
-func drawWorld(w *World, chronon int) {
-    fmt.Printf("Chronon: %d\n", chronon)
 
-    for row := 0; row < w.Size; row++ {
-        for col := 0; col < w.Size; col++ {
-            cell := w.Cells[row][col]
-            switch cell.Entity {
-            case Empty:
-                fmt.Print("~")
-            case Fish:
-                fmt.Print("F")
-            case Shark:
-                fmt.Print("S")
-            }
-        }
-        fmt.Println()
+    header := "Threads,GridSize,NumFish,NumShark,FishBreed,SharkBreed,Starve,Chronons,TimeMillis,Seed,CellsPerSec,CreaturesPerSec,AllocsPerChronon"
+    if err := appendCSVRow(cfg.BenchFile, header, row); err != nil {
+        fmt.Printf("Could not write benchmark file %s: %v\n", cfg.BenchFile, err)
     }
+}
+
+//  @brief Header of the -stats-csv file, one row per stats line
+const statsHeader = "Chronon,Fish,Sharks,Orcas,FishBirths,SharkBirths,FishEaten,SharksStarved,FishEntropy,SharkEntropy,ElapsedMillis"
 
-    fmt.Printf("Fish: %d  Sharks: %d\n", countEntities(w, Fish), countEntities(w, Shark))
-    fmt.Println()
+//  @brief Formats one row of the stats file: the populations, the events since the last row and the spatial entropies
+func statsRow(cfg Config, w *World, chronon int, elapsed time.Duration, events Events) string {
+    fish, sharks := entropyBlocks(cfg).Counts(w)
+    return fmt.Sprintf("%d,%d,%d,%d,%d,%d,%d,%d,%.4f,%.4f,%d",
+        chronon, countEntities(w, Fish), countEntities(w, Shark), countEntities(w, Orca),
+        events.FishBirths, events.SharkBirths, events.FishEaten, events.SharksStarved,
+        blockEntropy(fish), blockEntropy(sharks), elapsed.Milliseconds())
 }
 
 //  @brief Counts how many cells currently contain the given entity type
 func countEntities(w *World, e Entity) int {
     count := 0
-    for row := 0; row < w.Size; row++ {
+    for row := 0; row < w.Rows(); row++ {
         for col := 0; col < w.Size; col++ {
             if w.Cells[row][col].Entity == e {
                 count++
@@ -144,226 +735,535 @@ func countEntities(w *World, e Entity) int {
     return count
 }
 
-//  @brief Advances the world by one chronon (multi-threaded using goroutines)
-func StepWorld(w *World, cfg Config, rnd *rand.Rand) *World {
-    next := newEmptyWorldLike(w)
-
-    threads := cfg.Threads
+//  @brief Returns how many worker goroutines step a world of the given size
+func workerCount(threads, size int) int {
     if threads < 1 {
         threads = 1
     }
-    if threads > w.Size {
+    if threads > size {
         // no point having more threads than rows
-        threads = w.Size
+        threads = size
     }
+    return threads
+}
 
-    rowsPerThread := w.Size / threads
-    remainder := w.Size % threads
-
-    var wg sync.WaitGroup
+//  @brief Advances the world by one chronon, every worker stepping its band of rows as cfg.Strategy runs them (see strategy.go)
+//  @param "rngs" One random stream per worker, see workerCount
+func StepWorld(w *World, cfg Config, rngs []RNG) *World {
+    next := newEmptyWorldLike(w)
     var mu sync.Mutex // protects writes to "next"
 
-    startRow := 0
-    for t := 0; t < threads; t++ {
-        extra := 0
-        if t < remainder {
-            extra = 1
+    bandRunner(cfg.Strategy)(w.Rows(), len(rngs), func(t, start, end int) {
+        for row := start; row < end; row++ {
+            for col := 0; col < w.Size; col++ {
+                stepCell(w, next, row, col, cfg, rngs[t], &mu)
+            }
         }
-        endRow := startRow + rowsPerThread + extra
+    })
 
-        wg.Add(1)
+    return next
+}
 
-        go func(start, end int) {
+//  @brief Splits rows into bands of consecutive rows, one per worker (see bandRows), and runs fn on every band in a goroutine of its own
+//  Returns once all of them have finished
+func inBands(rows, workers int, fn func(worker, start, end int)) {
+    var wg sync.WaitGroup
+    for t := 0; t < workers; t++ {
+        start, end := bandRows(rows, workers, t)
+        wg.Add(1)
+        go func() {
             defer wg.Done()
-
-            // per-goroutine RNG
-            localRnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(start)))
-
-            for row := start; row < end; row++ {
-                for col := 0; col < w.Size; col++ {
-
-                    cell := w.Cells[row][col]
-                    if cell.Entity == Empty {
-                        continue
-                    }
-
-                    switch cell.Entity {
-                    case Fish:
-                        stepFish(w, next, row, col, cfg, localRnd, &mu)
-                    case Shark:
-                        stepShark(w, next, row, col, cfg, localRnd, &mu)
-                    }
-                }
-            }
-
-        }(startRow, endRow)
-
-        startRow = endRow
+            fn(t, start, end)
+        }()
     }
-
     wg.Wait()
+}
 
-    return next
+//  @brief Applies the rules for whatever occupies (row, column) of current, writing the result into next
+func stepCell(current *World, next *World, row, col int, cfg Config, rnd RNG, mu *sync.Mutex) {
+    if cfg.EntityRNG {
+        rnd = entityRNG(current.Cells[row][col], rnd)
+    }
+    switch e := current.Cells[row][col].Entity; {
+    case e == Empty:
+        return
+    case e >= firstSpecies:
+        stepSpecies(current, next, row, col, cfg, rnd, mu)
+    case e == Fish:
+        stepFish(current, next, row, col, cfg, rnd, mu)
+    case e == Shark:
+        stepShark(current, next, row, col, cfg, rnd, mu)
+    case e == Orca:
+        stepOrca(current, next, row, col, cfg, rnd, mu)
+    case e == Corpse:
+        stepCorpse(current, next, row, col, mu)
+    case e == Egg:
+        stepEgg(current, next, row, col, cfg, mu)
+    }
 }
 
-//  @brief Handles movement and reproduction for a single fish at (row, column)
-func stepFish(current *World, next *World, row, col int, cfg Config, rnd *rand.Rand, mu *sync.Mutex) {
+//  @brief Handles movement, maturing and reproduction for a single fish at (row, column)
+func stepFish(current *World, next *World, row, col int, cfg Config, rnd RNG, mu *sync.Mutex) {
     cell := current.Cells[row][col]
-    neighbors := current.Neighbors(row, col)
 
-    emptySpots := make([][2]int, 0)
+    // Juveniles count their age in the breed timer, which restarts once they mature
+    timer, stage := cell.BreedTimer+1, cell.Stage
+    if stage == Juvenile && timer >= cfg.FishMature {
+        timer, stage = 0, Adult
+    }
+
+    // Newborn fish start as juveniles only when life stages are enabled
+    born := Adult
+    if cfg.FishMature > 0 {
+        born = Juvenile
+    }
 
     // Look for empty neighbors in CURRENT world (not next)
-    for _, n := range neighbors {
-        nr, nc := n[0], n[1]
-        if current.Cells[nr][nc].Entity == Empty {
-            emptySpots = append(emptySpots, [2]int{nr, nc})
-        }
+    var scratch stepScratch
+    neighbors := current.appendNeighbors(scratch.neighbors[:0], row, col)
+    emptySpots := current.appendKept(scratch.targets[:0], neighbors, isEntity(Empty))
+    emptySpots = current.towardDepth(scratch.depth[:0], emptySpots, row, cfg.FishDepth)
+
+    log := cfg.explain.Cell(row, col)
+    if log != nil {
+        log.note("fish  timer %d of %d  neighbours %s -> empty %s", timer, cfg.FishBreed, neighborGlyphs(current, neighbors), cellList(emptySpots))
     }
 
     // No movement
     if len(emptySpots) == 0 {
+        if log != nil {
+            log.note("fish  stays, no empty neighbour, no birth")
+        }
         mu.Lock()
-        next.Cells[row][col] = Cell{
+        next.place(row, col, Cell{
             Entity:     Fish,
-            BreedTimer: cell.BreedTimer + 1,
-        }
+            BreedTimer: timer,
+            Stage:      stage,
+            Life:       cell.Life,
+        })
         mu.Unlock()
         return
     }
 
     // Pick random move
-    destination := emptySpots[rnd.Intn(len(emptySpots))]
+    pick := pickMove(cfg, current, Fish, row, col, emptySpots, rnd)
+    destination := emptySpots[pick]
     nr, nc := destination[0], destination[1]
+    if log != nil {
+        log.picked("fish", emptySpots, pick)
+    }
 
-    // Reproduction happens only ON MOVE
-    if cell.BreedTimer+1 >= cfg.FishBreed {
+    // Reproduction happens only ON MOVE, only for adults and only in a spawning season
+    if stage == Adult && timer >= cfg.FishBreed && cfg.spawning() {
+        if log != nil {
+            log.note("fish  timer %d >= %d, breeds, young left at (%d, %d)", timer, cfg.FishBreed, row, col)
+        }
         mu.Lock()
-        // Leave baby at original position
-        next.Cells[row][col] = Cell{
-            Entity:     Fish,
-            BreedTimer: 0,
+        if cfg.FishEggs > 0 {
+            // Lay an egg, which hatches later, see eggs.go
+            next.Events.EggsLaid++
+            next.place(row, col, Cell{Entity: Egg})
+        } else {
+            next.Events.FishBirths++
+            // Leave baby at original position
+            next.place(row, col, Cell{
+                Entity:     Fish,
+                BreedTimer: 0,
+                Stage:      born,
+            })
         }
         // Parent moves
-        next.Cells[nr][nc] = Cell{
+        next.place(nr, nc, Cell{
             Entity:     Fish,
             BreedTimer: 0,
-        }
+            Life:       cell.Life.bred(),
+        })
         mu.Unlock()
         return
     }
 
     // Normal movement
+    if log != nil {
+        if stage == Juvenile {
+            log.note("fish  juvenile, no birth")
+        } else {
+            log.note("fish  timer %d < %d, no birth", timer, cfg.FishBreed)
+        }
+    }
     mu.Lock()
-    next.Cells[nr][nc] = Cell{
+    next.place(nr, nc, Cell{
         Entity:     Fish,
-        BreedTimer: cell.BreedTimer + 1,
-    }
+        BreedTimer: timer,
+        Stage:      stage,
+        Life:       cell.Life,
+    })
     mu.Unlock()
 }
 
 //  @brief Handles movement, eating, reproduction and starvation for a shark at (row, column).
-func stepShark(current *World, next *World, row, col int, cfg Config, rnd *rand.Rand, mu *sync.Mutex) {
+func stepShark(current *World, next *World, row, col int, cfg Config, rnd RNG, mu *sync.Mutex) {
     cell := current.Cells[row][col]
 
-    // Shark loses 1 energy each turn
+    // Shark loses 1 energy each turn, more while pregnant
     newEnergy := cell.Energy - 1
+    if cell.Gestation > 0 {
+        newEnergy -= cfg.GestationCost
+    }
+    log := cfg.explain.Cell(row, col)
     if newEnergy <= 0 {
+        if log != nil {
+            log.note("shark  energy %d -> %d, starves", cell.Energy, newEnergy)
+        }
+        mu.Lock()
+        next.Events.SharksStarved++
+        next.Events.EnergySpent += cell.Energy
+        mu.Unlock()
+        leaveCorpse(next, row, col, cfg, mu)
         return // shark dies
     }
 
-    neighbors := current.Neighbors(row, col)
+    cell.Life = territoryLife(cfg, current, cell.Life, row, col, rnd)
+    birth, timer, gestation := sharkBreeding(cell, cfg)
+    if log != nil {
+        log.note("shark  energy %d -> %d  timer %d of %d  gestation %d  births on a move: %t", cell.Energy, newEnergy, timer, cfg.SharkBreed, gestation, birth)
+    }
+
+    // Books the energy spent this chronon and gained from a meal ending with energy, and that of a young if born, must be called with mu held
+    account := func(energy int, born bool) {
+        next.Events.EnergySpent += cell.Energy - newEnergy
+        next.Events.EnergyEaten += energy - newEnergy
+        if born {
+            next.Events.EnergyBorn += energy / 2
+        }
+    }
+
+    // Stays in place without eating or giving birth, must be called with mu held
+    stay := func() {
+        account(newEnergy, false)
+        next.place(row, col, Cell{Entity: Shark, BreedTimer: timer, Energy: newEnergy, Gestation: gestation, Life: cell.Life})
+    }
+
+    // A weak shark loses any cell another creature has already claimed this chronon, must be called with mu held
+    weak := cfg.WeakEnergy > 0 && newEnergy < cfg.WeakEnergy
+    yields := func(nr, nc int) bool {
+        if !weak || cfg.WeakMode != weakYield || next.Cells[nr][nc].Entity == Empty {
+            return false
+        }
+        stay()
+        return true
+    }
 
-    // 1. LOOK FOR FISH TO EAT
-    fishTargets := make([][2]int, 0)
-    for _, n := range neighbors {
-        nr, nc := n[0], n[1]
-        if current.Cells[nr][nc].Entity == Fish {
-            fishTargets = append(fishTargets, [2]int{nr, nc})
+    // 0. WEAK SHARKS REST EVERY OTHER CHRONON, energy falls by one each chronon so its parity alternates
+    if weak && cfg.WeakMode == weakSkip && newEnergy%2 == 0 {
+        if log != nil {
+            log.note("shark  weak (energy %d < %d), rests this chronon", newEnergy, cfg.WeakEnergy)
         }
+        mu.Lock()
+        stay()
+        mu.Unlock()
+        return
     }
 
+    // at most one of the lists below is used, so they share the targets array
+    var scratch stepScratch
+    neighbors := current.appendNeighbors(scratch.neighbors[:0], row, col)
+    if log != nil {
+        log.note("shark  neighbours %s", neighborGlyphs(current, neighbors))
+    }
+
+    // 1. LOOK FOR FISH (AND EGGS) TO EAT
+    fishTargets := current.appendKept(scratch.targets[:0], neighbors, isPrey)
+
     if len(fishTargets) > 0 {
-        destination := fishTargets[rnd.Intn(len(fishTargets))]
+        pick := pickSharkMove(cfg, current, cell, row, col, fishTargets, rnd)
+        destination := fishTargets[pick]
         nr, nc := destination[0], destination[1]
+        if log != nil {
+            log.note("shark  fish %s", cellList(fishTargets))
+            log.picked("shark eats", fishTargets, pick)
+        }
 
-        // Eating gives FULL energy
+        // Eating gives FULL energy, a juvenile or an egg only a partial meal
+        prey := current.Cells[nr][nc]
         gainedEnergy := cfg.Starve
+        if prey.Stage == Juvenile || prey.Entity == Egg {
+            gainedEnergy = min(newEnergy+cfg.JuvenileEnergy, cfg.Starve)
+        }
 
         mu.Lock()
         defer mu.Unlock()
+        if yields(nr, nc) {
+            return
+        }
+        if prey.Entity == Egg {
+            next.Events.EggsEaten++
+        } else {
+            next.Events.FishEaten++
+        }
+        account(gainedEnergy, birth)
 
         // Reproduction?
-        if cell.BreedTimer+1 >= cfg.SharkBreed {
+        if birth {
+            next.Events.SharkBirths++
             // Leave baby behind with HALF energy
-            next.Cells[row][col] = Cell{
+            next.place(row, col, Cell{
                 Entity:     Shark,
                 BreedTimer: 0,
                 Energy:     gainedEnergy / 2,
-            }
+            })
             // Parent moves to fish
-            next.Cells[nr][nc] = Cell{
+            next.place(nr, nc, Cell{
                 Entity:     Shark,
                 BreedTimer: 0,
                 Energy:     gainedEnergy,
-            }
+                Life:       cell.Life.bred(),
+            })
             return
         }
 
         // Normal move & eat
-        next.Cells[nr][nc] = Cell{
+        next.place(nr, nc, Cell{
             Entity:     Shark,
-            BreedTimer: cell.BreedTimer + 1,
+            BreedTimer: timer,
             Energy:     gainedEnergy,
+            Gestation:  gestation,
+            Life:       cell.Life,
+        })
+        return
+    }
+
+    // 2. NO FISH — SCAVENGE A CORPSE FOR PART OF A MEAL
+    if corpses := current.appendKept(scratch.targets[:0], neighbors, isEntity(Corpse)); len(corpses) > 0 {
+        pick := pickSharkMove(cfg, current, cell, row, col, corpses, rnd)
+        destination := corpses[pick]
+        nr, nc := destination[0], destination[1]
+        if log != nil {
+            log.note("shark  no fish, corpses %s", cellList(corpses))
+            log.picked("shark scavenges", corpses, pick)
+        }
+        gainedEnergy := scavengedEnergy(newEnergy, cfg.Starve, cfg)
+
+        mu.Lock()
+        defer mu.Unlock()
+        if yields(nr, nc) {
+            return
+        }
+        account(gainedEnergy, birth)
+
+        if birth {
+            next.Events.SharkBirths++
+            next.place(row, col, Cell{Entity: Shark, Energy: gainedEnergy / 2})
+            next.place(nr, nc, Cell{Entity: Shark, Energy: gainedEnergy, Life: cell.Life.bred()})
+            return
         }
+
+        next.place(nr, nc, Cell{
+            Entity:     Shark,
+            BreedTimer: timer,
+            Energy:     gainedEnergy,
+            Gestation:  gestation,
+            Life:       cell.Life,
+        })
         return
     }
 
-    // 2. NO FISH — MOVE LIKE FISH
-    emptyTargets := make([][2]int, 0)
-    for _, n := range neighbors {
-        nr, nc := n[0], n[1]
-        if current.Cells[nr][nc].Entity == Empty {
-            emptyTargets = append(emptyTargets, [2]int{nr, nc})
+    // 3. STARVING — ATTACK A NEIGHBOURING SHARK FOR PART OF A MEAL
+    if cfg.CannibalEnergy > 0 && newEnergy < cfg.CannibalEnergy {
+        if victims := current.appendKept(scratch.targets[:0], neighbors, isEntity(Shark)); len(victims) > 0 {
+            pick := pickSharkMove(cfg, current, cell, row, col, victims, rnd)
+            destination := victims[pick]
+            nr, nc := destination[0], destination[1]
+            if log != nil {
+                log.note("shark  starving (energy %d < %d), sharks %s", newEnergy, cfg.CannibalEnergy, cellList(victims))
+                log.picked("shark attacks", victims, pick)
+            }
+            gainedEnergy := min(newEnergy+cfg.CannibalGain, cfg.Starve)
+
+            mu.Lock()
+            defer mu.Unlock()
+            if yields(nr, nc) {
+                return
+            }
+            next.Events.SharksEaten++
+            account(gainedEnergy, birth)
+
+            if birth {
+                next.Events.SharkBirths++
+                next.place(row, col, Cell{Entity: Shark, Energy: gainedEnergy / 2})
+                next.place(nr, nc, Cell{Entity: Shark, Energy: gainedEnergy, Life: cell.Life.bred()})
+                return
+            }
+
+            next.place(nr, nc, Cell{
+                Entity:     Shark,
+                BreedTimer: timer,
+                Energy:     gainedEnergy,
+                Gestation:  gestation,
+                Life:       cell.Life,
+            })
+            return
         }
     }
 
+    // 4. NO FOOD — MOVE LIKE FISH
+    emptyTargets := current.appendKept(scratch.targets[:0], neighbors, isEntity(Empty))
+    emptyTargets = current.towardDepth(scratch.depth[:0], emptyTargets, row, cfg.SharkDepth)
+
+    if log != nil {
+        log.note("shark  no food, empty %s", cellList(emptyTargets))
+    }
     if len(emptyTargets) > 0 {
-        destination := emptyTargets[rnd.Intn(len(emptyTargets))]
+        pick := pickSharkMove(cfg, current, cell, row, col, emptyTargets, rnd)
+        destination := emptyTargets[pick]
         nr, nc := destination[0], destination[1]
+        if log != nil {
+            log.picked("shark moves", emptyTargets, pick)
+        }
 
         mu.Lock()
         defer mu.Unlock()
+        if yields(nr, nc) {
+            return
+        }
+        account(newEnergy, birth)
 
         // Reproduce?
-        if cell.BreedTimer+1 >= cfg.SharkBreed {
-            next.Cells[row][col] = Cell{
+        if birth {
+            next.Events.SharkBirths++
+            next.place(row, col, Cell{
                 Entity:     Shark,
                 BreedTimer: 0,
                 Energy:     newEnergy / 2,
-            }
-            next.Cells[nr][nc] = Cell{
+            })
+            next.place(nr, nc, Cell{
                 Entity:     Shark,
                 BreedTimer: 0,
                 Energy:     newEnergy,
-            }
+                Life:       cell.Life.bred(),
+            })
             return
         }
 
-        next.Cells[nr][nc] = Cell{
+        next.place(nr, nc, Cell{
             Entity:     Shark,
-            BreedTimer: cell.BreedTimer + 1,
+            BreedTimer: timer,
             Energy:     newEnergy,
-        }
+            Gestation:  gestation,
+            Life:       cell.Life,
+        })
         return
     }
 
-    // 3. Can't move
-    mu.Lock()
-    next.Cells[row][col] = Cell{
-        Entity:     Shark,
-        BreedTimer: cell.BreedTimer + 1,
-        Energy:     newEnergy,
+    // 5. Can't move, so any birth waits for the next move
+    if log != nil {
+        log.note("shark  stays, no empty neighbour")
     }
+    mu.Lock()
+    stay()
     mu.Unlock()
 }
+
+//  Ways a weak shark's behaviour is reduced (see Config.WeakMode)
+const (
+    weakSkip  = "skip"  //  Rests instead of moving every other chronon
+    weakYield = "yield" //  Loses contested cells to any creature that claimed them first
+)
+
+//  @brief Decides whether a shark gives birth if it moves this chronon
+//  Without a gestation period a shark gives birth as soon as its breed timer is up. With one, it
+//  first becomes pregnant for cfg.Gestation chronons, and gives birth on its first move after that
+//  @return Whether a move gives birth, and the breed timer and gestation the shark keeps if it does not
+func sharkBreeding(cell Cell, cfg Config) (bool, int, int) {
+    switch {
+    case cfg.Gestation == 0:
+        return cell.BreedTimer+1 >= cfg.SharkBreed, cell.BreedTimer + 1, 0
+    case cell.Gestation == 1:
+        return true, cell.BreedTimer + 1, 1
+    case cell.Gestation > 1:
+        return false, cell.BreedTimer + 1, cell.Gestation - 1
+    case cell.BreedTimer+1 >= cfg.SharkBreed:
+        return false, cell.BreedTimer + 1, cfg.Gestation
+    }
+    return false, cell.BreedTimer + 1, 0
+}
+
+//  @brief Handles movement, eating, reproduction and starvation for a single orca at (row, column)
+//  Orcas follow the shark rules one trophic level up: they hunt sharks first, then (with OrcaEatsFish) fish
+func stepOrca(current *World, next *World, row, col int, cfg Config, rnd RNG, mu *sync.Mutex) {
+    cell := current.Cells[row][col]
+
+    // Orca loses 1 energy each turn
+    energy := cell.Energy - 1
+    if energy <= 0 {
+        mu.Lock()
+        next.Events.OrcasStarved++
+        next.Events.EnergySpent += cell.Energy
+        mu.Unlock()
+        leaveCorpse(next, row, col, cfg, mu)
+        return // orca dies
+    }
+
+    var scratch stepScratch
+    neighbors := current.appendNeighbors(scratch.neighbors[:0], row, col)
+
+    // 1. LOOK FOR SHARKS, then fish if allowed, otherwise an empty cell
+    var targets [][2]int
+    eats := Empty
+    for _, prey := range [...]Entity{Shark, Fish} {
+        if prey == Fish && !cfg.OrcaEatsFish {
+            break
+        }
+        if targets = current.appendKept(scratch.targets[:0], neighbors, isEntity(prey)); len(targets) > 0 {
+            // Eating gives FULL energy
+            energy, eats = cfg.OrcaStarve, prey
+            break
+        }
+    }
+    if len(targets) == 0 {
+        targets = current.appendKept(scratch.targets[:0], neighbors, isEntity(Empty))
+    }
+
+    log := cfg.explain.Cell(row, col)
+    if log != nil {
+        prey := "empty"
+        if eats != Empty {
+            prey = entityName(eats)
+        }
+        log.note("orca  energy %d -> %d  timer %d of %d  neighbours %s -> %s %s", cell.Energy, energy, cell.BreedTimer+1, cfg.OrcaBreed,
+            neighborGlyphs(current, neighbors), prey, cellList(targets))
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+    next.Events.EnergySpent++
+    next.Events.EnergyEaten += energy - (cell.Energy - 1)
+
+    // 2. Can't move
+    if len(targets) == 0 {
+        next.place(row, col, Cell{Entity: Orca, BreedTimer: cell.BreedTimer + 1, Energy: energy, Life: cell.Life})
+        return
+    }
+
+    pick := pickMove(cfg, current, Orca, row, col, targets, rnd)
+    destination := targets[pick]
+    if log != nil {
+        log.picked("orca", targets, pick)
+    }
+    switch eats {
+    case Fish:
+        next.Events.FishEaten++
+    case Shark:
+        next.Events.SharksEaten++
+    }
+
+    // 3. Reproduce? The baby stays behind with HALF energy
+    if cell.BreedTimer+1 >= cfg.OrcaBreed {
+        next.Events.OrcaBirths++
+        next.Events.EnergyBorn += energy / 2
+        next.place(row, col, Cell{Entity: Orca, Energy: energy / 2})
+        next.place(destination[0], destination[1], Cell{Entity: Orca, Energy: energy, Life: cell.Life.bred()})
+        return
+    }
+
+    next.place(destination[0], destination[1], Cell{Entity: Orca, BreedTimer: cell.BreedTimer + 1, Energy: energy, Life: cell.Life})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "image"
+    "image/color"
+    "os"
+    "sort"
+)
+
+/**
+    @file snapdiff.go
+    @brief The diff subcommand: compares two world snapshots
+    Reports the count of every species in both snapshots and the change,
+    how many cells hold a different creature, and how many hold the same
+    creature in a different state (breed timer or energy), e.g.
+
+        wa-tor diff before.json after.json
+        wa-tor diff -image diff.png -scale 4 before.json after.json
+
+    With -image the differences are drawn to a PNG file, one square of
+    -scale pixels per cell: unchanged cells are white when empty and light
+    grey when occupied, changed cells take the colour of what the second
+    snapshot holds there, blue for fish, red for sharks, black for an
+    emptied cell and grey for anything else. Like diff(1) the command exits
+    with status 1 when the worlds differ.
+*/
+
+//  @brief Colours of a changed cell in the diff image, by what the second snapshot holds
+var diffColors = map[byte]color.RGBA{
+    'F': chartBlue,
+    'S': chartRed,
+    '~': chartAxis,
+}
+
+//  @brief SnapshotDiff describes how one snapshot differs from another of the same shape
+type SnapshotDiff struct {
+    Counts  map[byte][2]int //  Cells holding each glyph in the first and second snapshot, empty cells left out
+    Cells   int             //  Cells compared
+    Changed int             //  Cells holding a different creature
+    State   int             //  Cells holding the same creature with a different breed timer or energy
+}
+
+//  @brief Compares snapshot b against a, which must have the same size and depth
+func DiffSnapshots(a, b Snapshot) (SnapshotDiff, error) {
+    if a.Size != b.Size || len(a.Rows) != len(b.Rows) {
+        return SnapshotDiff{}, fmt.Errorf("the snapshots have different shapes, %d rows of %d and %d rows of %d",
+            len(a.Rows), a.Size, len(b.Rows), b.Size)
+    }
+
+    d := SnapshotDiff{Counts: make(map[byte][2]int)}
+    for row := range a.Rows {
+        for col := 0; col < a.Size; col++ {
+            ga, gb := a.Rows[row][col], b.Rows[row][col]
+            d.count(ga, 0)
+            d.count(gb, 1)
+            d.Cells++
+            switch {
+            case ga != gb:
+                d.Changed++
+            case ga != '~' && a.state(row, col) != b.state(row, col):
+                d.State++
+            }
+        }
+    }
+    return d, nil
+}
+
+//  @brief Returns the breed timer and energy of a cell, zero when the snapshot does not store them
+func (s Snapshot) state(row, col int) [2]int {
+    var st [2]int
+    if row < len(s.BreedTimer) && col < len(s.BreedTimer[row]) {
+        st[0] = s.BreedTimer[row][col]
+    }
+    if row < len(s.Energy) && col < len(s.Energy[row]) {
+        st[1] = s.Energy[row][col]
+    }
+    return st
+}
+
+//  @brief Counts one occupied cell of snapshot i
+func (d *SnapshotDiff) count(glyph byte, i int) {
+    if glyph == '~' {
+        return
+    }
+    c := d.Counts[glyph]
+    c[i]++
+    d.Counts[glyph] = c
+}
+
+//  @brief Reports whether the snapshots differ in any cell
+func (d SnapshotDiff) Differs() bool {
+    return d.Changed > 0 || d.State > 0
+}
+
+//  @brief Returns the name of the creature drawn with glyph, or the glyph itself for species not loaded
+func glyphName(glyph byte) string {
+    if e, ok := entityForGlyph(glyph); ok {
+        return entityName(e)
+    }
+    return string(glyph)
+}
+
+//  @brief Prints the count of every creature with its change, then the changed cells
+func (d SnapshotDiff) Print() {
+    glyphs := make([]byte, 0, len(d.Counts))
+    for g := range d.Counts {
+        glyphs = append(glyphs, g)
+    }
+    sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+
+    for _, g := range glyphs {
+        c := d.Counts[g]
+        fmt.Printf("%s: %d -> %d (%+d)\n", glyphName(g), c[0], c[1], c[1]-c[0])
+    }
+    fmt.Printf("Changed cells: %d of %d (%.2f%%)  Same creature, different state: %d\n",
+        d.Changed, d.Cells, 100*float64(d.Changed)/float64(d.Cells), d.State)
+}
+
+//  @brief Draws the differences of b from a, scale pixels per cell
+func diffImage(a, b Snapshot, scale int) *image.RGBA {
+    img := image.NewRGBA(image.Rect(0, 0, a.Size*scale, len(a.Rows)*scale))
+    for row := range a.Rows {
+        for col := 0; col < a.Size; col++ {
+            ga, gb := a.Rows[row][col], b.Rows[row][col]
+            fill := chartBackground
+            switch {
+            case ga != gb:
+                c, ok := diffColors[gb]
+                if !ok {
+                    c = chartGrey
+                }
+                fill = c
+            case ga != '~':
+                fill = chartGrid
+            }
+            fillRect(img, col*scale, row*scale, (col+1)*scale, (row+1)*scale, fill)
+        }
+    }
+    return img
+}
+
+//  @brief Entry point of the diff subcommand
+func runDiff(args []string) {
+    fs := flag.NewFlagSet("diff", flag.ExitOnError)
+    imageFlag := fs.String("image", "", "Draw the differences to this PNG file (optional)")
+    scaleFlag := fs.Int("scale", 4, "Pixels per cell in the -image file")
+    fs.Parse(args)
+
+    if fs.NArg() != 2 {
+        fmt.Println("Usage: wa-tor diff [-image diff.png] [-scale N] a.json b.json")
+        os.Exit(1)
+    }
+    if *scaleFlag < 1 {
+        fmt.Println("Error: -scale must be 1 or greater.")
+        os.Exit(1)
+    }
+
+    var snaps [2]Snapshot
+    for i, path := range fs.Args() {
+        s, err := ReadSnapshot(path)
+        if err != nil {
+            fmt.Printf("Error: %v\n", err)
+            os.Exit(1)
+        }
+        snaps[i] = s
+    }
+
+    d, err := DiffSnapshots(snaps[0], snaps[1])
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+    fmt.Printf("Chronon: %d -> %d\n", snaps[0].Chronon, snaps[1].Chronon)
+    d.Print()
+
+    if *imageFlag != "" {
+        if err := WritePNG(*imageFlag, diffImage(snaps[0], snaps[1], *scaleFlag)); err != nil {
+            fmt.Printf("Could not write diff image %s: %v\n", *imageFlag, err)
+        }
+    }
+    if d.Differs() {
+        os.Exit(1)
+    }
+}
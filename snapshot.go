@@ -0,0 +1,222 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+)
+
+/**
+    @file snapshot.go
+    @brief Saving and loading world snapshots as JSON
+    A snapshot records the full state of every cell so a world can be
+    inspected, compared or reloaded after the run has finished.
+    Each row of the grid is stored as a string of glyphs ('~', 'F', 'S' or a species glyph),
+    with the breed timers and shark energies stored row by row alongside it.
+*/
+
+//  @brief Snapshot is the on-disk JSON form of a world at a given chronon
+type Snapshot struct {
+    Chronon     int        `json:"chronon"`
+    Size        int        `json:"size"`
+    FishBreed   int        `json:"fishBreed"`
+    SharkBreed  int        `json:"sharkBreed"`
+    Starve      int        `json:"starve"`
+    OrcaBreed   int        `json:"orcaBreed,omitempty"`
+    OrcaStarve  int        `json:"orcaStarve,omitempty"`
+    CorpseDecay int        `json:"corpseDecay,omitempty"`
+    FishMature  int        `json:"fishMature,omitempty"`
+    FishEggs    int        `json:"fishEggs,omitempty"`
+    Gestation   int        `json:"gestation,omitempty"`
+    Topology    Topology   `json:"topology,omitempty"`
+    Depth       int        `json:"depth,omitempty"` //  Depth layers, stored one below the other in the rows
+    Rows        []string   `json:"rows"`
+    BreedTimer  [][]int    `json:"breedTimer"`
+    Energy      [][]int    `json:"energy"`
+    Stage       []string   `json:"stage,omitempty"`     //  Rows of 'j' (juvenile) and '.' (adult), only when fish mature
+    Pregnancy   [][]int    `json:"pregnancy,omitempty"` //  Gestation left in each cell, only when sharks have a gestation
+    Streams     [][]uint64 `json:"streams,omitempty"`   //  Random stream of each creature, only with -entity-rng
+    Homes       [][]int    `json:"homes,omitempty"`     //  Anchor of each shark's range as Life.Home holds it, only with -territory
+}
+
+//  @brief Captures the state of w at the given chronon
+func NewSnapshot(w *World, chronon int) Snapshot {
+    s := Snapshot{
+        Chronon:     chronon,
+        Size:        w.Size,
+        FishBreed:   w.FishBreed,
+        SharkBreed:  w.SharkBreed,
+        Starve:      w.Starve,
+        OrcaBreed:   w.OrcaBreed,
+        OrcaStarve:  w.OrcaStarve,
+        CorpseDecay: w.CorpseDecay,
+        FishMature:  w.FishMature,
+        FishEggs:    w.FishEggs,
+        Gestation:   w.Gestation,
+        Topology:    w.Topology,
+        Rows:        make([]string, w.Rows()),
+        BreedTimer:  make([][]int, w.Rows()),
+        Energy:      make([][]int, w.Rows()),
+    }
+
+    // A flat ocean is stored without a depth, as before depth layers existed
+    if w.Depth > 1 {
+        s.Depth = w.Depth
+    }
+
+    line := make([]byte, w.Size)
+    for row := 0; row < w.Rows(); row++ {
+        s.BreedTimer[row] = make([]int, w.Size)
+        s.Energy[row] = make([]int, w.Size)
+        for col := 0; col < w.Size; col++ {
+            cell := w.Cells[row][col]
+            line[col] = cellGlyph(cell.Entity)
+            s.BreedTimer[row][col] = cell.BreedTimer
+            s.Energy[row][col] = cell.Energy
+        }
+        s.Rows[row] = string(line)
+    }
+
+    // Life stages are only stored when fish have them
+    if w.FishMature > 0 {
+        s.Stage = make([]string, w.Rows())
+        for row := 0; row < w.Rows(); row++ {
+            for col := 0; col < w.Size; col++ {
+                line[col] = '.'
+                if w.Cells[row][col].Stage == Juvenile {
+                    line[col] = 'j'
+                }
+            }
+            s.Stage[row] = string(line)
+        }
+    }
+
+    if w.Gestation > 0 {
+        s.Pregnancy = make([][]int, w.Rows())
+        for row := 0; row < w.Rows(); row++ {
+            s.Pregnancy[row] = make([]int, w.Size)
+            for col := 0; col < w.Size; col++ {
+                s.Pregnancy[row][col] = w.Cells[row][col].Gestation
+            }
+        }
+    }
+
+    // Creatures only carry random streams of their own with -entity-rng
+    if w.hasStreams() {
+        s.Streams = make([][]uint64, w.Rows())
+        for row := 0; row < w.Rows(); row++ {
+            s.Streams[row] = make([]uint64, w.Size)
+            for col := 0; col < w.Size; col++ {
+                s.Streams[row][col] = w.Cells[row][col].Stream
+            }
+        }
+    }
+
+    // Sharks only have anchors with -territory
+    if w.hasHomes() {
+        s.Homes = make([][]int, w.Rows())
+        for row := 0; row < w.Rows(); row++ {
+            s.Homes[row] = make([]int, w.Size)
+            for col := 0; col < w.Size; col++ {
+                s.Homes[row][col] = w.Cells[row][col].Home
+            }
+        }
+    }
+
+    return s
+}
+
+//  @brief Rebuilds the world described by the snapshot
+func (s Snapshot) World() (*World, error) {
+    rows := s.Size * max(s.Depth, 1)
+    if len(s.Rows) != rows || len(s.BreedTimer) != rows || len(s.Energy) != rows {
+        return nil, fmt.Errorf("snapshot has %d rows, expected %d", len(s.Rows), rows)
+    }
+
+    w := NewWorld(Config{
+        GridSize:    s.Size,
+        FishBreed:   s.FishBreed,
+        SharkBreed:  s.SharkBreed,
+        Starve:      s.Starve,
+        OrcaBreed:   s.OrcaBreed,
+        OrcaStarve:  s.OrcaStarve,
+        CorpseDecay: s.CorpseDecay,
+        FishMature:  s.FishMature,
+        FishEggs:    s.FishEggs,
+        Gestation:   s.Gestation,
+        Topology:    s.Topology,
+        Depth:       s.Depth,
+    })
+
+    if s.Stage != nil && len(s.Stage) != rows {
+        return nil, fmt.Errorf("snapshot has %d stage rows, expected %d", len(s.Stage), rows)
+    }
+    if s.Pregnancy != nil && len(s.Pregnancy) != rows {
+        return nil, fmt.Errorf("snapshot has %d pregnancy rows, expected %d", len(s.Pregnancy), rows)
+    }
+    if s.Streams != nil && len(s.Streams) != rows {
+        return nil, fmt.Errorf("snapshot has %d stream rows, expected %d", len(s.Streams), rows)
+    }
+    if s.Homes != nil && len(s.Homes) != rows {
+        return nil, fmt.Errorf("snapshot has %d home rows, expected %d", len(s.Homes), rows)
+    }
+
+    for row := 0; row < rows; row++ {
+        if len(s.Rows[row]) != s.Size || len(s.BreedTimer[row]) != s.Size || len(s.Energy[row]) != s.Size {
+            return nil, fmt.Errorf("snapshot row %d does not have %d cells", row, s.Size)
+        }
+        for col := 0; col < s.Size; col++ {
+            e, ok := entityForGlyph(s.Rows[row][col])
+            if !ok {
+                return nil, fmt.Errorf("snapshot cell (%d, %d) has unknown glyph %q", row, col, s.Rows[row][col])
+            }
+            w.Cells[row][col] = Cell{
+                Entity:     e,
+                BreedTimer: s.BreedTimer[row][col],
+                Energy:     s.Energy[row][col],
+            }
+            if s.Stage != nil && len(s.Stage[row]) == s.Size && s.Stage[row][col] == 'j' {
+                w.Cells[row][col].Stage = Juvenile
+            }
+            if s.Pregnancy != nil && len(s.Pregnancy[row]) == s.Size {
+                w.Cells[row][col].Gestation = s.Pregnancy[row][col]
+            }
+            if s.Streams != nil && len(s.Streams[row]) == s.Size {
+                w.Cells[row][col].Life.Stream = s.Streams[row][col]
+            }
+            if s.Homes != nil && len(s.Homes[row]) == s.Size {
+                w.Cells[row][col].Life.Home = s.Homes[row][col]
+            }
+        }
+    }
+
+    return w, nil
+}
+
+//  @brief Writes a snapshot of w to the given file
+func WriteSnapshot(path string, w *World, chronon int) error {
+    f, err := CreateOutput(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    if err := json.NewEncoder(f).Encode(NewSnapshot(w, chronon)); err != nil {
+        return err
+    }
+    return f.Close()
+}
+
+//  @brief Reads a snapshot from the given file
+func ReadSnapshot(path string) (Snapshot, error) {
+    f, err := OpenInput(path)
+    if err != nil {
+        return Snapshot{}, err
+    }
+    defer f.Close()
+
+    var s Snapshot
+    if err := json.NewDecoder(f).Decode(&s); err != nil {
+        return Snapshot{}, fmt.Errorf("could not decode snapshot %s: %v", path, err)
+    }
+    return s, nil
+}
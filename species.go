@@ -0,0 +1,316 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+/**
+    @file species.go
+    @brief Species definition files: generalising fish and sharks into a small food web
+    A species file declares any number of species, each with its own breed
+    time, starvation time, energy gained per meal and a diet listing which
+    species it eats, for example
+
+        {"species": [
+            {"name": "fish",  "glyph": "F", "breed": 3, "initial": 2000},
+            {"name": "shark", "glyph": "S", "breed": 6, "starve": 5, "diet": ["fish"], "initial": 300}
+        ]}
+
+    A species with an empty diet never starves (like fish). A diet may also
+    list "corpse" to make the species a scavenger of starved predators when
+    corpses are enabled, and "depth" names the layer a species drifts towards
+    in a layered ocean. Species are stored in the grid as Entity values
+    starting at firstSpecies.
+*/
+
+//  @brief Species describes one kind of creature in a food web
+type Species struct {
+    Name       string   `json:"name"`
+    Glyph      string   `json:"glyph"`      //  Single character used when drawing
+    Breed      int      `json:"breed"`      //  Chronons between reproductions
+    Starve     int      `json:"starve"`     //  Maximum energy; predators lose 1 per chronon and die at 0
+    EnergyGain int      `json:"energyGain"` //  Energy gained per meal (0 = restore to Starve)
+    Diet       []string `json:"diet"`       //  Names of the species this one eats
+    Initial    int      `json:"initial"`    //  Number placed at the start of the run
+    Depth      int      `json:"depth"`      //  Layer the species drifts towards when not eating, 1 being the surface (0 = no preference)
+
+    entity    Entity //  Value stored in the grid for this species
+    eats      []bool //  eats[i] reports whether species i is in the diet
+    scavenges bool   //  The diet lists corpses (see corpse.go)
+}
+
+//  @brief FoodWeb is the set of species loaded from a species file
+type FoodWeb struct {
+    Species []Species `json:"species"`
+}
+
+//  @brief Species in use by the running process, looked up when drawing and saving cells
+var (
+    speciesMu    sync.RWMutex
+    speciesTable []*Species
+)
+
+//  @brief Reports whether the species eats creatures of entity e
+func (sp *Species) Eats(e Entity) bool {
+    i := int(e - firstSpecies)
+    return i >= 0 && i < len(sp.eats) && sp.eats[i]
+}
+
+//  @brief Entity stored in the grid for this species
+func (sp *Species) Entity() Entity {
+    return sp.entity
+}
+
+//  @brief Reads and validates a species file
+func LoadFoodWeb(path string) (*FoodWeb, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+
+    var web FoodWeb
+    if err := json.Unmarshal(data, &web); err != nil {
+        return nil, fmt.Errorf("could not decode species file %s: %v", path, err)
+    }
+    if err := web.resolve(); err != nil {
+        return nil, fmt.Errorf("species file %s: %v", path, err)
+    }
+    return &web, nil
+}
+
+//  @brief Validates the species and resolves names in diets to species indices
+func (web *FoodWeb) resolve() error {
+    if len(web.Species) == 0 {
+        return fmt.Errorf("no species defined")
+    }
+
+    index := make(map[string]int, len(web.Species))
+    glyphs := make(map[string]string, len(web.Species))
+    for i := range web.Species {
+        sp := &web.Species[i]
+        sp.Name = strings.ToLower(strings.TrimSpace(sp.Name))
+        switch {
+        case sp.Name == "":
+            return fmt.Errorf("species %d has no name", i+1)
+        case sp.Name == corpseDiet:
+            return fmt.Errorf("%q is reserved for corpses and cannot name a species", corpseDiet)
+        case len(sp.Glyph) != 1 || strings.ContainsAny(sp.Glyph, "~ \n"):
+            return fmt.Errorf("species %s needs a single printable glyph character", sp.Name)
+        case sp.Breed <= 0:
+            return fmt.Errorf("species %s: breed must be greater than 0", sp.Name)
+        case sp.Starve < 0 || sp.EnergyGain < 0 || sp.Initial < 0 || sp.Depth < 0:
+            return fmt.Errorf("species %s: starve, energyGain, initial and depth must be 0 or greater", sp.Name)
+        case len(sp.Diet) > 0 && sp.Starve == 0:
+            return fmt.Errorf("species %s eats other species, so starve must be greater than 0", sp.Name)
+        }
+        if _, dup := index[sp.Name]; dup {
+            return fmt.Errorf("species %s is defined twice", sp.Name)
+        }
+        if other, dup := glyphs[sp.Glyph]; dup {
+            return fmt.Errorf("species %s and %s share the glyph %q", other, sp.Name, sp.Glyph)
+        }
+        index[sp.Name] = i
+        glyphs[sp.Glyph] = sp.Name
+        sp.entity = firstSpecies + Entity(i)
+    }
+
+    for i := range web.Species {
+        sp := &web.Species[i]
+        sp.eats = make([]bool, len(web.Species))
+        for _, name := range sp.Diet {
+            name = strings.ToLower(strings.TrimSpace(name))
+            if name == corpseDiet {
+                sp.scavenges = true
+                continue
+            }
+            prey, ok := index[name]
+            if !ok {
+                return fmt.Errorf("species %s eats unknown species %q", sp.Name, name)
+            }
+            sp.eats[prey] = true
+        }
+    }
+    return nil
+}
+
+//  @brief Makes the food web's species known to drawing and snapshot code
+func (web *FoodWeb) Activate() {
+    speciesMu.Lock()
+    defer speciesMu.Unlock()
+
+    speciesTable = make([]*Species, len(web.Species))
+    for i := range web.Species {
+        speciesTable[i] = &web.Species[i]
+    }
+}
+
+//  @brief Returns the active species stored as entity e, or nil if e is not a species
+func speciesOf(e Entity) *Species {
+    speciesMu.RLock()
+    defer speciesMu.RUnlock()
+
+    i := int(e - firstSpecies)
+    if i < 0 || i >= len(speciesTable) {
+        return nil
+    }
+    return speciesTable[i]
+}
+
+//  @brief Returns the active species drawn with the given glyph, or nil
+func speciesByGlyph(g byte) *Species {
+    speciesMu.RLock()
+    defer speciesMu.RUnlock()
+
+    for _, sp := range speciesTable {
+        if sp.Glyph[0] == g {
+            return sp
+        }
+    }
+    return nil
+}
+
+//  @brief Total number of creatures placed at the start of the run
+func (web *FoodWeb) InitialTotal() int {
+    total := 0
+    for _, sp := range web.Species {
+        total += sp.Initial
+    }
+    return total
+}
+
+//  @brief Randomly places the initial population of every species into empty cells
+func (w *World) PopulateSpecies(web *FoodWeb, rnd RNG) error {
+    spots := w.emptyCellsIn(Region{}.Resolve(w.Size))
+    if web.InitialTotal() > len(spots) {
+        return fmt.Errorf("cannot place %d creatures in %d empty cells", web.InitialTotal(), len(spots))
+    }
+
+    pickRandom(spots, web.InitialTotal(), rnd)
+    next := 0
+    for _, sp := range web.Species {
+        for _, pos := range spots[next : next+sp.Initial] {
+            w.Cells[pos[0]][pos[1]] = Cell{Entity: sp.entity, Energy: sp.Starve}
+        }
+        next += sp.Initial
+    }
+    return nil
+}
+
+//  @brief Counts the creatures of every species, in species order
+func (web *FoodWeb) Counts(w *World) []int {
+    counts := make([]int, len(web.Species))
+    w.ForEachEntity(func(row, col int, c Cell) {
+        if i := int(c.Entity - firstSpecies); i >= 0 && i < len(counts) {
+            counts[i]++
+        }
+    })
+    return counts
+}
+
+//  @brief Returns how many species still have at least one living creature
+func (web *FoodWeb) Surviving(w *World) int {
+    alive := 0
+    for _, n := range web.Counts(w) {
+        if n > 0 {
+            alive++
+        }
+    }
+    return alive
+}
+
+//  @brief Formats the population of w, per species when a food web is active, otherwise as fish, sharks and any orcas, followed by corpses when enabled
+func populationLine(w *World) string {
+    speciesMu.RLock()
+    table := speciesTable
+    speciesMu.RUnlock()
+
+    var parts []string
+    if len(table) == 0 {
+        parts = append(parts, "Fish: "+strconv.Itoa(countEntities(w, Fish)), "Sharks: "+strconv.Itoa(countEntities(w, Shark)))
+        if w.OrcaStarve > 0 {
+            parts = append(parts, "Orcas: "+strconv.Itoa(countEntities(w, Orca)))
+        }
+    }
+    for _, sp := range table {
+        parts = append(parts, sp.Name+": "+strconv.Itoa(countEntities(w, sp.entity)))
+    }
+    if w.CorpseDecay > 0 {
+        parts = append(parts, "Corpses: "+strconv.Itoa(countEntities(w, Corpse)))
+    }
+    if w.FishEggs > 0 {
+        parts = append(parts, "Eggs: "+strconv.Itoa(countEntities(w, Egg)))
+    }
+    return strings.Join(parts, "  ")
+}
+
+//  @brief Handles movement, eating, reproduction and starvation for a food-web creature at (row, column)
+//  Follows the shark rules for species with a diet and the fish rules for species without one
+func stepSpecies(current *World, next *World, row, col int, cfg Config, rnd RNG, mu *sync.Mutex) {
+    cell := current.Cells[row][col]
+    sp := &cfg.FoodWeb.Species[cell.Entity-firstSpecies]
+    predator := len(sp.Diet) > 0
+
+    // Predators lose 1 energy each turn
+    energy := cell.Energy
+    if predator {
+        energy--
+        if energy <= 0 {
+            mu.Lock()
+            next.Events.EnergySpent += cell.Energy
+            mu.Unlock()
+            leaveCorpse(next, row, col, cfg, mu)
+            return // starved
+        }
+    }
+
+    var scratch stepScratch
+    neighbors := current.appendNeighbors(scratch.neighbors[:0], row, col)
+
+    // 1. LOOK FOR PREY, then a corpse if the species scavenges, otherwise an empty cell
+    targets := current.appendKept(scratch.targets[:0], neighbors, func(c Cell) bool { return sp.Eats(c.Entity) })
+    if len(targets) > 0 {
+        energy = sp.Starve
+        if sp.EnergyGain > 0 {
+            energy = min(energy+sp.EnergyGain, sp.Starve)
+        }
+    } else if sp.scavenges {
+        targets = current.appendKept(scratch.targets[:0], neighbors, isEntity(Corpse))
+        if len(targets) > 0 {
+            energy = scavengedEnergy(energy, sp.Starve, cfg)
+        }
+    }
+    if len(targets) == 0 {
+        targets = current.appendKept(scratch.targets[:0], neighbors, isEntity(Empty))
+        targets = current.towardDepth(scratch.depth[:0], targets, row, sp.Depth)
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+    if predator {
+        next.Events.EnergySpent++
+        next.Events.EnergyEaten += energy - (cell.Energy - 1)
+    }
+
+    // 2. Can't move
+    if len(targets) == 0 {
+        next.place(row, col, Cell{Entity: cell.Entity, BreedTimer: cell.BreedTimer + 1, Energy: energy, Life: cell.Life})
+        return
+    }
+
+    destination := targets[pickMove(cfg, current, cell.Entity, row, col, targets, rnd)]
+
+    // 3. Reproduction happens only on a move, the baby stays behind with half the energy
+    if cell.BreedTimer+1 >= sp.Breed {
+        next.Events.EnergyBorn += energy / 2
+        next.place(row, col, Cell{Entity: cell.Entity, Energy: energy / 2})
+        next.place(destination[0], destination[1], Cell{Entity: cell.Entity, Energy: energy, Life: cell.Life.bred()})
+        return
+    }
+
+    next.place(destination[0], destination[1], Cell{Entity: cell.Entity, BreedTimer: cell.BreedTimer + 1, Energy: energy, Life: cell.Life})
+}
@@ -0,0 +1,53 @@
+package main
+
+/**
+    @file strategy.go
+    @brief How the bands of workers step a chronon, chosen with -strategy
+    The rows are split into one band per worker (see bandRows), and every
+    band is stepped by the same rules, stepCell, with the random stream of
+    its worker. The strategy only decides how the bands are run:
+
+        threaded  every band in a goroutine of its own, all at once
+        serial    the bands one after another in the simulation goroutine
+
+    Both step the same cells with the same streams, so they simulate the
+    same model, and a serial run checks a threaded one: with a seed and a
+    thread count they differ only where creatures of two bands claim one
+    cell in the same chronon, which the threaded bands settle in whatever
+    order they reach it. Only a serial run, or one with a single thread,
+    therefore repeats with its seed, e.g.
+
+        wa-tor -headless -strategy serial -seed 7 -chronons 500 300 2000 3 8 5 100 4
+*/
+
+//  @brief Strategies of running the bands of a chronon
+const (
+    strategyThreaded = "threaded"
+    strategySerial   = "serial"
+)
+
+//  @brief Reports whether strategy names a way of running the bands
+func validStrategy(strategy string) bool {
+    return strategy == strategyThreaded || strategy == strategySerial
+}
+
+//  @brief Reports whether a run of cfg repeats with its seed: whether its bands cannot race for cells
+func (cfg Config) repeatable() bool {
+    return cfg.Threads <= 1 || cfg.Strategy == strategySerial
+}
+
+//  @brief Returns the function running fn on the bands of rows split between workers for the strategy
+func bandRunner(strategy string) func(rows, workers int, fn func(worker, start, end int)) {
+    if strategy == strategySerial {
+        return serialBands
+    }
+    return inBands
+}
+
+//  @brief Splits rows into bands like inBands, but runs fn on one band after another in the calling goroutine
+func serialBands(rows, workers int, fn func(worker, start, end int)) {
+    for t := 0; t < workers; t++ {
+        start, end := bandRows(rows, workers, t)
+        fn(t, start, end)
+    }
+}
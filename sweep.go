@@ -0,0 +1,294 @@
+package main
+
+import (
+    "encoding/csv"
+    "flag"
+    "fmt"
+    "os"
+    "runtime"
+    "strings"
+)
+
+/**
+    @file sweep.go
+    @brief The sweep subcommand: one run per parameter combination and seed, classified by outcome
+    Every FishBreed, SharkBreed and Starve in the -fish-breed, -shark-breed
+    and -starve ranges (see findstable.go) is run headless with each of
+    -seeds seeds until the target -chronons or until a species dies out.
+    Each run is classified from its population series:
+        coexistence    fish and sharks are both alive at the end
+        both-extinct   the fish died out, leaving the sharks nothing to eat
+        shark-extinct-then-fish-fill
+                       the sharks died out after eating the fish below their
+                       starting number, which the fish then refill
+        fish-only      the sharks died out without ever pulling the fish
+                       below their starting number
+    and appended as a row to the -out CSV file, whose Outcome column colours
+    a phase diagram directly, e.g.
+
+        wa-tor sweep -fish-breed 1:8 -shark-breed 2:12 -starve 3 -out sweep.csv -chronons 2000 300 2000 3 8 5 100
+
+    Up to -jobs runs (one per CPU by default) execute at once, each stepping
+    its world on -threads goroutines. Rows are written as runs finish, so
+    they may be out of order; the Index column restores the sweep order.
+    A run repeats with its seed with -threads 1, the default, or -strategy
+    serial only (see strategy.go).
+
+    The CSV file doubles as the progress of the sweep. An interrupted sweep
+    is continued with -resume naming that file (and -out defaulting to it):
+    runs whose index it already holds are skipped, so the same command can
+    be repeated until the sweep is complete, e.g.
+
+        wa-tor sweep -resume sweep.csv -fish-breed 1:8 -shark-breed 2:12 -starve 3 -chronons 2000 300 2000 3 8 5 100
+
+    With -dashboard the line per run is replaced by a live view of the
+    progress and outcomes (see dashboard.go).
+*/
+
+//  Outcomes a sweep run is classified as
+const (
+    outcomeCoexistence = "coexistence"
+    outcomeBothExtinct = "both-extinct"
+    outcomeFishFill    = "shark-extinct-then-fish-fill"
+    outcomeFishOnly    = "fish-only"
+)
+
+//  @brief Outcomes in the order the summary lists them
+var sweepOutcomes = []string{outcomeCoexistence, outcomeFishFill, outcomeFishOnly, outcomeBothExtinct}
+
+//  @brief SweepRun is one run of a sweep and, once run, its result
+type SweepRun struct {
+    Index int //  Position of the run in the sweep
+    stableParams
+    Seed int64
+
+    Chronons     int //  Chronons run, less than the target when a species died out
+    Fish, Sharks int //  Populations at the end of the run
+    Outcome      string
+}
+
+//  @brief Classifies a run from its population series (the counts after each chronon) and its starting fish
+func classifyOutcome(series PopulationSeries, initialFish int) string {
+    n := len(series.Fish)
+    if n == 0 {
+        return outcomeCoexistence
+    }
+    fish, sharks := series.Fish[n-1], series.Sharks[n-1]
+    switch {
+    case fish > 0 && sharks > 0:
+        return outcomeCoexistence
+    case fish == 0:
+        return outcomeBothExtinct
+    }
+
+    for i, f := range series.Fish {
+        if series.Sharks[i] > 0 && f < float64(initialFish) {
+            return outcomeFishFill
+        }
+    }
+    return outcomeFishOnly
+}
+
+//  @brief Lists the runs of a sweep: every combination in the ranges with seeds consecutive seeds from base
+func sweepRuns(ranges [3]paramRange, seeds int, base int64) []SweepRun {
+    var runs []SweepRun
+    for fb := ranges[0].Lo; fb <= ranges[0].Hi; fb++ {
+        for sb := ranges[1].Lo; sb <= ranges[1].Hi; sb++ {
+            for st := ranges[2].Lo; st <= ranges[2].Hi; st++ {
+                for i := 0; i < seeds; i++ {
+                    runs = append(runs, SweepRun{Index: len(runs), stableParams: stableParams{fb, sb, st}, Seed: base + int64(i)})
+                }
+            }
+        }
+    }
+    return runs
+}
+
+//  @brief Runs r with the parameters and seed it names and records the result
+func (r *SweepRun) Run(cfg Config) error {
+    cfg.FishBreed, cfg.SharkBreed, cfg.Starve, cfg.Seed = r.FishBreed, r.SharkBreed, r.Starve, r.Seed
+    var series PopulationSeries
+    chronons, fish, sharks, err := survivalRun(cfg, &series)
+    if err != nil {
+        return err
+    }
+    r.Chronons, r.Fish, r.Sharks = chronons, fish, sharks
+    r.Outcome = classifyOutcome(series, cfg.NumFish)
+    return nil
+}
+
+//  @brief Runs every run of the sweep on up to jobs goroutines, calling done with each as it finishes
+//  done is called from one goroutine at a time, so it may print and write files without further locking.
+//  Returns the first error; runs already started are finished first.
+func RunSweep(cfg Config, runs []SweepRun, jobs int, done func(*SweepRun)) error {
+    return runJobs(len(runs), jobs, func(i int) error {
+        return runs[i].Run(cfg)
+    }, func(i int) {
+        done(&runs[i])
+    })
+}
+
+//  @brief Reads the finished runs of a sweep CSV file, by index; a file that does not exist yet holds none
+func readSweepCSV(path string) (map[int]SweepRun, error) {
+    finished := make(map[int]SweepRun)
+    f, err := OpenInput(path)
+    if os.IsNotExist(err) {
+        return finished, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    records, err := csv.NewReader(f).ReadAll()
+    if err != nil {
+        return nil, err
+    }
+    for i, rec := range records {
+        if i == 0 && strings.Join(rec, ",") == sweepHeader {
+            continue
+        }
+        var r SweepRun
+        if len(rec) != 9 {
+            return nil, fmt.Errorf("line %d has %d fields, a sweep row has 9", i+1, len(rec))
+        }
+        _, err := fmt.Sscanf(strings.Join(rec[:8], " "), "%d %d %d %d %d %d %d %d",
+            &r.Index, &r.FishBreed, &r.SharkBreed, &r.Starve, &r.Seed, &r.Chronons, &r.Fish, &r.Sharks)
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %v", i+1, err)
+        }
+        r.Outcome = rec[8]
+        finished[r.Index] = r
+    }
+    return finished, nil
+}
+
+//  @brief Fills in the runs already finished and returns the ones left to run
+//  Fails if a finished run has different parameters or seed, i.e. the file belongs to another sweep
+func resumeSweep(runs []SweepRun, finished map[int]SweepRun) ([]SweepRun, error) {
+    var todo []SweepRun
+    for i := range runs {
+        f, ok := finished[runs[i].Index]
+        if !ok {
+            todo = append(todo, runs[i])
+            continue
+        }
+        if f.stableParams != runs[i].stableParams || f.Seed != runs[i].Seed {
+            return nil, fmt.Errorf("run %d was FishBreed %d  SharkBreed %d  Starve %d  Seed %d, this sweep has FishBreed %d  SharkBreed %d  Starve %d  Seed %d",
+                f.Index, f.FishBreed, f.SharkBreed, f.Starve, f.Seed, runs[i].FishBreed, runs[i].SharkBreed, runs[i].Starve, runs[i].Seed)
+        }
+        runs[i] = f
+    }
+    return todo, nil
+}
+
+//  @brief Header of the sweep CSV file
+const sweepHeader = "Index,FishBreed,SharkBreed,Starve,Seed,Chronons,Fish,Sharks,Outcome"
+
+//  @brief Formats a finished run as a sweep CSV line, without a trailing newline
+func (r SweepRun) row() string {
+    return fmt.Sprintf("%d,%d,%d,%d,%d,%d,%d,%d,%s", r.Index, r.FishBreed, r.SharkBreed, r.Starve, r.Seed,
+        r.Chronons, r.Fish, r.Sharks, r.Outcome)
+}
+
+//  @brief Describes a finished run on one line
+func (r SweepRun) String() string {
+    return fmt.Sprintf("Run %d  FishBreed %d  SharkBreed %d  Starve %d  Seed %d: %s after %d chronons (Fish: %d  Sharks: %d)",
+        r.Index, r.FishBreed, r.SharkBreed, r.Starve, r.Seed, r.Outcome, r.Chronons, r.Fish, r.Sharks)
+}
+
+//  @brief Counts the finished runs of each outcome on one line
+func outcomeSummary(runs []SweepRun) string {
+    counts := make(map[string]int)
+    for _, r := range runs {
+        counts[r.Outcome]++
+    }
+    parts := make([]string, len(sweepOutcomes))
+    for i, o := range sweepOutcomes {
+        parts[i] = fmt.Sprintf("%s: %d", o, counts[o])
+    }
+    return "Outcomes  " + strings.Join(parts, "  ")
+}
+
+//  @brief Entry point of the sweep subcommand
+func runSweep(args []string) {
+    fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+    fishBreedFlag := fs.String("fish-breed", "1:8", "FishBreed values to sweep, lo:hi or a single value")
+    sharkBreedFlag := fs.String("shark-breed", "2:12", "SharkBreed values to sweep, lo:hi or a single value")
+    starveFlag := fs.String("starve", "1:8", "Starve values to sweep, lo:hi or a single value")
+    seedsFlag := fs.Int("seeds", 1, "Seeds each combination is run with, starting at -seed")
+    threadsFlag := fs.Int("threads", 1, "Threads each run uses (above 1 only repeatable with -strategy serial)")
+    jobsFlag := fs.Int("jobs", runtime.NumCPU(), "Runs executed at the same time")
+    outFlag := fs.String("out", "", "CSV file a row is appended to for every finished run (optional)")
+    dashboardFlag := fs.Bool("dashboard", false, "Show the progress and a phase diagram of outcomes instead of a line per run")
+    cfg := parseConfig(fs, args, false)
+
+    ranges, err := parseParamRanges(*fishBreedFlag, *sharkBreedFlag, *starveFlag)
+    if err != nil {
+        fmt.Printf("Error: %v.\n", err)
+        os.Exit(1)
+    }
+    switch {
+    case cfg.Chronons <= 0:
+        fmt.Println("Error: sweep needs a target -chronons greater than 0.")
+        os.Exit(1)
+    case *seedsFlag < 1 || *threadsFlag < 1 || *jobsFlag < 1:
+        fmt.Println("Error: -seeds, -threads and -jobs must be 1 or greater.")
+        os.Exit(1)
+    case cfg.FoodWeb != nil:
+        fmt.Println("Error: sweep varies fish and shark parameters, it cannot be combined with -species.")
+        os.Exit(1)
+    }
+
+    cfg.Threads = *threadsFlag
+    cfg.Headless = true
+    fmt.Printf("Loaded configuration: %+v\n", cfg)
+    fmt.Printf("Seed: %d\n", cfg.Seed)
+
+    runs := sweepRuns(ranges, *seedsFlag, cfg.Seed)
+    todo := runs
+    var finished map[int]SweepRun
+    if cfg.Resume != "" {
+        finished, err = readSweepCSV(cfg.Resume)
+        if err == nil {
+            todo, err = resumeSweep(runs, finished)
+        }
+        if err != nil {
+            fmt.Printf("Error: could not resume the sweep from %s: %v\n", cfg.Resume, err)
+            os.Exit(1)
+        }
+        fmt.Printf("Resuming from %s: %d of %d runs already finished\n", cfg.Resume, len(runs)-len(todo), len(runs))
+        if *outFlag == "" {
+            *outFlag = cfg.Resume
+        }
+    }
+    // -resume names the sweep file here, the runs themselves always start from a new world
+    cfg.Resume = ""
+
+    fmt.Printf("Sweeping %d runs on %d jobs\n", len(todo), *jobsFlag)
+    var dashboard *SweepDashboard
+    if *dashboardFlag {
+        dashboard = NewSweepDashboard(os.Stdout, isTerminal(os.Stdout), ranges, runs, finished)
+        dashboard.Draw()
+    }
+    err = RunSweep(cfg, todo, *jobsFlag, func(r *SweepRun) {
+        if dashboard != nil {
+            dashboard.Finish(r)
+        } else {
+            fmt.Println(r)
+        }
+        if *outFlag != "" {
+            if err := appendCSVRow(*outFlag, sweepHeader, r.row()); err != nil {
+                fmt.Printf("Could not write sweep %s: %v\n", *outFlag, err)
+            }
+        }
+    })
+    if err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+    for _, r := range todo {
+        runs[r.Index] = r
+    }
+    fmt.Println(outcomeSummary(runs))
+}
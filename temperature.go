@@ -0,0 +1,102 @@
+package main
+
+import (
+    "fmt"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+/**
+    @file temperature.go
+    @brief How far the behavior of a species is followed, set with -temperature
+    A behavior (see behavior.go) picks the move it finds best every time.
+    -temperature SPECIES=T mixes that with the random walk of the plain
+    rules: each move is a random candidate with chance T and the behavior's
+    pick otherwise, so T 0 is fully greedy, T 1 a pure random walk and the
+    values between sweep the spectrum, e.g.
+
+        wa-tor -headless -behavior wator.Stalking -temperature shark=0.25 300 2000 3 8 5 100 4
+
+    Species are named as for behaviors, and a temperature needs a behavior
+    selected for its species. The chance is drawn from the creature's
+    random stream, except at 0 and 1, which draw nothing extra.
+*/
+
+//  @brief Steps the chance of a random move is drawn in
+const temperatureSteps = 1000000
+
+//  @brief Temperatures is a repeatable command-line flag collecting SPECIES=T pairs
+type Temperatures map[string]float64
+
+//  @brief Formats the pairs as a comma separated list, sorted by species (flag.Value)
+func (t *Temperatures) String() string {
+    var pairs []string
+    for species, temp := range *t {
+        pairs = append(pairs, species+"="+strconv.FormatFloat(temp, 'g', -1, 64))
+    }
+    slices.Sort(pairs)
+    return strings.Join(pairs, ",")
+}
+
+//  @brief Adds one SPECIES=T pair each time the flag is given (flag.Value)
+func (t *Temperatures) Set(s string) error {
+    species, value, ok := strings.Cut(strings.TrimSpace(s), "=")
+    if !ok || species == "" {
+        return fmt.Errorf("%q is not SPECIES=T", s)
+    }
+    temp, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+        return fmt.Errorf("%q: temperature %q is not a number", s, value)
+    }
+    if *t == nil {
+        *t = Temperatures{}
+    }
+    (*t)[species] = temp
+    return nil
+}
+
+//  @brief Reports whether every temperature lies between 0 and 1
+func (t Temperatures) valid() bool {
+    for _, temp := range t {
+        if temp < 0 || temp > 1 {
+            return false
+        }
+    }
+    return true
+}
+
+//  @brief Wraps the pick functions of picks in the temperatures of cfg, which must each have a behavior for their species
+func applyTemperatures(picks map[Entity]PickFunc, cfg Config) error {
+    for species, temp := range cfg.Temperature {
+        e, err := behaviorEntity(species, cfg)
+        if err != nil {
+            return fmt.Errorf("temperature: %v", err)
+        }
+        pick, ok := picks[e]
+        if !ok {
+            return fmt.Errorf("temperature of %s needs a behavior selected for %s", species, species)
+        }
+        picks[e] = withTemperature(pick, temp)
+    }
+    return nil
+}
+
+//  @brief Returns a pick function moving to a random candidate with chance temp and as pick does otherwise
+func withTemperature(pick PickFunc, temp float64) PickFunc {
+    switch temp {
+    case 0:
+        return pick
+    case 1:
+        return func(row, col int, candidates [][2]int, look func(row, col int) (int, int), intn func(n int) int) int {
+            return intn(len(candidates))
+        }
+    }
+    threshold := int(temp * temperatureSteps)
+    return func(row, col int, candidates [][2]int, look func(row, col int) (int, int), intn func(n int) int) int {
+        if intn(temperatureSteps) < threshold {
+            return intn(len(candidates))
+        }
+        return pick(row, col, candidates, look, intn)
+    }
+}
@@ -0,0 +1,123 @@
+package main
+
+/**
+    @file territory.go
+    @brief Sharks keeping to a home range around an anchor, enabled with -territory
+    Normally a shark moves to any of its candidate cells with the same
+    chance. With -territory RADIUS every shark has an anchor, the cell it
+    was in when it was first stepped, and its moves are weighed by where
+    they lead: a candidate within RADIUS cells of the anchor (counted along
+    rows and columns) has weight territoryWeight, and every cell further out
+    halves it, down to a single share. A shark still leaves its range to
+    eat, when nothing else is left, but it drifts back, so the sharks
+    spread out into ranges of their own, e.g.
+
+        wa-tor -headless -territory 6 -territory-drift 30 -chronons 500 300 2000 3 8 5 100 4
+
+    The anchor drifts itself: each chronon it moves one cell towards its
+    shark with chance 1 in -territory-drift (0 for anchors that never move),
+    so a range follows a shark that keeps finding its food elsewhere. A
+    young shark anchors where it is born. The anchor is a position of the
+    surface, shared by the depth layers, and distances wrap across the
+    edges the topology connects. A behavior selected for sharks with
+    -behavior picks their moves instead. Snapshots and checkpoints store
+    the anchors.
+*/
+
+//  @brief Weight of a candidate within the radius of the anchor, halved for every cell further out
+const territoryWeight = 8
+
+//  @brief Returns the life of a shark stepped at (row, col) of w with its anchor set, or moved on when it drifts
+func territoryLife(cfg Config, w *World, life Life, row, col int, rnd RNG) Life {
+    if cfg.TerritoryRadius == 0 {
+        return life
+    }
+    row %= w.Size
+    if life.Home == 0 {
+        life.Home = row*w.Size + col + 1
+        return life
+    }
+    if cfg.TerritoryDrift == 0 || rnd.Intn(cfg.TerritoryDrift) != 0 {
+        return life
+    }
+    hr, hc := w.home(life)
+    dr, dc := w.homeDelta(hr, row, w.rowsWrap()), w.homeDelta(hc, col, w.Topology != TopologyBounded)
+    // one cell along the axis it is further off on
+    switch {
+    case abs(dr) >= abs(dc) && dr != 0:
+        hr = (hr + sign(dr) + w.Size) % w.Size
+    case dc != 0:
+        hc = (hc + sign(dc) + w.Size) % w.Size
+    }
+    life.Home = hr*w.Size + hc + 1
+    return life
+}
+
+//  @brief Returns the index of the candidate the shark in cell at (row, col) moves to, weighed by its territory under -territory
+func pickSharkMove(cfg Config, current *World, cell Cell, row, col int, candidates [][2]int, rnd RNG) int {
+    if cfg.TerritoryRadius == 0 || cell.Home == 0 || cfg.behaviors[Shark] != nil {
+        return pickMove(cfg, current, Shark, row, col, candidates, rnd)
+    }
+    hr, hc := current.home(cell.Life)
+    var weights [maxNeighbors]int
+    total := 0
+    for i, c := range candidates {
+        d := abs(current.homeDelta(hr, c[0]%current.Size, current.rowsWrap())) + abs(current.homeDelta(hc, c[1], current.Topology != TopologyBounded))
+        weights[i] = territoryWeight >> min(max(d-cfg.TerritoryRadius, 0), 3)
+        total += weights[i]
+    }
+    n := rnd.Intn(total)
+    for i, weight := range weights[:len(candidates)] {
+        if n < weight {
+            return i
+        }
+        n -= weight
+    }
+    return len(candidates) - 1
+}
+
+//  @brief Returns the surface row and the column of the anchor in life
+func (w *World) home(life Life) (int, int) {
+    return (life.Home - 1) / w.Size, (life.Home - 1) % w.Size
+}
+
+//  @brief Reports whether the rows of w wrap from the bottom edge to the top
+func (w *World) rowsWrap() bool {
+    return w.Topology == TopologyTorus || w.Topology == ""
+}
+
+//  @brief Returns how far to is from from along an axis of w, shortest across the edges when wraps
+func (w *World) homeDelta(from, to int, wraps bool) int {
+    d := to - from
+    if wraps {
+        if d > w.Size/2 {
+            d -= w.Size
+        } else if d < -w.Size/2 {
+            d += w.Size
+        }
+    }
+    return d
+}
+
+//  @brief Reports whether any shark of w has an anchor
+func (w *World) hasHomes() bool {
+    for _, cells := range w.Cells {
+        for _, c := range cells {
+            if c.Home != 0 {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+//  @brief Returns -1, 0 or 1 for the sign of v
+func sign(v int) int {
+    switch {
+    case v < 0:
+        return -1
+    case v > 0:
+        return 1
+    }
+    return 0
+}
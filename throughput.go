@@ -0,0 +1,112 @@
+package main
+
+import (
+    "fmt"
+    "runtime/metrics"
+    "time"
+)
+
+/**
+    @file throughput.go
+    @brief Cells and creatures stepped per second
+    Wall-clock time alone cannot compare runs on different grid sizes or
+    populations, so the run also reports how many cells and how many
+    creatures it stepped per second of the step loop, e.g.
+
+        Throughput  cells/sec 12.41M  creatures/sec 3.71M  allocs/chronon 112
+
+    A creature counts once for every chronon it starts alive, a cell once
+    for every chronon. Both rates are also written to the benchmark file as
+    the CellsPerSec and CreaturesPerSec columns.
+
+    The heap allocations of the step loop are counted too, per chronon, as
+    the AllocsPerChronon column. Stepping a creature allocates nothing (see
+    stepScratch), so the count stays about the same with the population and
+    a change that allocates per cell again shows up as thousands.
+*/
+
+//  @brief Runtime metric counting the objects allocated on the heap
+const heapAllocsMetric = "/gc/heap/allocs:objects"
+
+//  @brief Throughput adds up the cells and creatures the chronons of a run stepped
+type Throughput struct {
+    Cells, Creatures int64
+    Chronons         int64  //  Chronons stepped
+    Allocs           uint64 //  Heap allocations between StartAllocs and StopAllocs
+    allocsFrom       uint64
+}
+
+//  @brief Records chronons chronons stepping w, creatures being how many the first started with
+//  The population of the chronons of a -batch in between is not counted, the first stands in for them
+func (t *Throughput) Add(w *World, creatures, chronons int) {
+    t.Cells += int64(w.Rows()*w.Size) * int64(chronons)
+    t.Creatures += int64(creatures) * int64(chronons)
+    t.Chronons += int64(chronons)
+}
+
+//  @brief Starts counting the heap allocations of the step loop
+func (t *Throughput) StartAllocs() {
+    t.allocsFrom = heapAllocs()
+}
+
+//  @brief Stops counting the heap allocations of the step loop
+func (t *Throughput) StopAllocs() {
+    t.Allocs = heapAllocs() - t.allocsFrom
+}
+
+//  @brief Returns the heap allocations per chronon stepped
+func (t Throughput) AllocsPerChronon() float64 {
+    if t.Chronons == 0 {
+        return 0
+    }
+    return float64(t.Allocs) / float64(t.Chronons)
+}
+
+//  @brief Returns how many objects the program has allocated on the heap so far
+func heapAllocs() uint64 {
+    sample := []metrics.Sample{{Name: heapAllocsMetric}}
+    metrics.Read(sample)
+    if sample[0].Value.Kind() != metrics.KindUint64 {
+        return 0
+    }
+    return sample[0].Value.Uint64()
+}
+
+//  @brief Returns the cells and creatures stepped per second of elapsed
+func (t Throughput) Rates(elapsed time.Duration) (cells, creatures float64) {
+    if elapsed <= 0 {
+        return 0, 0
+    }
+    return float64(t.Cells) / elapsed.Seconds(), float64(t.Creatures) / elapsed.Seconds()
+}
+
+//  @brief Formats the rates for the summary of a run
+func (t Throughput) Line(elapsed time.Duration) string {
+    cells, creatures := t.Rates(elapsed)
+    return fmt.Sprintf("Throughput  cells/sec %s  creatures/sec %s  allocs/chronon %.0f", siRate(cells), siRate(creatures), t.AllocsPerChronon())
+}
+
+//  @brief Formats a rate with two decimals and a k, M or G suffix
+func siRate(rate float64) string {
+    for _, unit := range []struct {
+        scale  float64
+        suffix string
+    }{{1e9, "G"}, {1e6, "M"}, {1e3, "k"}} {
+        if rate >= unit.scale {
+            return fmt.Sprintf("%.2f%s", rate/unit.scale, unit.suffix)
+        }
+    }
+    return fmt.Sprintf("%.2f", rate)
+}
+
+//  @brief Counts the creatures of w, per species when a food web is active
+func creatureCount(cfg Config, w *World) int {
+    if cfg.FoodWeb != nil {
+        total := 0
+        for _, n := range cfg.FoodWeb.Counts(w) {
+            total += n
+        }
+        return total
+    }
+    return countEntities(w, Fish) + countEntities(w, Shark) + countEntities(w, Orca)
+}
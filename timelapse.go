@@ -0,0 +1,112 @@
+package main
+
+import (
+    "image"
+    "image/color"
+    "image/gif"
+    "os"
+)
+
+/**
+    @file timelapse.go
+    @brief Condensed GIF animation of a run, written with -timelapse
+    The starting world and then every -stride chronons become one frame of
+    the animation, -scale pixels square per cell, always ending on the last
+    chronon of the run, so a run of thousands of chronons plays back in a
+    few seconds, e.g.
+
+        wa-tor -headless -timelapse run.gif -stride 50 -scale 4 -chronons 5000 300 2000 3 8 5 100 4
+
+    Cells are coloured as in the diff image: white water, blue fish, red
+    sharks, black orcas and grey for corpses and other species. Depth layers
+    are drawn one below the other. Frames are kept in memory until the run
+    ends, so a larger stride keeps long runs on large grids small.
+*/
+
+//  @brief Hundredths of a second every timelapse frame is shown for
+const timelapseDelay = 10
+
+//  @brief Colours of the timelapse frames, indexed as by timelapseIndex
+var timelapsePalette = color.Palette{chartBackground, chartBlue, chartRed, chartAxis, chartGrey}
+
+//  @brief Returns the palette index a cell holding e is drawn with
+func timelapseIndex(e Entity) uint8 {
+    switch e {
+    case Empty:
+        return 0
+    case Fish:
+        return 1
+    case Shark:
+        return 2
+    case Orca:
+        return 3
+    }
+    return 4
+}
+
+//  @brief Timelapse collects sampled chronons of a run into a GIF animation
+type Timelapse struct {
+    path   string
+    stride int
+    scale  int
+    last   int //  Chronon of the newest frame, -1 before the first
+    anim   gif.GIF
+}
+
+//  @brief Creates a timelapse written to path, sampling every stride chronons at scale pixels per cell
+func NewTimelapse(path string, stride, scale int) *Timelapse {
+    return &Timelapse{path: path, stride: stride, scale: scale, last: -1}
+}
+
+//  @brief Adds the grid of w as a frame when chronon is a multiple of the stride
+func (t *Timelapse) Sample(w *World, chronon int) {
+    if chronon%t.stride == 0 {
+        t.add(w, chronon)
+    }
+}
+
+//  @brief Draws the grid of w as the next frame
+func (t *Timelapse) add(w *World, chronon int) {
+    img := cellImage(w.Rows(), w.Size, t.scale, timelapsePalette, func(row, col int) uint8 {
+        return timelapseIndex(w.Cells[row][col].Entity)
+    })
+    t.anim.Image = append(t.anim.Image, img)
+    t.anim.Delay = append(t.anim.Delay, timelapseDelay)
+    t.last = chronon
+}
+
+//  @brief Draws a grid of rows by cols cells, scale pixels square each, in the colour of palette the index of a cell gives
+func cellImage(rows, cols, scale int, palette color.Palette, index func(row, col int) uint8) *image.Paletted {
+    img := image.NewPaletted(image.Rect(0, 0, cols*scale, rows*scale), palette)
+    for row := 0; row < rows; row++ {
+        for col := 0; col < cols; col++ {
+            i := index(row, col)
+            if i == 0 {
+                continue
+            }
+            for y := row * scale; y < (row+1)*scale; y++ {
+                for x := col * scale; x < (col+1)*scale; x++ {
+                    img.SetColorIndex(x, y, i)
+                }
+            }
+        }
+    }
+    return img
+}
+
+//  @brief Adds the final world at chronon unless it was just sampled, then writes the animation and returns its frame count
+func (t *Timelapse) Finish(w *World, chronon int) (int, error) {
+    if chronon != t.last {
+        t.add(w, chronon)
+    }
+
+    f, err := os.Create(t.path)
+    if err != nil {
+        return 0, err
+    }
+    if err := gif.EncodeAll(f, &t.anim); err != nil {
+        f.Close()
+        return 0, err
+    }
+    return len(t.anim.Image), f.Close()
+}
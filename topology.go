@@ -0,0 +1,46 @@
+package main
+
+import "strings"
+
+/**
+    @file topology.go
+    @brief How the edges of the grid connect
+        torus     both pairs of opposite edges are joined (the classic Wa-Tor ocean)
+        bounded   no edges are joined, creatures cannot leave the grid
+        cylinder  only the left and right edges are joined
+        mobius    the left and right edges are joined with a half twist, so a
+                  creature leaving row r on one side enters row Size-1-r on the other
+    The top and bottom edges are walls on every topology except the torus.
+*/
+
+//  @brief Topology selects how the grid's edges connect
+type Topology string
+
+const (
+    TopologyTorus    Topology = "torus"
+    TopologyBounded  Topology = "bounded"
+    TopologyCylinder Topology = "cylinder"
+    TopologyMobius   Topology = "mobius"
+)
+
+//  @brief Every supported topology, in the order they are listed in help text
+var topologies = []Topology{TopologyTorus, TopologyBounded, TopologyCylinder, TopologyMobius}
+
+//  @brief Reports whether t names a supported topology
+func validTopology(t Topology) bool {
+    for _, known := range topologies {
+        if t == known {
+            return true
+        }
+    }
+    return false
+}
+
+//  @brief Returns the topology names joined by sep, for help and error messages
+func topologyNames(sep string) string {
+    names := make([]string, len(topologies))
+    for i, t := range topologies {
+        names[i] = string(t)
+    }
+    return strings.Join(names, sep)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+/**
+    @file trace.go
+    @brief Optional tracing of chronon phases, exported to an OpenTelemetry collector
+    With -otlp URL every traced chronon becomes a trace of its own: a
+    "chronon" span with "step", "draw", "count" and "checkpoint" children.
+    Spans are batched and sent in the background as OTLP/HTTP JSON to
+    URL/v1/traces, so any OpenTelemetry collector or tracing backend that
+    accepts OTLP can show them, e.g.
+
+        wa-tor -headless -otlp http://localhost:4318 -trace-every 10 300 2000 3 8 5 1000 4
+
+    A nil *Tracer and a nil *Span do nothing, so untraced runs pay nothing.
+*/
+
+//  @brief Spans sent in one request, a full batch is sent at once
+const traceBatch = 512
+
+//  @brief Longest time a finished span waits before being sent
+const traceFlushEvery = 2 * time.Second
+
+//  @brief Tracer collects finished spans and sends them to an OTLP/HTTP endpoint
+type Tracer struct {
+    url    string //  Full URL of the traces endpoint
+    every  int    //  Trace every Nth chronon
+    client *http.Client
+
+    mu      sync.Mutex
+    pending []otlpSpan
+    wake    chan struct{} //  Signals the exporter that a batch is full
+    done    chan struct{} //  Closed by Close to stop the exporter
+    stopped chan struct{} //  Closed once the exporter has sent everything
+    failed  bool          //  An export has already been reported as failing
+}
+
+//  @brief Span is one timed phase of a traced chronon
+type Span struct {
+    tracer  *Tracer
+    name    string
+    traceID [16]byte
+    id      [8]byte
+    parent  [8]byte
+    start   time.Time
+    attrs   []otlpAttribute
+}
+
+//  OTLP/HTTP JSON encoding of spans, see opentelemetry-proto's trace.proto
+type otlpSpan struct {
+    TraceID      string          `json:"traceId"`
+    SpanID       string          `json:"spanId"`
+    ParentSpanID string          `json:"parentSpanId,omitempty"`
+    Name         string          `json:"name"`
+    Kind         int             `json:"kind"`
+    Start        string          `json:"startTimeUnixNano"`
+    End          string          `json:"endTimeUnixNano"`
+    Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+//  @brief A key and value, the value keyed by its OTLP type such as "intValue"
+type otlpAttribute struct {
+    Key   string            `json:"key"`
+    Value map[string]string `json:"value"`
+}
+
+//  @brief Kind of every span, they are all internal to the process
+const otlpSpanInternal = 1
+
+//  @brief Creates a tracer sending to the collector at endpoint (e.g. http://localhost:4318) and starts its exporter
+//  @param every Trace every Nth chronon, 1 traces them all
+func NewTracer(endpoint string, every int) *Tracer {
+    t := &Tracer{
+        url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+        every:   max(every, 1),
+        client:  &http.Client{Timeout: 10 * time.Second},
+        wake:    make(chan struct{}, 1),
+        done:    make(chan struct{}),
+        stopped: make(chan struct{}),
+    }
+    go t.export()
+    return t
+}
+
+//  @brief Starts the root span of a chronon, or returns nil if the chronon is not traced
+func (t *Tracer) StartChronon(chronon int) *Span {
+    if t == nil || chronon%t.every != 0 {
+        return nil
+    }
+    s := &Span{tracer: t, name: "chronon", start: time.Now()}
+    rand.Read(s.traceID[:])
+    rand.Read(s.id[:])
+    s.SetInt("chronon", chronon)
+    return s
+}
+
+//  @brief Starts a child span of s
+func (s *Span) Child(name string) *Span {
+    if s == nil {
+        return nil
+    }
+    child := &Span{tracer: s.tracer, name: name, traceID: s.traceID, parent: s.id, start: time.Now()}
+    rand.Read(child.id[:])
+    return child
+}
+
+//  @brief Records an integer attribute on the span
+func (s *Span) SetInt(key string, value int) {
+    if s == nil {
+        return
+    }
+    s.attrs = append(s.attrs, otlpAttribute{Key: key, Value: map[string]string{"intValue": strconv.Itoa(value)}})
+}
+
+//  @brief Finishes the span and queues it for export
+func (s *Span) End() {
+    if s == nil {
+        return
+    }
+    span := otlpSpan{
+        TraceID:    hex.EncodeToString(s.traceID[:]),
+        SpanID:     hex.EncodeToString(s.id[:]),
+        Name:       s.name,
+        Kind:       otlpSpanInternal,
+        Start:      strconv.FormatInt(s.start.UnixNano(), 10),
+        End:        strconv.FormatInt(time.Now().UnixNano(), 10),
+        Attributes: s.attrs,
+    }
+    if s.parent != [8]byte{} {
+        span.ParentSpanID = hex.EncodeToString(s.parent[:])
+    }
+
+    t := s.tracer
+    t.mu.Lock()
+    t.pending = append(t.pending, span)
+    full := len(t.pending) >= traceBatch
+    t.mu.Unlock()
+
+    if full {
+        select {
+        case t.wake <- struct{}{}:
+        default:
+        }
+    }
+}
+
+//  @brief Sends the spans that are still waiting and stops the exporter
+func (t *Tracer) Close() {
+    if t == nil {
+        return
+    }
+    close(t.done)
+    <-t.stopped
+}
+
+//  @brief Sends batches of spans whenever one is full or has waited long enough, until Close
+func (t *Tracer) export() {
+    defer close(t.stopped)
+    ticker := time.NewTicker(traceFlushEvery)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-t.wake:
+        case <-ticker.C:
+        case <-t.done:
+            t.flush()
+            return
+        }
+        t.flush()
+    }
+}
+
+//  @brief Sends every waiting span, reporting only the first failure so a missing collector does not flood the output
+func (t *Tracer) flush() {
+    t.mu.Lock()
+    spans := t.pending
+    t.pending = nil
+    t.mu.Unlock()
+
+    for len(spans) > 0 {
+        n := min(len(spans), traceBatch)
+        if err := t.send(spans[:n]); err != nil && !t.failed {
+            t.failed = true
+            fmt.Printf("Could not export traces to %s: %v\n", t.url, err)
+        }
+        spans = spans[n:]
+    }
+}
+
+//  @brief Posts one batch of spans as an OTLP ExportTraceServiceRequest
+func (t *Tracer) send(spans []otlpSpan) error {
+    request := map[string]any{
+        "resourceSpans": []any{map[string]any{
+            "resource": map[string]any{
+                "attributes": []otlpAttribute{{Key: "service.name", Value: map[string]string{"stringValue": "wa-tor"}}},
+            },
+            "scopeSpans": []any{map[string]any{
+                "scope": map[string]string{"name": "wator"},
+                "spans": spans,
+            }},
+        }},
+    }
+    body, err := json.Marshal(request)
+    if err != nil {
+        return err
+    }
+
+    resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        return fmt.Errorf("collector answered %s", resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "math"
+    "os"
+    "sort"
+)
+
+/**
+    @file tune.go
+    @brief The tune subcommand: a genetic algorithm evolving parameters toward target dynamics
+    Each genome is a FishBreed, SharkBreed and Starve inside the -fish-breed,
+    -shark-breed and -starve ranges (see findstable.go). Every generation the
+    genomes are run headless with several seeds, in parallel on -workers
+    goroutines, and scored on the objective:
+        survival  chronons until either species died out, divided by the target -chronons
+        period    with -period P, how close the fish oscillation period is to P:
+                  1 / (1 + |period - P| / P), or 0 when the fish do not oscillate
+    The fitness of a genome is its mean survival over the seeds, averaged with
+    its mean period score when -period is set, so 1 is a perfect score. The
+    next generation keeps the -elite best genomes and fills the rest with
+    children of two tournament winners, each gene taken from either parent
+    and then shifted by one or two with probability -mutation, e.g.
+
+        wa-tor tune -period 200 -generations 20 -population 24 -chronons 10000 300 2000 3 8 5 100
+
+    The search itself is seeded with -seed, so a tuning run can be repeated
+    as long as its runs are: with -threads 1, the default, or -strategy
+    serial, as threaded bands settle contested cells in whatever order they
+    reach them (see strategy.go).
+*/
+
+//  @brief TunedGenome is a genome with the outcome of its runs
+type TunedGenome struct {
+    stableParams
+    Fitness      float64 //  Mean objective score in [0, 1]
+    Survived     int     //  Seeds where both species were alive at the target chronon
+    Seeds        int     //  Seeds run
+    MeanChronons float64 //  Mean chronons before either species died out, capped at the target
+    MeanPeriod   float64 //  Mean fish oscillation period over the seeds that oscillate, 0 if none does
+}
+
+//  @brief Tuner evolves genomes toward the objective, evaluating each genome at most once
+type Tuner struct {
+    Config     Config
+    Seeds      int
+    Period     int           //  Target oscillation period (0 = survival only)
+    Ranges     [3]paramRange //  FishBreed, SharkBreed and Starve
+    Population int
+    Elite      int
+    Mutation   float64 //  Probability that each gene of a child is shifted
+    Workers    int
+
+    rnd     RNG
+    results map[stableParams]TunedGenome
+}
+
+//  @brief Scores how close a measured period is to the target, 0 when there is no oscillation
+func periodScore(period int, ok bool, target int) float64 {
+    if !ok {
+        return 0
+    }
+    return 1 / (1 + math.Abs(float64(period-target))/float64(target))
+}
+
+//  @brief Runs the genome p with seeds consecutive seeds starting at cfg.Seed and scores it
+func evaluateGenome(cfg Config, p stableParams, seeds, target int) (TunedGenome, error) {
+    cfg.FishBreed, cfg.SharkBreed, cfg.Starve = p.FishBreed, p.SharkBreed, p.Starve
+    g := TunedGenome{stableParams: p, Seeds: seeds}
+    base := cfg.Seed
+    survival, periods, oscillating := 0.0, 0.0, 0
+
+    for i := 0; i < seeds; i++ {
+        cfg.Seed = base + int64(i)
+        var series PopulationSeries
+        chronons, fish, sharks, err := survivalRun(cfg, &series)
+        if err != nil {
+            return g, err
+        }
+        if fish > 0 && sharks > 0 {
+            g.Survived++
+        }
+        g.MeanChronons += float64(chronons)
+        survival += float64(chronons) / float64(cfg.Chronons)
+
+        if target > 0 {
+            period, ok := series.Period()
+            periods += periodScore(period, ok, target)
+            if ok {
+                g.MeanPeriod += float64(period)
+                oscillating++
+            }
+        }
+    }
+
+    g.MeanChronons /= float64(seeds)
+    g.Fitness = survival / float64(seeds)
+    if target > 0 {
+        g.Fitness = (g.Fitness + periods/float64(seeds)) / 2
+    }
+    if oscillating > 0 {
+        g.MeanPeriod /= float64(oscillating)
+    }
+    return g, nil
+}
+
+//  @brief Evaluates the genomes not seen before on t.Workers goroutines and returns the outcome of each
+func (t *Tuner) evaluate(genomes []stableParams) ([]TunedGenome, error) {
+    var todo []stableParams
+    queued := make(map[stableParams]bool)
+    for _, p := range genomes {
+        if _, ok := t.results[p]; !ok && !queued[p] {
+            queued[p] = true
+            todo = append(todo, p)
+        }
+    }
+
+    done := make([]TunedGenome, len(todo))
+    err := runJobs(len(todo), t.Workers, func(i int) error {
+        var err error
+        done[i], err = evaluateGenome(t.Config, todo[i], t.Seeds, t.Period)
+        return err
+    }, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    // results are stored in order once every run is finished, so the search does not depend on scheduling
+    for _, g := range done {
+        t.results[g.stableParams] = g
+    }
+
+    out := make([]TunedGenome, len(genomes))
+    for i, p := range genomes {
+        out[i] = t.results[p]
+    }
+    return out, nil
+}
+
+//  @brief Returns a genome with every gene drawn uniformly from its range
+func (t *Tuner) randomGenome() stableParams {
+    var p stableParams
+    for i, gene := range p.genes() {
+        *gene = t.Ranges[i].Lo + t.rnd.Intn(t.Ranges[i].Hi-t.Ranges[i].Lo+1)
+    }
+    return p
+}
+
+//  @brief Returns the fitter of two genomes drawn at random from the ranked generation
+func (t *Tuner) tournament(ranked []TunedGenome) stableParams {
+    a, b := t.rnd.Intn(len(ranked)), t.rnd.Intn(len(ranked))
+    // ranked is sorted best first
+    return ranked[min(a, b)].stableParams
+}
+
+//  @brief Returns a child taking each gene from either parent, then shifting it by one or two with probability t.Mutation
+func (t *Tuner) child(a, b stableParams) stableParams {
+    child := a
+    genes, other := child.genes(), b.genes()
+    for i, gene := range genes {
+        if t.rnd.Intn(2) == 0 {
+            *gene = *other[i]
+        }
+        if float64(t.rnd.Uint64()>>11)/(1<<53) < t.Mutation {
+            shift := 1 + t.rnd.Intn(2)
+            if t.rnd.Intn(2) == 0 {
+                shift = -shift
+            }
+            *gene = t.Ranges[i].clamp(*gene + shift)
+        }
+    }
+    return child
+}
+
+//  @brief Sorts genomes best first: higher fitness, then more surviving seeds, then parameter order
+func rankGenomes(genomes []TunedGenome) {
+    sort.Slice(genomes, func(i, j int) bool {
+        a, b := genomes[i], genomes[j]
+        switch {
+        case a.Fitness != b.Fitness:
+            return a.Fitness > b.Fitness
+        case a.Survived != b.Survived:
+            return a.Survived > b.Survived
+        case a.FishBreed != b.FishBreed:
+            return a.FishBreed < b.FishBreed
+        case a.SharkBreed != b.SharkBreed:
+            return a.SharkBreed < b.SharkBreed
+        }
+        return a.Starve < b.Starve
+    })
+}
+
+//  @brief Evolves the population for the given number of generations, starting from start and random genomes
+//  Prints the best and mean fitness of every generation
+func (t *Tuner) Run(start stableParams, generations int) error {
+    genomes := []stableParams{start}
+    for len(genomes) < t.Population {
+        genomes = append(genomes, t.randomGenome())
+    }
+
+    for gen := 1; ; gen++ {
+        ranked, err := t.evaluate(genomes)
+        if err != nil {
+            return err
+        }
+        rankGenomes(ranked)
+
+        mean := 0.0
+        for _, g := range ranked {
+            mean += g.Fitness
+        }
+        best := ranked[0]
+        fmt.Printf("Generation %d: best fitness %.3f (FishBreed %d  SharkBreed %d  Starve %d), mean %.3f\n",
+            gen, best.Fitness, best.FishBreed, best.SharkBreed, best.Starve, mean/float64(len(ranked)))
+        if gen == generations {
+            return nil
+        }
+
+        genomes = genomes[:0]
+        for _, g := range ranked[:t.Elite] {
+            genomes = append(genomes, g.stableParams)
+        }
+        for len(genomes) < t.Population {
+            genomes = append(genomes, t.child(t.tournament(ranked), t.tournament(ranked)))
+        }
+    }
+}
+
+//  @brief Returns every genome evaluated during the run, best first
+func (t *Tuner) Ranked() []TunedGenome {
+    ranked := make([]TunedGenome, 0, len(t.results))
+    for _, g := range t.results {
+        ranked = append(ranked, g)
+    }
+    rankGenomes(ranked)
+    return ranked
+}
+
+//  @brief Prints the best genomes as an aligned table
+func printTunedTable(ranked []TunedGenome) {
+    fmt.Printf("%4s  %9s  %10s  %6s  %7s  %8s  %13s  %10s\n", "Rank", "FishBreed", "SharkBreed", "Starve", "Fitness", "Survived", "MeanChronons", "MeanPeriod")
+    for i, g := range ranked {
+        fmt.Printf("%4d  %9d  %10d  %6d  %7.3f  %8s  %13.1f  %10.1f\n", i+1, g.FishBreed, g.SharkBreed, g.Starve,
+            g.Fitness, fmt.Sprintf("%d/%d", g.Survived, g.Seeds), g.MeanChronons, g.MeanPeriod)
+    }
+}
+
+//  @brief Writes the ranked genomes as CSV
+func writeTunedCSV(path string, ranked []TunedGenome) error {
+    f, err := CreateOutput(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    fmt.Fprintln(f, "Rank,FishBreed,SharkBreed,Starve,Fitness,Survived,Seeds,MeanChronons,MeanPeriod")
+    for i, g := range ranked {
+        fmt.Fprintf(f, "%d,%d,%d,%d,%.4f,%d,%d,%.2f,%.2f\n", i+1, g.FishBreed, g.SharkBreed, g.Starve,
+            g.Fitness, g.Survived, g.Seeds, g.MeanChronons, g.MeanPeriod)
+    }
+    return f.Close()
+}
+
+//  @brief Entry point of the tune subcommand
+func runTune(args []string) {
+    fs := flag.NewFlagSet("tune", flag.ExitOnError)
+    fishBreedFlag := fs.String("fish-breed", "1:8", "FishBreed values genomes may take, lo:hi or a single value")
+    sharkBreedFlag := fs.String("shark-breed", "2:12", "SharkBreed values genomes may take, lo:hi or a single value")
+    starveFlag := fs.String("starve", "1:8", "Starve values genomes may take, lo:hi or a single value")
+    periodFlag := fs.Int("period", 0, "Target fish oscillation period in chronons (0 = optimise survival only)")
+    generationsFlag := fs.Int("generations", 10, "Number of generations to evolve")
+    populationFlag := fs.Int("population", 16, "Genomes in each generation")
+    eliteFlag := fs.Int("elite", 2, "Best genomes carried unchanged into the next generation")
+    mutationFlag := fs.Float64("mutation", 0.3, "Probability that each gene of a child is shifted by one or two")
+    seedsFlag := fs.Int("seeds", 3, "Seeds each genome is run with, starting at -seed")
+    workersFlag := fs.Int("workers", 2, "Genomes run at the same time")
+    threadsFlag := fs.Int("threads", 1, "Threads each run uses (above 1 only repeatable with -strategy serial)")
+    topFlag := fs.Int("top", 10, "Number of best genomes to print (0 = all)")
+    outFlag := fs.String("out", "", "Also write every evaluated genome, best first, as CSV to this file")
+    cfg := parseConfig(fs, args, false)
+
+    ranges, err := parseParamRanges(*fishBreedFlag, *sharkBreedFlag, *starveFlag)
+    if err != nil {
+        fmt.Printf("Error: %v.\n", err)
+        os.Exit(1)
+    }
+    switch {
+    case cfg.Chronons <= 0:
+        fmt.Println("Error: tune needs a target -chronons greater than 0.")
+        os.Exit(1)
+    case *periodFlag < 0:
+        fmt.Println("Error: -period cannot be negative.")
+        os.Exit(1)
+    case *generationsFlag < 1 || *populationFlag < 2:
+        fmt.Println("Error: -generations must be 1 or greater and -population 2 or greater.")
+        os.Exit(1)
+    case *eliteFlag < 0 || *eliteFlag >= *populationFlag:
+        fmt.Println("Error: -elite must be between 0 and one less than -population.")
+        os.Exit(1)
+    case *mutationFlag < 0 || *mutationFlag > 1:
+        fmt.Println("Error: -mutation must be between 0 and 1.")
+        os.Exit(1)
+    case *seedsFlag < 1 || *workersFlag < 1 || *threadsFlag < 1:
+        fmt.Println("Error: -seeds, -workers and -threads must be 1 or greater.")
+        os.Exit(1)
+    case *topFlag < 0:
+        fmt.Println("Error: -top cannot be negative.")
+        os.Exit(1)
+    case cfg.FoodWeb != nil || cfg.Resume != "":
+        fmt.Println("Error: tune evolves fish and shark parameters, it cannot be combined with -species or -resume.")
+        os.Exit(1)
+    }
+
+    cfg.Threads = *threadsFlag
+    cfg.Headless = true
+    fmt.Printf("Loaded configuration: %+v\n", cfg)
+    fmt.Printf("Seed: %d\n", cfg.Seed)
+
+    tuner := Tuner{
+        Config:     cfg,
+        Seeds:      *seedsFlag,
+        Period:     *periodFlag,
+        Ranges:     ranges,
+        Population: *populationFlag,
+        Elite:      *eliteFlag,
+        Mutation:   *mutationFlag,
+        Workers:    *workersFlag,
+        rnd:        mustRNG(cfg.RNG, cfg.Seed),
+        results:    make(map[stableParams]TunedGenome),
+    }
+    // the positional parameters join the first generation, so a known good setting is never lost
+    if err := tuner.Run(startParams(cfg, ranges), *generationsFlag); err != nil {
+        fmt.Printf("Error: %v\n", err)
+        os.Exit(1)
+    }
+
+    ranked := tuner.Ranked()
+    fmt.Printf("Evaluated %d genomes over %d seeds to %d chronons\n", len(ranked), tuner.Seeds, cfg.Chronons)
+    top := ranked
+    if *topFlag > 0 && *topFlag < len(top) {
+        top = top[:*topFlag]
+    }
+    printTunedTable(top)
+
+    if *outFlag != "" {
+        if err := writeTunedCSV(*outFlag, ranked); err != nil {
+            fmt.Printf("Could not write genomes %s: %v\n", *outFlag, err)
+        }
+    }
+}
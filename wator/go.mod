@@ -1,3 +0,0 @@
-module wator
-
-go 1.24.0
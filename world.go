@@ -1,14 +1,17 @@
 package main
 
 import (
-    "math/rand"
+    "encoding/binary"
+    "fmt"
+    "hash/fnv"
+    "slices"
 )
 
 /**
 	@file world.go
 	@brief Defines the World structure and grid operations for the Wa-Tor simulation
 	The World contains:
-		A 2D toroidal grid of Cells
+		A 2D grid of Cells, toroidal unless another topology is chosen (see topology.go)
 		Simulation parameters from Config
 		Helper functions for movement and neighbor retrieval
 */
@@ -19,9 +22,64 @@ type World struct {
     Cells [][]Cell  //	2D array storing every cell in the world
 
     // Parameters copied from Config for convenience
-    FishBreed  int
-    SharkBreed int
-    Starve     int
+    FishBreed   int
+    SharkBreed  int
+    Starve      int
+    OrcaBreed   int //  Zero when the run has no orcas
+    OrcaStarve  int
+    CorpseDecay int //  Zero when starved predators leave no corpse
+    FishMature  int //  Zero when fish have no juvenile stage
+    FishEggs    int //  Zero when fish give birth to live young instead of laying eggs
+    Gestation   int //  Zero when sharks give birth without a pregnancy
+    Topology    Topology
+    Depth       int //  Number of depth layers, 0 or 1 for a flat ocean
+
+    Events Events //  What happened during the chronon that produced this world
+
+    shared []bool //  Rows a View still shares, copied before they are written, see writable
+}
+
+//  @brief Events counts the births, meals and deaths of one or more chronons
+//  The step functions record them in the next world while holding the step mutex
+type Events struct {
+    FishBirths    int `json:"fishBirths"`
+    SharkBirths   int `json:"sharkBirths"`
+    FishEaten     int `json:"fishEaten"` //  By sharks and orcas
+    SharksStarved int `json:"sharksStarved"`
+    SharksEaten   int `json:"sharksEaten"` //  By orcas and cannibal sharks
+    OrcaBirths    int `json:"orcaBirths,omitempty"`
+    OrcasStarved  int `json:"orcasStarved,omitempty"`
+    EggsLaid      int `json:"eggsLaid,omitempty"` //  By fish with -fish-eggs, a hatching counts as a fish birth
+    EggsEaten     int `json:"eggsEaten,omitempty"` //  By sharks
+
+    // Energy of the creatures, booked for the ledger of energy.go
+    EnergyEaten int `json:"energyEaten,omitempty"` //  Gained from meals
+    EnergyBorn  int `json:"energyBorn,omitempty"`  //  What the young start with
+    EnergySpent int `json:"energySpent,omitempty"` //  Used up living, the last of it by the starved
+    EnergyLost  int `json:"energyLost,omitempty"`  //  Held by creatures whose cell another creature then took
+}
+
+//  @brief Adds the counts of other to e
+func (e *Events) Add(other Events) {
+    e.FishBirths += other.FishBirths
+    e.SharkBirths += other.SharkBirths
+    e.FishEaten += other.FishEaten
+    e.SharksStarved += other.SharksStarved
+    e.SharksEaten += other.SharksEaten
+    e.OrcaBirths += other.OrcaBirths
+    e.OrcasStarved += other.OrcasStarved
+    e.EggsLaid += other.EggsLaid
+    e.EggsEaten += other.EggsEaten
+    e.EnergyEaten += other.EnergyEaten
+    e.EnergyBorn += other.EnergyBorn
+    e.EnergySpent += other.EnergySpent
+    e.EnergyLost += other.EnergyLost
+}
+
+//  @brief Formats the fish and shark counts for the stats line, see Mortality for the full ledger
+func (e Events) String() string {
+    return fmt.Sprintf("Fish births: %d  Shark births: %d  Fish eaten: %d  Sharks starved: %d",
+        e.FishBirths, e.SharkBirths, e.FishEaten, e.SharksStarved)
 }
 
 /**
@@ -29,19 +87,96 @@ type World struct {
 	Creating a square gird of size x size
 */
 func NewWorld(cfg Config) *World {
-    //	Allocate a 2D slice of Cells
-    cells := make([][]Cell, cfg.GridSize)
+    //	Allocate a 2D slice of Cells, with the depth layers stacked one below the other
+    cells := make([][]Cell, cfg.GridSize*max(cfg.Depth, 1))
     for i := range cells {
         cells[i] = make([]Cell, cfg.GridSize)
     }
 
     return &World{
-        Size:       cfg.GridSize,
-        Cells:      cells,
-        FishBreed:  cfg.FishBreed,
-        SharkBreed: cfg.SharkBreed,
-        Starve:     cfg.Starve,
+        Size:        cfg.GridSize,
+        Cells:       cells,
+        FishBreed:   cfg.FishBreed,
+        SharkBreed:  cfg.SharkBreed,
+        Starve:      cfg.Starve,
+        OrcaBreed:   cfg.OrcaBreed,
+        OrcaStarve:  cfg.OrcaStarve,
+        CorpseDecay: cfg.CorpseDecay,
+        FishMature:  cfg.FishMature,
+        FishEggs:    cfg.FishEggs,
+        Gestation:   cfg.Gestation,
+        Topology:    cfg.Topology,
+        Depth:       cfg.Depth,
+    }
+}
+
+//  @brief Number of rows in Cells: Size rows for each depth layer
+//  Layer l holds rows l*Size to (l+1)*Size-1, so a cell's layer is row / Size
+func (w *World) Rows() int {
+    return w.Size * max(w.Depth, 1)
+}
+
+//  @brief Returns a 64-bit FNV-1a digest of the grid: its dimensions and every cell's entity, timers, energy and stage
+//  Equal worlds give equal hashes on every platform and version, so two runs can be compared chronon by chronon
+//  without writing snapshots; the parameters copied from Config are not included
+func (w *World) Hash() uint64 {
+    h := fnv.New64a()
+    buf := make([]byte, 0, 2*8+w.Size*(4*8+1))
+    buf = binary.LittleEndian.AppendUint64(buf, uint64(w.Size))
+    buf = binary.LittleEndian.AppendUint64(buf, uint64(max(w.Depth, 1)))
+    h.Write(buf)
+
+    for row := 0; row < w.Rows(); row++ {
+        buf = buf[:0]
+        for _, c := range w.Cells[row] {
+            buf = binary.LittleEndian.AppendUint64(buf, uint64(c.Entity))
+            buf = binary.LittleEndian.AppendUint64(buf, uint64(c.BreedTimer))
+            buf = binary.LittleEndian.AppendUint64(buf, uint64(c.Energy))
+            buf = binary.LittleEndian.AppendUint64(buf, uint64(c.Gestation))
+            buf = append(buf, byte(c.Stage))
+        }
+        h.Write(buf)
+    }
+    return h.Sum64()
+}
+
+//  @brief Returns a copy of w that shares nothing with it, its cells held in a single allocation
+func (w *World) Clone() *World {
+    c := *w
+    c.shared = nil
+    cells := make([]Cell, w.Rows()*w.Size)
+    c.Cells = make([][]Cell, w.Rows())
+    for row := range c.Cells {
+        c.Cells[row] = cells[row*w.Size : (row+1)*w.Size : (row+1)*w.Size]
+        copy(c.Cells[row], w.Cells[row])
     }
+    return &c
+}
+
+/**
+	@brief Returns a read-only view of w as it is now, which stays so while w goes on changing
+	The view shares the rows of w instead of copying them; w copies a row the first time it writes it
+	afterwards (copy on write). StepWorld never writes the world it steps, so a view taken after a chronon
+	can be read in another goroutine, e.g. by a checkpoint writer, while the next chronon is computed
+*/
+func (w *World) View() *World {
+    v := *w
+    v.Cells = slices.Clone(w.Cells)
+    v.shared = nil
+    w.shared = make([]bool, len(w.Cells))
+    for row := range w.shared {
+        w.shared[row] = true
+    }
+    return &v
+}
+
+//  @brief Returns the cells of row for writing them in place, copying the row first while a View shares it
+func (w *World) writable(row int) []Cell {
+    if w.shared != nil && w.shared[row] {
+        w.Cells[row] = slices.Clone(w.Cells[row])
+        w.shared[row] = false
+    }
+    return w.Cells[row]
 }
 
 /**
@@ -58,59 +193,334 @@ func (w *World) wrap(i int) int {
 }
 
 /**
-	@   brief Returns the indices of the 4 neighboring cells
+	@   brief Returns the indices of the 4 neighboring cells, plus the cells above and below in a layered ocean
+	On topologies with edges, cells on an edge have fewer neighbours
 */
 func (w *World) Neighbors(row, col int) [][2]int {
-    return [][2]int{
-        {w.wrap(row-1), col}, //	North
-        {w.wrap(row+1), col}, //	South
-        {row, w.wrap(col-1)}, //	West
-        {row, w.wrap(col+1)}, //	East
+    return w.appendNeighbors(make([][2]int, 0, maxNeighbors), row, col)
+}
+
+//  @brief Most neighbours a cell can have: four within its layer, one above and one below
+const maxNeighbors = 6
+
+//  @brief Appends the neighbours of (row, column) to dst in the order of Neighbors
+//  The step rules pass an array of a stepScratch, so that stepping a cell allocates nothing
+func (w *World) appendNeighbors(dst [][2]int, row, col int) [][2]int {
+    layer := 0
+    if w.Depth > 1 {
+        // the moves within a layer are those of a single layer, then up and down follow
+        layer, row = row/w.Size, row%w.Size
     }
+    first := len(dst)
+    if w.Topology == TopologyTorus || w.Topology == "" {
+        dst = append(dst,
+            [2]int{w.wrap(row - 1), col}, //	North
+            [2]int{w.wrap(row + 1), col}, //	South
+            [2]int{row, w.wrap(col - 1)}, //	West
+            [2]int{row, w.wrap(col + 1)}, //	East
+        )
+    } else {
+        for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+            if r, c, ok := w.move(row+d[0], col+d[1]); ok {
+                dst = append(dst, [2]int{r, c})
+            }
+        }
+    }
+    if w.Depth <= 1 {
+        return dst
+    }
+
+    for i := first; i < len(dst); i++ {
+        dst[i][0] += layer * w.Size
+    }
+    row += layer * w.Size
+    if layer > 0 {
+        dst = append(dst, [2]int{row - w.Size, col}) //	Up
+    }
+    if layer < w.Depth-1 {
+        dst = append(dst, [2]int{row + w.Size, col}) //	Down
+    }
+    return dst
+}
+
+/**
+	@brief stepScratch holds the neighbour lists the rules for one creature are worked out with
+	A step function declares one as a local variable, so the lists live in arrays on the stack of the
+	worker stepping the cell instead of slices allocated for every creature of every chronon
+*/
+type stepScratch struct {
+    neighbors [maxNeighbors][2]int //  Every neighbour, see appendNeighbors
+    targets   [maxNeighbors][2]int //  The neighbours a creature could move to, see appendKept
+    depth     [maxNeighbors][2]int //  The targets left by towardDepth
+}
+
+//  @brief Puts c into (row, column) of the next world, booking the energy of any creature already there as lost, must be called with the step mutex held
+//  The next world starts out empty, so a creature there is one c takes the cell from
+func (w *World) place(row, col int, c Cell) {
+    w.Events.EnergyLost += w.Cells[row][col].energy()
+    w.Cells[row][col] = c
+}
+
+//  @brief Position is the row and column of a cell, rows of deeper layers following those above
+type Position struct {
+    Row, Col int
+}
+
+//  @brief Neighbor is a neighbouring cell together with its position
+type Neighbor struct {
+    Position
+    Cell
+}
+
+//  @brief Returns the neighbouring cells of (row, column) with their positions, in the order of Neighbors
+func (w *World) NeighborCells(row, col int) []Neighbor {
+    positions := w.Neighbors(row, col)
+    cells := make([]Neighbor, len(positions))
+    for i, p := range positions {
+        cells[i] = Neighbor{Position{p[0], p[1]}, w.Cells[p[0]][p[1]]}
+    }
+    return cells
 }
 
 /**
-	@brief Randomly places sharks and fish into empty cells at the start of the simulation
+	@brief Returns the positions of the cells at most radius steps from (row, column), nearest first, without the cell itself
+	Steps are those of Neighbors, so edges, the seam of a Mobius strip and depth layers count as for a single move;
+	radius 1 gives the cells of Neighbors
 */
-func (w *World) Populate(numFish, numShark int) {
-    total := w.Size * w.Size
-
-    //	Generate a list of all cell positions
-    positions := make([][2]int, 0, total)
-    for row := 0; row < w.Size; row++ {
-        for col := 0; col < w.Size; col++ {
-            positions = append(positions, [2]int{row, col})
+func (w *World) NeighborsWithin(row, col, radius int) [][2]int {
+    seen := map[[2]int]bool{{row, col}: true}
+    var within [][2]int
+    ring := [][2]int{{row, col}}
+    for step := 0; step < radius && len(ring) > 0; step++ {
+        var next [][2]int
+        for _, p := range ring {
+            for _, n := range w.Neighbors(p[0], p[1]) {
+                if !seen[n] {
+                    seen[n] = true
+                    next = append(next, n)
+                }
+            }
         }
+        within = append(within, next...)
+        ring = next
     }
+    return within
+}
 
-    //	Shuffle positions
-    rand.Shuffle(len(positions), func(i, j int) {
-        positions[i], positions[j] = positions[j], positions[i]
-    })
+//  @brief Returns the neighbours of (row, column) whose cell keep accepts, in the order of Neighbors
+func (w *World) NeighborsWhere(row, col int, keep func(Cell) bool) [][2]int {
+    return w.filter(w.Neighbors(row, col), keep)
+}
+
+//  @brief Returns the neighbours of (row, column) holding e
+func (w *World) NeighborsOf(row, col int, e Entity) [][2]int {
+    return w.filter(w.Neighbors(row, col), isEntity(e))
+}
+
+//  @brief Returns the empty neighbours of (row, column)
+func (w *World) EmptyNeighbors(row, col int) [][2]int {
+    return w.NeighborsOf(row, col, Empty)
+}
+
+//  @brief Returns the positions among cells whose cell keep accepts, for rules that look at one list of neighbours several ways
+func (w *World) filter(cells [][2]int, keep func(Cell) bool) [][2]int {
+    return w.appendKept(make([][2]int, 0, len(cells)), cells, keep)
+}
 
-    index := 0
-
-    //	Place sharks
-    for shark := 0; shark < numShark && index < len(positions); shark++ {
-        pos := positions[index]
-        index++
-        w.Cells[pos[0]][pos[1]].Entity = Shark
-        w.Cells[pos[0]][pos[1]].Energy = w.Starve
-        w.Cells[pos[0]][pos[1]].BreedTimer = 0
-    }
-
-    //	Place fish
-    for fish := 0; fish < numFish && index < len(positions); fish++ {
-        pos := positions[index]
-        index++
-        //	Only place fish in empty cells
-        if w.Cells[pos[0]][pos[1]].Entity == Empty {
-            w.Cells[pos[0]][pos[1]].Entity = Fish
-            w.Cells[pos[0]][pos[1]].BreedTimer = 0
+//  @brief Appends the positions among cells whose cell keep accepts to dst, like filter without allocating
+func (w *World) appendKept(dst, cells [][2]int, keep func(Cell) bool) [][2]int {
+    for _, p := range cells {
+        if keep(w.Cells[p[0]][p[1]]) {
+            dst = append(dst, p)
         }
     }
+    return dst
 }
 
+//  @brief Returns a filter accepting the cells that hold e
+func isEntity(e Entity) func(Cell) bool {
+    return func(c Cell) bool { return c.Entity == e }
+}
+
+//  @brief Drops the moves that would take a creature at row further from its preferred depth layer
+//  Moves within a layer are always kept; if no move is left the targets are returned unchanged
+//  @param dst Where the moves kept are appended, an empty slice of an array of a stepScratch
+//  @param prefer The preferred layer counted from 1 at the surface, or 0 for no preference
+func (w *World) towardDepth(dst, targets [][2]int, row, prefer int) [][2]int {
+    if w.Depth <= 1 || prefer <= 0 || len(targets) == 0 {
+        return targets
+    }
+    distance := abs(row/w.Size - (prefer - 1))
+    for _, t := range targets {
+        if abs(t[0]/w.Size-(prefer-1)) <= distance {
+            dst = append(dst, t)
+        }
+    }
+    if len(dst) == 0 {
+        return targets
+    }
+    return dst
+}
+
+//  @brief Maps a position one step off the grid back onto it for the world's (non-torus) topology
+//  @return The position, or false if the step leaves the grid through an edge
+func (w *World) move(row, col int) (int, int, bool) {
+    // Only the torus wraps vertically
+    if row < 0 || row >= w.Size {
+        return 0, 0, false
+    }
+    if col >= 0 && col < w.Size {
+        return row, col, true
+    }
+
+    switch w.Topology {
+    case TopologyCylinder:
+        return row, w.wrap(col), true
+    case TopologyMobius:
+        // Crossing the seam turns the strip over, so the row is mirrored
+        return w.Size - 1 - row, w.wrap(col), true
+    }
+    return 0, 0, false
+}
+
+/**
+	@brief Randomly places exactly numShark sharks and numFish fish into empty cells at the start of the simulation
+	@param fishRegion, sharkRegion Rectangles the fish and sharks are confined to, the zero Region means the whole grid
+	In a layered ocean a region covers the same rectangle in every layer
+	@param rnd Source of the random placement, derive it from the master seed (see placementRNG) so placement is reproducible
+	@return The number of fish and sharks actually placed, or an error (placing nothing) if they do not fit
+*/
+func (w *World) Populate(numFish, numShark int, fishRegion, sharkRegion Region, rnd RNG) (int, int, error) {
+    if numFish < 0 || numShark < 0 {
+        return 0, 0, fmt.Errorf("cannot place a negative number of creatures")
+    }
+    for _, r := range []Region{fishRegion, sharkRegion} {
+        if err := r.Validate(w.Size); err != nil {
+            return 0, 0, err
+        }
+    }
+    fishRegion, sharkRegion = fishRegion.Resolve(w.Size), sharkRegion.Resolve(w.Size)
 
+    sharkSpots := w.emptyCellsIn(sharkRegion)
+    fishSpots := w.emptyCellsIn(fishRegion)
 
+    //	Sharks are placed first and may take cells where both regions overlap,
+    //	so fish must fit even if every shark lands in the overlap
+    shared := 0
+    for _, pos := range fishSpots {
+        if sharkRegion.Contains(pos[0]%w.Size, pos[1]) {
+            shared++
+        }
+    }
+    if numShark > len(sharkSpots) {
+        return 0, 0, fmt.Errorf("cannot place %d sharks in the %d empty cells of region %s", numShark, len(sharkSpots), sharkRegion)
+    }
+    if numFish > len(fishSpots)-min(numShark, shared) {
+        return 0, 0, fmt.Errorf("cannot place %d fish in the %d empty cells of region %s left after the sharks",
+            numFish, len(fishSpots)-min(numShark, shared), fishRegion)
+    }
 
+    pickRandom(sharkSpots, numShark, rnd)
+    for _, pos := range sharkSpots[:numShark] {
+        w.Cells[pos[0]][pos[1]] = Cell{Entity: Shark, Energy: w.Starve}
+    }
+
+    //	Every chosen position is distinct and still empty, so each creature gets its own cell
+    fishSpots = w.emptyCellsIn(fishRegion)
+    pickRandom(fishSpots, numFish, rnd)
+    for _, pos := range fishSpots[:numFish] {
+        w.Cells[pos[0]][pos[1]] = Cell{Entity: Fish}
+    }
+
+    return numFish, numShark, nil
+}
+
+//  @brief Creates the initial world described by cfg: its food web if one is given, otherwise fish and sharks
+func NewPopulatedWorld(cfg Config) (*World, error) {
+    w := NewWorld(cfg)
+    if cfg.FoodWeb != nil {
+        return w, w.PopulateSpecies(cfg.FoodWeb, placementRNG(cfg))
+    }
+    rnd := placementRNG(cfg)
+    if _, _, err := w.Populate(cfg.NumFish, cfg.NumShark, cfg.FishRegion, cfg.SharkRegion, rnd); err != nil {
+        return w, err
+    }
+    return w, w.PopulateOrcas(cfg.NumOrca, rnd)
+}
+
+//  @brief Randomly places exactly numOrca orcas into the cells left empty after Populate
+//  Orcas are drawn after fish and sharks, so adding them does not change where those are placed for a given seed
+func (w *World) PopulateOrcas(numOrca int, rnd RNG) error {
+    spots := w.emptyCellsIn(Region{}.Resolve(w.Size))
+    if numOrca > len(spots) {
+        return fmt.Errorf("cannot place %d orcas in the %d empty cells left after the fish and sharks", numOrca, len(spots))
+    }
+
+    pickRandom(spots, numOrca, rnd)
+    for _, pos := range spots[:numOrca] {
+        w.Cells[pos[0]][pos[1]] = Cell{Entity: Orca, Energy: w.OrcaStarve}
+    }
+    return nil
+}
+
+//  @brief Returns the positions of every empty cell inside the region, in every depth layer
+func (w *World) emptyCellsIn(r Region) [][2]int {
+    layers := max(w.Depth, 1)
+    positions := make([][2]int, 0, (r.Row1-r.Row0)*(r.Col1-r.Col0)*layers)
+    for layer := 0; layer < layers; layer++ {
+        for row := r.Row0 + layer*w.Size; row < r.Row1+layer*w.Size; row++ {
+            for col := r.Col0; col < r.Col1; col++ {
+                if w.Cells[row][col].Entity == Empty {
+                    positions = append(positions, [2]int{row, col})
+                }
+            }
+        }
+    }
+    return positions
+}
+
+//  @brief Calls fn for every cell holding a creature or a corpse, row by row
+func (w *World) ForEachEntity(fn func(row, col int, c Cell)) {
+    for row := 0; row < w.Rows(); row++ {
+        for col, c := range w.Cells[row] {
+            if c.Entity != Empty {
+                fn(row, col, c)
+            }
+        }
+    }
+}
+
+//  @brief Returns the positions of the cells holding kind, row by row
+func (w *World) Entities(kind Entity) []Position {
+    var positions []Position
+    w.ForEachEntity(func(row, col int, c Cell) {
+        if c.Entity == kind {
+            positions = append(positions, Position{row, col})
+        }
+    })
+    return positions
+}
+
+/**
+	@brief Calls fn for every cell of w, the rows split between threads goroutines as StepWorld splits them
+	fn is given a copy of the cell, so any layer it computes (a diffusion field, statistics per cell) goes into
+	storage of the caller's with one entry per cell written only for that cell, or per row, which no two goroutines share;
+	w must not change until ApplyParallel returns. threads is limited as for the simulation, see workerCount
+*/
+func (w *World) ApplyParallel(fn func(row, col int, c Cell), threads int) {
+    inBands(w.Rows(), workerCount(threads, w.Rows()), func(_, start, end int) {
+        for row := start; row < end; row++ {
+            for col, c := range w.Cells[row] {
+                fn(row, col, c)
+            }
+        }
+    })
+}
+
+//  @brief Moves k uniformly chosen positions to the front of the slice (partial Fisher-Yates shuffle)
+func pickRandom(positions [][2]int, k int, rnd RNG) {
+    for i := 0; i < k; i++ {
+        j := i + rnd.Intn(len(positions)-i)
+        positions[i], positions[j] = positions[j], positions[i]
+    }
+}